@@ -0,0 +1,248 @@
+package framework
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 慢请求的默认阈值
+const defaultSlowThreshold = 5 * time.Second
+
+// AdminConfig 运行时管理接口的配置
+type AdminConfig struct {
+	// SlowThreshold 处理时间超过这个值的请求为慢请求，默认5秒
+	SlowThreshold time.Duration
+	// LogSlowRequests 定期检查慢请求，把每个慢请求的调用栈写入一次日志，StopAdmin或者服务关闭时停止
+	LogSlowRequests bool
+}
+
+// LevelLogger 可以在运行中调整级别的日志，AxisLogger实现了这个接口
+type LevelLogger interface {
+	Logger
+	SetLevel(level Level)
+	Level() Level
+}
+
+// ErrLevelNotSupported core的日志不支持调整级别，日志级别接口返回501
+var ErrLevelNotSupported = errors.New("logger does not support changing level")
+
+// inflightView 正在处理的请求的输出形式
+type inflightView struct {
+	InflightRequest
+	Elapsed string   `json:"elapsed"`
+	Stacks  []string `json:"stacks,omitempty"` // 处理请求的goroutine和它启动的goroutine的调用栈
+}
+
+// Admin 在group下注册运行时管理接口，group需要自己加上鉴权中间件
+// 注册后会给处理请求的goroutine加上pprof标签，用于获取慢请求的调用栈，重复调用时停止之前的慢请求检查
+//
+//	GET  /requests             正在处理的请求和已经处理的时间
+//	GET  /slow?threshold=5s    处理时间超过阈值的请求和它们的调用栈
+//	GET  /loglevel             当前的日志级别
+//	PUT  /loglevel?level=debug&duration=10m  调整日志级别，duration不为空时到时间后恢复
+func (c *Core) Admin(group IGroup, config AdminConfig) {
+	if config.SlowThreshold <= 0 {
+		config.SlowThreshold = defaultSlowThreshold
+	}
+	c.inflight.EnableGoroutineLabels()
+	c.StopAdmin()
+	if config.LogSlowRequests {
+		ctx, cancel := context.WithCancel(context.Background())
+		c.adminMu.Lock()
+		c.stopSlowWatch = cancel
+		c.adminMu.Unlock()
+		go c.watchSlowRequests(ctx, config.SlowThreshold)
+	}
+
+	group.Get("/requests", func(ctx *Context) error {
+		now := time.Now()
+		requests := c.inflight.Snapshot()
+		views := make([]inflightView, 0, len(requests))
+		for _, req := range requests {
+			views = append(views, inflightView{InflightRequest: req, Elapsed: now.Sub(req.Start).String()})
+		}
+		return writeDebugJSON(ctx, map[string]interface{}{
+			"count":      len(views),
+			"goroutines": c.inflight.Goroutines(),
+			"requests":   views,
+		})
+	})
+	group.Get("/slow", func(ctx *Context) error {
+		threshold := config.SlowThreshold
+		if s, ok := ctx.QueryString("threshold", ""); ok && s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return writeBadRequest(ctx, err)
+			}
+			threshold = d
+		}
+		return writeDebugJSON(ctx, c.slowRequests(threshold))
+	})
+
+	var (
+		mu       sync.Mutex
+		restore  *time.Timer // 临时调整后恢复级别的定时器
+		original Level       // 临时调整之前的级别
+	)
+	group.Get("/loglevel", func(ctx *Context) error {
+		logger, ok := c.Logger().(LevelLogger)
+		if !ok {
+			return writeJSONError(ctx, http.StatusNotImplemented, ErrLevelNotSupported)
+		}
+		return writeDebugJSON(ctx, map[string]string{"level": logger.Level().String()})
+	})
+	setLevel := func(ctx *Context) error {
+		logger, ok := c.Logger().(LevelLogger)
+		if !ok {
+			return writeJSONError(ctx, http.StatusNotImplemented, ErrLevelNotSupported)
+		}
+		s, _ := ctx.QueryString("level", "")
+		level, err := ParseLevel(s)
+		if err != nil {
+			return writeBadRequest(ctx, err)
+		}
+		var duration time.Duration
+		if s, _ := ctx.QueryString("duration", ""); s != "" {
+			if duration, err = time.ParseDuration(s); err != nil {
+				return writeBadRequest(ctx, err)
+			}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		previous := logger.Level()
+		if restore != nil && restore.Stop() {
+			// 之前的临时调整还没有恢复，恢复时使用最初的级别
+			previous = original
+		}
+		restore = nil
+		// 在修改之前记录，调高级别之后warn日志可能不再输出
+		c.Logger().Warn("log level changed", "from", previous.String(), "to", level.String(), "duration", duration)
+		logger.SetLevel(level)
+		if duration > 0 {
+			original = previous
+			var timer *time.Timer
+			timer = time.AfterFunc(duration, func() {
+				mu.Lock()
+				defer mu.Unlock()
+				if restore != timer {
+					// 定时器触发时已经有新的调整，Stop没能取消这次恢复
+					return
+				}
+				restore = nil
+				logger.SetLevel(previous)
+				c.Logger().Warn("log level restored", "level", previous.String())
+			})
+			restore = timer
+		}
+		return writeDebugJSON(ctx, map[string]string{"level": level.String(), "previous": previous.String()})
+	}
+	group.Put("/loglevel", setLevel)
+	group.Post("/loglevel", setLevel)
+}
+
+// writeBadRequest 参数错误时返回400和错误信息
+func writeBadRequest(ctx *Context, err error) error {
+	return writeJSONError(ctx, http.StatusBadRequest, err)
+}
+
+// writeJSONError 返回状态码status和json格式的错误信息
+func writeJSONError(ctx *Context, status int, err error) error {
+	ctx.responseWriter.Header().Set("Content-Type", "application/json")
+	ctx.responseWriter.WriteHeader(status)
+	body, _ := json.Marshal(map[string]string{"error": err.Error()})
+	ctx.responseWriter.Write(body)
+	return nil
+}
+
+// StopAdmin 停止Admin启动的慢请求检查，Server关闭时会调用，不使用Server时需要自己调用
+func (c *Core) StopAdmin() {
+	c.adminMu.Lock()
+	defer c.adminMu.Unlock()
+	if c.stopSlowWatch != nil {
+		c.stopSlowWatch()
+		c.stopSlowWatch = nil
+	}
+}
+
+// slowRequests 处理时间超过threshold的请求，带有调用栈
+func (c *Core) slowRequests(threshold time.Duration) []inflightView {
+	now := time.Now()
+	var slow []inflightView
+	for _, req := range c.inflight.Snapshot() {
+		if elapsed := now.Sub(req.Start); elapsed >= threshold {
+			slow = append(slow, inflightView{InflightRequest: req, Elapsed: elapsed.String()})
+		}
+	}
+	if len(slow) == 0 {
+		return slow
+	}
+	stacks := requestStacks()
+	for i := range slow {
+		slow[i].Stacks = stacks[slow[i].ID]
+	}
+	return slow
+}
+
+// requestStacks 按请求ID分组的goroutine调用栈，从带标签的goroutine profile中解析
+func requestStacks() map[uint64][]string {
+	var buf bytes.Buffer
+	pprof.Lookup("goroutine").WriteTo(&buf, 1)
+	marker := `"` + goroutineLabelKey + `":"`
+	stacks := map[uint64][]string{}
+	// debug=1的格式中每组调用栈之间用空行分隔，标签在"# labels: "行中
+	for _, block := range strings.Split(buf.String(), "\n\n") {
+		i := strings.Index(block, marker)
+		if i < 0 {
+			continue
+		}
+		rest := block[i+len(marker):]
+		end := strings.IndexByte(rest, '"')
+		if end < 0 {
+			continue
+		}
+		id, err := strconv.ParseUint(rest[:end], 10, 64)
+		if err != nil {
+			continue
+		}
+		stacks[id] = append(stacks[id], strings.TrimSpace(block))
+	}
+	return stacks
+}
+
+// watchSlowRequests 定期检查慢请求，每个请求只记录一次，ctx取消时退出
+func (c *Core) watchSlowRequests(ctx context.Context, threshold time.Duration) {
+	interval := threshold / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	logged := map[uint64]bool{}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		current := map[uint64]bool{}
+		for _, req := range c.slowRequests(threshold) {
+			current[req.ID] = true
+			if logged[req.ID] {
+				continue
+			}
+			c.Logger().Warn("slow request", "method", req.Method, "path", req.Path, "route", req.Route,
+				"request_id", req.RequestID, "elapsed", req.Elapsed, "stack", strings.Join(req.Stacks, "\n\n"))
+		}
+		// 已经结束的请求不再记录
+		logged = current
+	}
+}
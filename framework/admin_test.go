@@ -0,0 +1,155 @@
+package framework
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// plainLogger 不支持调整级别的日志
+type plainLogger struct{ Logger }
+
+func newAdminCore(t *testing.T, config AdminConfig) *Core {
+	t.Helper()
+	core := NewCore()
+	core.SetMode(TestMode)
+	core.Admin(core.Group("/admin"), config)
+	t.Cleanup(core.StopAdmin)
+	return core
+}
+
+func serveAdmin(core *Core, method, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	core.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec
+}
+
+func TestAdminLogLevel(t *testing.T) {
+	tests := []struct {
+		name   string
+		logger Logger
+		method string
+		path   string
+		status int
+		want   map[string]string
+	}{
+		{name: "get", method: "GET", path: "/admin/loglevel", status: http.StatusOK, want: map[string]string{"level": "info"}},
+		{name: "set", method: "PUT", path: "/admin/loglevel?level=debug", status: http.StatusOK, want: map[string]string{"level": "debug", "previous": "info"}},
+		{name: "bad level", method: "PUT", path: "/admin/loglevel?level=verbose", status: http.StatusBadRequest},
+		{name: "bad duration", method: "PUT", path: "/admin/loglevel?level=debug&duration=soon", status: http.StatusBadRequest},
+		{name: "get not supported", logger: plainLogger{}, method: "GET", path: "/admin/loglevel", status: http.StatusNotImplemented,
+			want: map[string]string{"error": ErrLevelNotSupported.Error()}},
+		{name: "set not supported", logger: plainLogger{}, method: "PUT", path: "/admin/loglevel?level=debug", status: http.StatusNotImplemented,
+			want: map[string]string{"error": ErrLevelNotSupported.Error()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core := newAdminCore(t, AdminConfig{})
+			logger := tt.logger
+			if logger == nil {
+				logger = NewLogger(io.Discard, LevelInfo, JSONEncoder{})
+			}
+			core.SetLogger(logger)
+			rec := serveAdmin(core, tt.method, tt.path)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if rec.Header().Get("Content-Type") != "application/json" {
+				t.Fatalf("content type = %q", rec.Header().Get("Content-Type"))
+			}
+			if tt.want == nil {
+				return
+			}
+			var got map[string]string
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			for key, value := range tt.want {
+				if got[key] != value {
+					t.Fatalf("%s = %q, want %q", key, got[key], value)
+				}
+			}
+		})
+	}
+}
+
+func TestAdminLogLevelRestore(t *testing.T) {
+	core := newAdminCore(t, AdminConfig{})
+	logger := NewLogger(io.Discard, LevelInfo, JSONEncoder{})
+	core.SetLogger(logger)
+
+	serveAdmin(core, "PUT", "/admin/loglevel?level=debug&duration=20ms")
+	// 在恢复之前再次临时调整，恢复到最初的级别
+	serveAdmin(core, "PUT", "/admin/loglevel?level=warn&duration=20ms")
+	if logger.Level() != LevelWarn {
+		t.Fatalf("level = %v, want warn", logger.Level())
+	}
+	time.Sleep(100 * time.Millisecond)
+	if logger.Level() != LevelInfo {
+		t.Fatalf("level after restore = %v, want info", logger.Level())
+	}
+
+	// 恢复和并发的调整不能互相覆盖，最后一次没有duration的调整一直生效
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serveAdmin(core, "PUT", "/admin/loglevel?level=debug&duration=1ms")
+		}()
+	}
+	wg.Wait()
+	serveAdmin(core, "PUT", "/admin/loglevel?level=error")
+	time.Sleep(50 * time.Millisecond)
+	if logger.Level() != LevelError {
+		t.Fatalf("level = %v, want error", logger.Level())
+	}
+}
+
+// slowWatchers 正在运行的慢请求检查goroutine数量
+func slowWatchers() int {
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+	return bytes.Count(buf, []byte("watchSlowRequests"))
+}
+
+func TestAdminSlowWatcherStop(t *testing.T) {
+	before := slowWatchers()
+	core := NewCore()
+	core.SetMode(TestMode)
+	core.Admin(core.Group("/admin"), AdminConfig{LogSlowRequests: true})
+	core.Admin(core.Group("/admin2"), AdminConfig{LogSlowRequests: true})
+
+	waitWatchers := func(want int) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for slowWatchers() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("slow request watchers = %d, want %d", slowWatchers(), want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	// 重复调用Admin时停止之前的检查
+	waitWatchers(before + 1)
+	core.StopAdmin()
+	waitWatchers(before)
+}
+
+func TestAdminRequests(t *testing.T) {
+	core := newAdminCore(t, AdminConfig{})
+	rec := serveAdmin(core, "GET", "/admin/requests")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"/admin/requests"`) {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if rec := serveAdmin(core, "GET", "/admin/slow?threshold=never"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("bad threshold status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,526 @@
+package framework
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v2"
+)
+
+// 配置相关的环境变量
+const (
+	envConfigEnv     = "AXIS_ENV" // 运行环境，例如dev、test、prod，默认dev
+	defaultEnvName   = "dev"
+	defaultEnvPrefix = "AXIS"
+)
+
+// Config 配置服务
+// 配置目录下的yaml/json文件按文件名作为第一级key，例如app.yaml中的port为app.port
+// 运行环境子目录中的文件覆盖配置目录中的同名配置，例如config/prod/app.yaml覆盖config/app.yaml
+// 优先级从高到低为：命令行参数、环境变量、.env文件、运行环境的配置文件、公共配置文件
+type Config struct {
+	folder    string
+	env       string
+	envPrefix string
+
+	mu       sync.RWMutex
+	data     map[string]interface{} // 合并后的配置
+	dotenv   map[string]string      // .env文件中的变量，优先级低于真实的环境变量
+	flags    map[string]string      // 命令行中设置的配置
+	modTimes map[string]time.Time   // 配置文件的修改时间，用于检查文件变化
+	onChange []func(*Config)
+
+	watchOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+}
+
+// NewConfig 加载配置目录，env为空时使用环境变量AXIS_ENV，默认dev
+func NewConfig(folder string, env string) (*Config, error) {
+	if env == "" {
+		env = os.Getenv(envConfigEnv)
+	}
+	if env == "" {
+		env = defaultEnvName
+	}
+	c := &Config{
+		folder:    folder,
+		env:       env,
+		envPrefix: defaultEnvPrefix,
+		flags:     map[string]string{},
+		stop:      make(chan struct{}),
+	}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Env 当前的运行环境
+func (c *Config) Env() string {
+	return c.env
+}
+
+// SetEnvPrefix 设置环境变量的前缀，默认AXIS，配置app.server.port对应的环境变量为AXIS_APP_SERVER_PORT
+func (c *Config) SetEnvPrefix(prefix string) error {
+	c.mu.Lock()
+	c.envPrefix = prefix
+	c.mu.Unlock()
+	return c.Reload()
+}
+
+// BindFlags 使用命令行中设置过的参数覆盖同名配置，参数名为配置的key，例如 -app.server.port=9000
+// 需要在fs.Parse之后调用，没有在命令行中设置的参数不会覆盖配置
+func (c *Config) BindFlags(fs *flag.FlagSet) error {
+	c.mu.Lock()
+	fs.Visit(func(f *flag.Flag) {
+		if strings.Contains(f.Name, ".") {
+			c.flags[f.Name] = f.Value.String()
+		}
+	})
+	c.mu.Unlock()
+	return c.Reload()
+}
+
+// Reload 重新加载配置文件，出错时保留原来的配置
+func (c *Config) Reload() error {
+	files, modTimes, err := c.scanFiles()
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{}
+	dotenv := map[string]string{}
+	for _, file := range files {
+		if strings.HasPrefix(filepath.Base(file), ".env") {
+			if err := readDotenv(file, dotenv); err != nil {
+				return fmt.Errorf("config %s: %w", file, err)
+			}
+			continue
+		}
+		content, err := readConfigFile(file)
+		if err != nil {
+			return fmt.Errorf("config %s: %w", file, err)
+		}
+		name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		if old, ok := data[name].(map[string]interface{}); ok {
+			mergeConfig(old, content)
+		} else {
+			data[name] = content
+		}
+	}
+
+	c.mu.Lock()
+	c.data = data
+	c.dotenv = dotenv
+	c.modTimes = modTimes
+	c.applyOverrides("", data)
+	for key, value := range c.flags {
+		setConfigValue(data, key, parseConfigValue(value, c.lookup(key)))
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// scanFiles 按优先级从低到高列出配置文件
+func (c *Config) scanFiles() ([]string, map[string]time.Time, error) {
+	var files []string
+	for _, dir := range []string{c.folder, filepath.Join(c.folder, c.env)} {
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			switch filepath.Ext(entry.Name()) {
+			case ".yaml", ".yml", ".json":
+				files = append(files, filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+	// .env文件的优先级高于配置文件
+	for _, name := range []string{".env", ".env." + c.env} {
+		files = append(files, filepath.Join(c.folder, name))
+	}
+
+	modTimes := map[string]time.Time{}
+	ret := files[:0]
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		modTimes[file] = info.ModTime()
+		ret = append(ret, file)
+	}
+	return ret, modTimes, nil
+}
+
+// applyOverrides 使用环境变量覆盖配置中已有的值，调用方需要持有锁
+func (c *Config) applyOverrides(prefix string, data map[string]interface{}) {
+	for key, value := range data {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if sub, ok := value.(map[string]interface{}); ok {
+			c.applyOverrides(path, sub)
+			continue
+		}
+		if env, ok := c.lookupEnv(path); ok {
+			data[key] = parseConfigValue(env, value)
+		}
+	}
+}
+
+// envName 配置对应的环境变量名
+func (c *Config) envName(key string) string {
+	name := strings.NewReplacer(".", "_", "-", "_").Replace(strings.ToUpper(key))
+	if c.envPrefix == "" {
+		return name
+	}
+	return c.envPrefix + "_" + name
+}
+
+// lookupEnv 查找配置对应的环境变量，真实的环境变量优先于.env文件
+func (c *Config) lookupEnv(key string) (string, bool) {
+	name := c.envName(key)
+	if value, ok := os.LookupEnv(name); ok {
+		return value, true
+	}
+	value, ok := c.dotenv[name]
+	return value, ok
+}
+
+// lookup 在合并后的配置中查找，调用方需要持有锁
+func (c *Config) lookup(key string) interface{} {
+	var current interface{} = c.data
+	for _, part := range strings.Split(key, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if current, ok = m[part]; !ok {
+			return nil
+		}
+	}
+	return current
+}
+
+// Get 获取配置，key使用点分隔，例如app.server.port，不存在时返回nil
+// 配置文件中没有的key也可以通过环境变量设置
+func (c *Config) Get(key string) interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if value := c.lookup(key); value != nil {
+		return value
+	}
+	if env, ok := c.lookupEnv(key); ok {
+		return parseConfigValue(env, nil)
+	}
+	return nil
+}
+
+// All 合并后的全部配置的副本
+func (c *Config) All() map[string]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return copyConfig(c.data)
+}
+
+// copyConfig 深拷贝配置，避免调用方修改内部数据
+func copyConfig(data map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			out[key] = copyConfig(v)
+		case []interface{}:
+			out[key] = append([]interface{}(nil), v...)
+		default:
+			out[key] = v
+		}
+	}
+	return out
+}
+
+// IsExist 配置是否存在
+func (c *Config) IsExist(key string) bool {
+	return c.Get(key) != nil
+}
+
+// GetString 获取字符串配置
+func (c *Config) GetString(key string) string {
+	return cast.ToString(c.Get(key))
+}
+
+// GetInt 获取int配置
+func (c *Config) GetInt(key string) int {
+	return cast.ToInt(c.Get(key))
+}
+
+// GetInt64 获取int64配置
+func (c *Config) GetInt64(key string) int64 {
+	return cast.ToInt64(c.Get(key))
+}
+
+// GetFloat64 获取float64配置
+func (c *Config) GetFloat64(key string) float64 {
+	return cast.ToFloat64(c.Get(key))
+}
+
+// GetBool 获取bool配置
+func (c *Config) GetBool(key string) bool {
+	return cast.ToBool(c.Get(key))
+}
+
+// GetDuration 获取时间间隔配置，例如 30s、1m30s，数字按纳秒处理
+func (c *Config) GetDuration(key string) time.Duration {
+	return cast.ToDuration(c.Get(key))
+}
+
+// GetStringSlice 获取字符串数组配置
+func (c *Config) GetStringSlice(key string) []string {
+	return cast.ToStringSlice(c.Get(key))
+}
+
+// GetStringMap 获取map配置
+func (c *Config) GetStringMap(key string) map[string]interface{} {
+	return cast.ToStringMap(c.Get(key))
+}
+
+// GetStringMapString 获取值为字符串的map配置
+func (c *Config) GetStringMapString(key string) map[string]string {
+	return cast.ToStringMapString(c.Get(key))
+}
+
+// Unmarshal 把配置解析到结构体中，结构体使用yaml tag，key为空时解析全部配置
+func (c *Config) Unmarshal(key string, obj interface{}) error {
+	var value interface{}
+	if key == "" {
+		c.mu.RLock()
+		value = c.data
+		c.mu.RUnlock()
+	} else {
+		value = c.Get(key)
+	}
+	if value == nil {
+		return fmt.Errorf("config %s not found", key)
+	}
+	c.mu.RLock()
+	out, err := yaml.Marshal(value)
+	c.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(out, obj)
+}
+
+// OnChange 注册配置文件变化后的回调，在Watch检查到变化并重新加载成功后调用
+func (c *Config) OnChange(fn func(*Config)) {
+	c.mu.Lock()
+	c.onChange = append(c.onChange, fn)
+	c.mu.Unlock()
+}
+
+// Watch 按间隔检查配置文件是否变化，变化后重新加载并通知OnChange注册的回调，只有第一次调用生效
+func (c *Config) Watch(interval time.Duration) {
+	c.watchOnce.Do(func() {
+		go c.watch(interval)
+	})
+}
+
+func (c *Config) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+		_, modTimes, err := c.scanFiles()
+		if err != nil {
+			continue
+		}
+		c.mu.RLock()
+		changed := !sameModTimes(modTimes, c.modTimes)
+		c.mu.RUnlock()
+		if !changed {
+			continue
+		}
+		if err := c.Reload(); err != nil {
+			DefaultLogger().Error("reload config failed", "folder", c.folder, "error", err)
+			continue
+		}
+		c.mu.RLock()
+		callbacks := append([]func(*Config){}, c.onChange...)
+		c.mu.RUnlock()
+		for _, fn := range callbacks {
+			fn(c)
+		}
+	}
+}
+
+// Close 停止检查配置文件
+func (c *Config) Close() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+}
+
+func sameModTimes(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for file, t := range a {
+		if !t.Equal(b[file]) {
+			return false
+		}
+	}
+	return true
+}
+
+// readConfigFile 读取yaml或者json文件
+func readConfigFile(file string) (map[string]interface{}, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Ext(file) == ".json" {
+		data := map[string]interface{}{}
+		if err := json.Unmarshal(content, &data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+	var data interface{}
+	if err := yaml.Unmarshal(content, &data); err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return map[string]interface{}{}, nil
+	}
+	m, ok := normalizeConfig(data).(map[string]interface{})
+	if !ok {
+		return nil, errors.New("top level must be a map")
+	}
+	return m, nil
+}
+
+// readDotenv 读取.env文件，格式为 KEY=VALUE，支持注释、export前缀和引号
+func readDotenv(file string, dotenv map[string]string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		dotenv[strings.TrimSpace(key)] = value
+	}
+	return scanner.Err()
+}
+
+// normalizeConfig 把yaml解析出的map[interface{}]interface{}转换为map[string]interface{}
+func normalizeConfig(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			m[fmt.Sprint(key)] = normalizeConfig(val)
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = normalizeConfig(v[i])
+		}
+	}
+	return value
+}
+
+// mergeConfig 把src深度合并到dst中
+func mergeConfig(dst, src map[string]interface{}) {
+	for key, value := range src {
+		if sub, ok := value.(map[string]interface{}); ok {
+			if old, ok := dst[key].(map[string]interface{}); ok {
+				mergeConfig(old, sub)
+				continue
+			}
+		}
+		dst[key] = value
+	}
+}
+
+// setConfigValue 设置点分隔的key，中间不存在的层级自动创建
+func setConfigValue(data map[string]interface{}, key string, value interface{}) {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		sub, ok := data[part].(map[string]interface{})
+		if !ok {
+			sub = map[string]interface{}{}
+			data[part] = sub
+		}
+		data = sub
+	}
+	data[parts[len(parts)-1]] = value
+}
+
+// parseConfigValue 解析环境变量和命令行中的字符串，原来的值是字符串时保持字符串，否则按yaml解析出数字、bool和数组
+func parseConfigValue(s string, old interface{}) interface{} {
+	if _, ok := old.(string); ok {
+		return s
+	}
+	var value interface{}
+	if err := yaml.Unmarshal([]byte(s), &value); err != nil || value == nil {
+		return s
+	}
+	if _, ok := value.(map[interface{}]interface{}); ok {
+		return s
+	}
+	return normalizeConfig(value)
+}
+
+// SetConfig 设置core使用的配置服务
+func (c *Core) SetConfig(config *Config) {
+	c.config = config
+}
+
+// Config 获取core使用的配置服务，没有设置时返回nil
+func (c *Core) Config() *Config {
+	return c.config
+}
+
+// Config 获取配置服务，没有设置时返回nil
+func (ctx *Context) Config() *Config {
+	if ctx.core == nil {
+		return nil
+	}
+	return ctx.core.config
+}
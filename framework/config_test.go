@@ -0,0 +1,280 @@
+package framework
+
+import (
+	"flag"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeConfigFiles 在dir下写入配置文件，name可以包含子目录
+func writeConfigFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// newTestConfigDir 各层配置都有的目录，每个key只在部分层中出现，用于验证优先级
+func newTestConfigDir(t *testing.T) string {
+	dir := t.TempDir()
+	writeConfigFiles(t, dir, map[string]string{
+		"app.yaml": `
+common: base
+port: 8080
+name: base
+level: base
+workers: 1
+version: "1.0"
+timeout: 5s
+tags: [a, b]
+server:
+  host: 127.0.0.1
+  debug: false
+`,
+		"prod/app.yaml": `
+port: 9090
+server:
+  debug: true
+`,
+		"dev/app.yaml": "port: 7070\n",
+		"db.json":      `{"user": "base", "pool": {"size": 10}}`,
+		".env": `# 注释
+AXIS_APP_NAME=dotenv
+export AXIS_APP_LEVEL="dotenv"
+AXIS_DB_USER=dotenv
+`,
+		".env.prod": "AXIS_DB_USER='prod'\n",
+	})
+	return dir
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	dir := newTestConfigDir(t)
+	t.Setenv("AXIS_APP_LEVEL", "env")
+	t.Setenv("AXIS_APP_WORKERS", "4")
+	t.Setenv("AXIS_APP_VERSION", "2")
+	t.Setenv("AXIS_APP_SERVER_DEBUG", "false")
+	t.Setenv("AXIS_EXTRA_LIMIT", "30")
+
+	c, err := NewConfig(dir, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("app.workers", 0, "")
+	if err := fs.Parse([]string{"-app.workers=8"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.BindFlags(fs); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key  string
+		want interface{}
+	}{
+		{key: "app.common", want: "base"},           // 只在公共配置中
+		{key: "app.port", want: 9090},               // 运行环境的配置文件覆盖公共配置
+		{key: "app.server.host", want: "127.0.0.1"}, // 合并时保留没有覆盖的子项
+		{key: "app.name", want: "dotenv"},           // .env覆盖配置文件
+		{key: "app.level", want: "env"},             // 环境变量覆盖.env
+		{key: "app.workers", want: 8},               // 命令行参数覆盖环境变量
+		{key: "app.version", want: "2"},             // 原来是字符串的配置保持字符串
+		{key: "app.server.debug", want: false},      // 环境变量覆盖运行环境的配置文件
+		{key: "db.user", want: "prod"},              // 运行环境的.env覆盖公共的.env
+		{key: "db.pool.size", want: float64(10)},    // json文件
+		{key: "extra.limit", want: 30},              // 配置文件中没有的key也可以通过环境变量设置
+		{key: "app.missing", want: nil},
+	}
+	for _, tt := range tests {
+		if got := c.Get(tt.key); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Get(%s) = %#v, want %#v", tt.key, got, tt.want)
+		}
+	}
+
+	if c.Env() != "prod" {
+		t.Errorf("Env = %s", c.Env())
+	}
+	// 其他运行环境的配置不生效
+	dev, err := NewConfig(dir, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port := dev.GetInt("app.port"); port != 7070 {
+		t.Errorf("dev app.port = %d", port)
+	}
+	if user := dev.GetString("db.user"); user != "dotenv" {
+		t.Errorf("dev db.user = %s", user)
+	}
+
+	if err := c.SetEnvPrefix("OTHER"); err != nil {
+		t.Fatal(err)
+	}
+	if level := c.GetString("app.level"); level != "base" {
+		t.Errorf("app.level with other prefix = %s", level)
+	}
+}
+
+func TestConfigBindFlags(t *testing.T) {
+	c, err := NewConfig(newTestConfigDir(t), "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("app.port", 0, "")
+	fs.String("app.name", "flag default", "")
+	fs.Bool("verbose", false, "")
+	fs.String("app.feature.enabled", "", "")
+	args := []string{"-app.port=9000", "-verbose", "-app.feature.enabled=true"}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.BindFlags(fs); err != nil {
+		t.Fatal(err)
+	}
+
+	if port := c.GetInt("app.port"); port != 9000 {
+		t.Errorf("app.port = %d", port)
+	}
+	// 没有在命令行中设置的参数不使用默认值覆盖配置
+	if name := c.GetString("app.name"); name != "dotenv" {
+		t.Errorf("app.name = %s", name)
+	}
+	// 不带点的参数不是配置
+	if c.IsExist("verbose") {
+		t.Error("flag without dot bound as config")
+	}
+	// 配置中没有的key自动创建层级
+	if enabled := c.Get("app.feature.enabled"); enabled != true {
+		t.Errorf("app.feature.enabled = %#v", enabled)
+	}
+	// 重新加载后仍然使用命令行参数
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if port := c.GetInt("app.port"); port != 9000 {
+		t.Errorf("app.port after reload = %d", port)
+	}
+}
+
+func TestConfigGetters(t *testing.T) {
+	c, err := NewConfig(newTestConfigDir(t), "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := c.GetDuration("app.timeout"); d != 5*time.Second {
+		t.Errorf("GetDuration = %v", d)
+	}
+	if tags := c.GetStringSlice("app.tags"); !reflect.DeepEqual(tags, []string{"a", "b"}) {
+		t.Errorf("GetStringSlice = %v", tags)
+	}
+	if server := c.GetStringMapString("app.server"); server["host"] != "127.0.0.1" || server["debug"] != "true" {
+		t.Errorf("GetStringMapString = %v", server)
+	}
+
+	var app struct {
+		Port   int      `yaml:"port"`
+		Tags   []string `yaml:"tags"`
+		Server struct {
+			Host  string `yaml:"host"`
+			Debug bool   `yaml:"debug"`
+		} `yaml:"server"`
+	}
+	if err := c.Unmarshal("app", &app); err != nil {
+		t.Fatal(err)
+	}
+	if app.Port != 9090 || len(app.Tags) != 2 || app.Server.Host != "127.0.0.1" || !app.Server.Debug {
+		t.Errorf("Unmarshal = %+v", app)
+	}
+	if err := c.Unmarshal("missing", &app); err == nil {
+		t.Error("Unmarshal of missing key succeeded")
+	}
+
+	// All返回的是副本
+	all := c.All()
+	all["app"].(map[string]interface{})["port"] = 1
+	if port := c.GetInt("app.port"); port != 9090 {
+		t.Errorf("All modified config, app.port = %d", port)
+	}
+
+	core := NewCore()
+	core.SetMode(TestMode)
+	core.SetConfig(c)
+	var got *Config
+	core.Get("/config", func(ctx *Context) error {
+		got = ctx.Config()
+		return nil
+	})
+	core.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/config", nil))
+	if got != c {
+		t.Error("Context.Config is not the core config")
+	}
+}
+
+func TestConfigWatch(t *testing.T) {
+	dir := newTestConfigDir(t)
+	c, err := NewConfig(dir, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	changed := make(chan int, 10)
+	c.OnChange(func(c *Config) {
+		changed <- c.GetInt("app.port")
+	})
+	c.Watch(10 * time.Millisecond)
+
+	// 文件系统的时间精度可能比检查间隔粗，明确设置不同的修改时间
+	touch := func(name string, content string, offset time.Duration) {
+		writeConfigFiles(t, dir, map[string]string{name: content})
+		mtime := time.Now().Add(offset)
+		if err := os.Chtimes(filepath.Join(dir, name), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	touch("prod/app.yaml", "port: 9191\n", time.Hour)
+	select {
+	case port := <-changed:
+		if port != 9191 {
+			t.Fatalf("port after reload = %d", port)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnChange not called after file changed")
+	}
+
+	// 解析失败时保留原来的配置，不通知
+	touch("prod/app.yaml", "port: [\n", 2*time.Hour)
+	select {
+	case port := <-changed:
+		t.Fatalf("OnChange called for invalid file, port = %d", port)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if port := c.GetInt("app.port"); port != 9191 {
+		t.Fatalf("port after failed reload = %d", port)
+	}
+
+	// 新增的文件也会触发重新加载
+	touch("cache.yaml", "ttl: 1m\n", 0)
+	touch("prod/app.yaml", "port: 9292\n", 3*time.Hour)
+	// 两个文件可能在不同的检查中发现
+	for port := 0; port != 9292; {
+		select {
+		case port = <-changed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("OnChange not called after file fixed")
+		}
+	}
+	if ttl := c.GetDuration("cache.ttl"); ttl != time.Minute {
+		t.Fatalf("cache.ttl = %v", ttl)
+	}
+}
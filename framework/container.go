@@ -0,0 +1,336 @@
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrServiceNotBound 服务没有绑定
+var ErrServiceNotBound = errors.New("service not bound")
+
+// NewInstance 创建服务实例的方法，params为服务提供者Params返回的参数
+type NewInstance func(params ...interface{}) (interface{}, error)
+
+// Lifetime 服务实例的生命周期
+type Lifetime int
+
+const (
+	// LifetimeSingleton 整个应用共用一个实例
+	LifetimeSingleton Lifetime = iota
+	// LifetimeRequest 每个请求一个实例，只能通过Context获取
+	LifetimeRequest
+)
+
+// ServiceProvider 服务提供者，负责注册服务的创建方法
+type ServiceProvider interface {
+	// Name 服务的名称，例如 app.db
+	Name() string
+	// Register 返回创建服务实例的方法，c用于获取依赖的服务
+	Register(c Container) NewInstance
+	// Params 创建服务实例时传递给NewInstance的参数
+	Params(c Container) []interface{}
+	// IsDefer 是否延迟到第一次获取时才创建实例，为false时绑定后立即创建，只对单例有效
+	IsDefer() bool
+	// Boot 创建第一个实例之前调用一次，可以做一些初始化工作，出错时不会创建实例
+	Boot(c Container) error
+}
+
+// LifetimeProvider 可选接口，服务提供者实现后可以指定实例的生命周期，默认为单例
+type LifetimeProvider interface {
+	Lifetime() Lifetime
+}
+
+// Container 服务容器，绑定服务提供者并按名称获取服务实例
+type Container interface {
+	// Bind 绑定服务提供者，同名的服务提供者会被替换，已经创建的单例也会被丢弃，方便在测试中替换服务
+	Bind(provider ServiceProvider) error
+	// IsBind 服务是否已经绑定
+	IsBind(key string) bool
+	// Make 获取服务实例
+	Make(key string) (interface{}, error)
+	// MustMake 获取服务实例，出错时panic
+	MustMake(key string) interface{}
+	// MakeNew 使用指定的参数创建新的实例，不会缓存，params为nil时使用服务提供者的参数
+	MakeNew(key string, params []interface{}) (interface{}, error)
+}
+
+// binding 一个绑定的服务提供者
+type binding struct {
+	provider ServiceProvider
+	lifetime Lifetime
+	bootOnce sync.Once
+	bootErr  error
+	makeMu   sync.Mutex // 保证并发获取单例时只创建一次
+}
+
+// boot 执行服务提供者的Boot，只执行一次
+func (b *binding) boot(c Container) error {
+	b.bootOnce.Do(func() {
+		b.bootErr = b.provider.Boot(c)
+	})
+	return b.bootErr
+}
+
+// AxisContainer 服务容器的默认实现
+type AxisContainer struct {
+	lock      sync.RWMutex
+	bindings  map[string]*binding
+	instances map[string]interface{} // 已经创建的单例
+}
+
+// NewAxisContainer 初始化服务容器
+func NewAxisContainer() *AxisContainer {
+	return &AxisContainer{
+		bindings:  map[string]*binding{},
+		instances: map[string]interface{}{},
+	}
+}
+
+// Bind 绑定服务提供者，不需要延迟创建的单例会立即创建
+func (c *AxisContainer) Bind(provider ServiceProvider) error {
+	key := provider.Name()
+	b := &binding{provider: provider}
+	if lp, ok := provider.(LifetimeProvider); ok {
+		b.lifetime = lp.Lifetime()
+	}
+	c.lock.Lock()
+	c.bindings[key] = b
+	delete(c.instances, key)
+	c.lock.Unlock()
+
+	if b.lifetime == LifetimeSingleton && !provider.IsDefer() {
+		if _, err := c.Make(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsBind 服务是否已经绑定
+func (c *AxisContainer) IsBind(key string) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	_, ok := c.bindings[key]
+	return ok
+}
+
+// Make 获取单例服务，不能获取每个请求一个实例的服务
+func (c *AxisContainer) Make(key string) (interface{}, error) {
+	return (&resolver{container: c}).Make(key)
+}
+
+// MustMake 获取单例服务，出错时panic
+func (c *AxisContainer) MustMake(key string) interface{} {
+	return (&resolver{container: c}).MustMake(key)
+}
+
+// MakeNew 使用指定的参数创建新的实例
+func (c *AxisContainer) MakeNew(key string, params []interface{}) (interface{}, error) {
+	return (&resolver{container: c}).MakeNew(key, params)
+}
+
+// findBinding 查找绑定
+func (c *AxisContainer) findBinding(key string) (*binding, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	b, ok := c.bindings[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrServiceNotBound, key)
+	}
+	return b, nil
+}
+
+// instance 已经创建的单例
+func (c *AxisContainer) instance(key string) (interface{}, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	instance, ok := c.instances[key]
+	return instance, ok
+}
+
+// resolver 一次服务获取过程，记录正在创建的服务用于检测循环依赖
+// 服务提供者的Register、Params和Boot拿到的Container是resolver，通过它获取的依赖和当前服务在同一个请求中
+type resolver struct {
+	container *AxisContainer
+	ctx       *Context // 每个请求一个实例的服务保存在ctx中，获取单例时为nil
+	chain     []string // 正在创建的服务
+}
+
+func (r *resolver) Bind(provider ServiceProvider) error {
+	return r.container.Bind(provider)
+}
+
+func (r *resolver) IsBind(key string) bool {
+	return r.container.IsBind(key)
+}
+
+func (r *resolver) MustMake(key string) interface{} {
+	instance, err := r.Make(key)
+	if err != nil {
+		panic(err)
+	}
+	return instance
+}
+
+func (r *resolver) Make(key string) (interface{}, error) {
+	b, err := r.check(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.lifetime == LifetimeRequest {
+		if r.ctx == nil {
+			return nil, r.errorf(key, errors.New("request scoped service can only be made from a request context"))
+		}
+		return r.ctx.makeScoped(key, func() (interface{}, error) {
+			return r.create(key, b, nil)
+		})
+	}
+
+	c := r.container
+	if instance, ok := c.instance(key); ok {
+		return instance, nil
+	}
+	// 创建时不持有容器的锁，依赖的服务可以继续获取，同一个绑定的创建串行执行
+	b.makeMu.Lock()
+	defer b.makeMu.Unlock()
+	if instance, ok := c.instance(key); ok {
+		return instance, nil
+	}
+	// 单例和它的依赖不能是某一个请求中的实例
+	instance, err := (&resolver{container: c, chain: r.chain}).create(key, b, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	// 绑定已经被替换时不保存
+	if c.bindings[key] == b {
+		c.instances[key] = instance
+	}
+	return instance, nil
+}
+
+func (r *resolver) MakeNew(key string, params []interface{}) (interface{}, error) {
+	b, err := r.check(key)
+	if err != nil {
+		return nil, err
+	}
+	return r.create(key, b, params)
+}
+
+// check 查找绑定并检测循环依赖
+func (r *resolver) check(key string) (*binding, error) {
+	for _, name := range r.chain {
+		if name == key {
+			return nil, r.errorf(key, errors.New("circular dependency"))
+		}
+	}
+	b, err := r.container.findBinding(key)
+	if err != nil && len(r.chain) > 0 {
+		return nil, r.errorf(key, err)
+	}
+	return b, err
+}
+
+// create 依次执行Boot、Register和Params，然后创建实例
+func (r *resolver) create(key string, b *binding, params []interface{}) (interface{}, error) {
+	next := &resolver{container: r.container, ctx: r.ctx, chain: append(r.chain[:len(r.chain):len(r.chain)], key)}
+	if err := b.boot(next); err != nil {
+		return nil, r.errorf(key, fmt.Errorf("boot: %w", err))
+	}
+	newInstance := b.provider.Register(next)
+	if newInstance == nil {
+		return nil, r.errorf(key, errors.New("provider registered nil constructor"))
+	}
+	if params == nil {
+		params = b.provider.Params(next)
+	}
+	instance, err := newInstance(params...)
+	if err != nil {
+		return nil, r.errorf(key, err)
+	}
+	return instance, nil
+}
+
+// errorf 生成带依赖链的错误，依赖的服务返回的错误已经带有完整的依赖链，直接返回
+func (r *resolver) errorf(key string, err error) error {
+	var me *makeError
+	if errors.As(err, &me) {
+		return err
+	}
+	return &makeError{path: append(r.chain[:len(r.chain):len(r.chain)], key), err: err}
+}
+
+// makeError 获取服务失败的错误，path为从最外层服务到出错服务的依赖链
+type makeError struct {
+	path []string
+	err  error
+}
+
+func (e *makeError) Error() string {
+	return fmt.Sprintf("make service %s: %v", strings.Join(e.path, " -> "), e.err)
+}
+
+func (e *makeError) Unwrap() error {
+	return e.err
+}
+
+// Container 获取core的服务容器
+func (c *Core) Container() Container {
+	return c.container
+}
+
+// Bind 在core的服务容器中绑定服务提供者
+func (c *Core) Bind(provider ServiceProvider) error {
+	return c.container.Bind(provider)
+}
+
+// makeScoped 获取当前请求中的实例，没有时创建
+func (ctx *Context) makeScoped(key string, create func() (interface{}, error)) (interface{}, error) {
+	ctx.servicesMu.Lock()
+	instance, ok := ctx.services[key]
+	ctx.servicesMu.Unlock()
+	if ok {
+		return instance, nil
+	}
+	instance, err := create()
+	if err != nil {
+		return nil, err
+	}
+	ctx.servicesMu.Lock()
+	defer ctx.servicesMu.Unlock()
+	if old, ok := ctx.services[key]; ok {
+		return old, nil
+	}
+	if ctx.services == nil {
+		ctx.services = map[string]interface{}{}
+	}
+	ctx.services[key] = instance
+	return instance, nil
+}
+
+// resolver 当前请求的服务获取
+func (ctx *Context) resolver() *resolver {
+	if ctx.core == nil {
+		return &resolver{container: NewAxisContainer(), ctx: ctx}
+	}
+	return &resolver{container: ctx.core.container, ctx: ctx}
+}
+
+// Make 获取服务实例，每个请求一个实例的服务在同一个请求中只创建一次
+func (ctx *Context) Make(key string) (interface{}, error) {
+	return ctx.resolver().Make(key)
+}
+
+// MustMake 获取服务实例，出错时panic
+func (ctx *Context) MustMake(key string) interface{} {
+	return ctx.resolver().MustMake(key)
+}
+
+// MakeNew 使用指定的参数创建新的实例
+func (ctx *Context) MakeNew(key string, params []interface{}) (interface{}, error) {
+	return ctx.resolver().MakeNew(key, params)
+}
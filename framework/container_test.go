@@ -0,0 +1,280 @@
+package framework
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testService 测试服务的实例
+type testService struct {
+	name   string
+	params []interface{}
+	deps   []interface{}
+}
+
+// testProvider 测试用的服务提供者，deps为创建实例时依赖的服务
+type testProvider struct {
+	name     string
+	lazy     bool
+	lifetime Lifetime
+	deps     []string
+	params   []interface{}
+	delay    time.Duration // 创建实例的耗时，用于并发测试
+	bootErr  error
+
+	boots   atomic.Int32
+	creates atomic.Int32
+}
+
+func (p *testProvider) Name() string { return p.name }
+
+func (p *testProvider) Register(c Container) NewInstance {
+	return func(params ...interface{}) (interface{}, error) {
+		p.creates.Add(1)
+		time.Sleep(p.delay)
+		s := &testService{name: p.name, params: params}
+		for _, dep := range p.deps {
+			instance, err := c.Make(dep)
+			if err != nil {
+				return nil, err
+			}
+			s.deps = append(s.deps, instance)
+		}
+		return s, nil
+	}
+}
+
+func (p *testProvider) Params(Container) []interface{} { return p.params }
+
+func (p *testProvider) IsDefer() bool { return p.lazy }
+
+func (p *testProvider) Boot(Container) error {
+	p.boots.Add(1)
+	return p.bootErr
+}
+
+func (p *testProvider) Lifetime() Lifetime { return p.lifetime }
+
+func TestContainerBind(t *testing.T) {
+	c := NewAxisContainer()
+	if _, err := c.Make("app.missing"); !errors.Is(err, ErrServiceNotBound) {
+		t.Fatalf("Make unbound = %v", err)
+	}
+
+	eager := &testProvider{name: "app.eager"}
+	if err := c.Bind(eager); err != nil {
+		t.Fatal(err)
+	}
+	if !c.IsBind("app.eager") || c.IsBind("app.missing") {
+		t.Fatal("IsBind mismatch")
+	}
+	// 不延迟的单例在绑定时创建
+	if eager.creates.Load() != 1 {
+		t.Fatalf("eager singleton created %d times at bind", eager.creates.Load())
+	}
+
+	lazy := &testProvider{name: "app.lazy", lazy: true, params: []interface{}{"dsn"}}
+	if err := c.Bind(lazy); err != nil {
+		t.Fatal(err)
+	}
+	if lazy.creates.Load() != 0 || lazy.boots.Load() != 0 {
+		t.Fatal("deferred singleton created at bind")
+	}
+	first := c.MustMake("app.lazy").(*testService)
+	second := c.MustMake("app.lazy").(*testService)
+	if first != second || lazy.creates.Load() != 1 || lazy.boots.Load() != 1 {
+		t.Fatalf("singleton created %d times, booted %d times", lazy.creates.Load(), lazy.boots.Load())
+	}
+	if len(first.params) != 1 || first.params[0] != "dsn" {
+		t.Fatalf("params = %v", first.params)
+	}
+
+	// 重新绑定后丢弃已经创建的单例
+	replaced := &testProvider{name: "app.lazy", lazy: true}
+	if err := c.Bind(replaced); err != nil {
+		t.Fatal(err)
+	}
+	if c.MustMake("app.lazy") == first || replaced.creates.Load() != 1 {
+		t.Fatal("rebinding kept the old instance")
+	}
+
+	// Boot出错时不创建实例，绑定不延迟时Bind返回错误
+	bootErr := errors.New("no database")
+	broken := &testProvider{name: "app.broken", bootErr: bootErr}
+	if err := c.Bind(broken); !errors.Is(err, bootErr) {
+		t.Fatalf("Bind with boot error = %v", err)
+	}
+	if _, err := c.Make("app.broken"); !errors.Is(err, bootErr) {
+		t.Fatalf("Make with boot error = %v", err)
+	}
+	if broken.creates.Load() != 0 || broken.boots.Load() != 1 {
+		t.Fatalf("broken provider created %d times, booted %d times", broken.creates.Load(), broken.boots.Load())
+	}
+}
+
+func TestContainerSingletonConcurrent(t *testing.T) {
+	c := NewAxisContainer()
+	db := &testProvider{name: "app.db", lazy: true, delay: 20 * time.Millisecond}
+	repo := &testProvider{name: "app.repo", lazy: true, deps: []string{"app.db"}, delay: 20 * time.Millisecond}
+	for _, p := range []ServiceProvider{db, repo} {
+		if err := c.Bind(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const n = 20
+	instances := make([]interface{}, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := "app.db"
+			if i%2 == 0 {
+				key = "app.repo"
+			}
+			instances[i] = c.MustMake(key)
+		}(i)
+	}
+	wg.Wait()
+
+	if db.creates.Load() != 1 || repo.creates.Load() != 1 {
+		t.Fatalf("created db %d times, repo %d times", db.creates.Load(), repo.creates.Load())
+	}
+	dbInstance := c.MustMake("app.db")
+	for i, instance := range instances {
+		if i%2 == 0 {
+			if instance.(*testService).deps[0] != dbInstance {
+				t.Fatalf("repo %d uses another db instance", i)
+			}
+		} else if instance != dbInstance {
+			t.Fatalf("db %d is another instance", i)
+		}
+	}
+}
+
+func TestContainerMakeNew(t *testing.T) {
+	c := NewAxisContainer()
+	p := &testProvider{name: "app.client", lazy: true, params: []interface{}{"default"}}
+	if err := c.Bind(p); err != nil {
+		t.Fatal(err)
+	}
+	singleton := c.MustMake("app.client")
+
+	first, err := c.MakeNew("app.client", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.MakeNew("app.client", []interface{}{"custom"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == singleton || second == singleton || first == second {
+		t.Fatal("MakeNew returned a cached instance")
+	}
+	if params := first.(*testService).params; len(params) != 1 || params[0] != "default" {
+		t.Fatalf("nil params = %v, want provider params", params)
+	}
+	if params := second.(*testService).params; len(params) != 1 || params[0] != "custom" {
+		t.Fatalf("params = %v", params)
+	}
+	// Boot只执行一次
+	if p.boots.Load() != 1 || c.MustMake("app.client") != singleton {
+		t.Fatalf("booted %d times", p.boots.Load())
+	}
+}
+
+func TestContainerRequestLifetime(t *testing.T) {
+	core := NewCore()
+	core.SetMode(TestMode)
+	scoped := &testProvider{name: "app.tx", lifetime: LifetimeRequest, deps: []string{"app.db"}}
+	db := &testProvider{name: "app.db", lazy: true}
+	// 单例不能依赖请求中的实例
+	cache := &testProvider{name: "app.cache", lazy: true, deps: []string{"app.tx"}}
+	for _, p := range []ServiceProvider{scoped, db, cache} {
+		if err := core.Bind(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var instances []interface{}
+	var cacheErr error
+	core.Get("/tx", func(c *Context) error {
+		first := c.MustMake("app.tx")
+		if c.MustMake("app.tx") != first {
+			t.Error("request scoped service created twice in one request")
+		}
+		instances = append(instances, first)
+		_, cacheErr = c.Make("app.cache")
+		return nil
+	})
+	for i := 0; i < 2; i++ {
+		core.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/tx", nil))
+	}
+
+	if len(instances) != 2 || instances[0] == instances[1] {
+		t.Fatalf("request scoped instances = %v", instances)
+	}
+	if scoped.creates.Load() != 2 || scoped.boots.Load() != 1 {
+		t.Fatalf("scoped created %d times, booted %d times", scoped.creates.Load(), scoped.boots.Load())
+	}
+	// 依赖的单例在请求之间共用
+	dbInstance := core.Container().MustMake("app.db")
+	for _, instance := range instances {
+		if instance.(*testService).deps[0] != dbInstance {
+			t.Fatal("request scoped service got another db instance")
+		}
+	}
+	if cacheErr == nil || !strings.Contains(cacheErr.Error(), "app.cache -> app.tx") {
+		t.Fatalf("singleton depending on request service = %v", cacheErr)
+	}
+	if _, err := core.Container().Make("app.tx"); err == nil {
+		t.Fatal("request scoped service made outside a request")
+	}
+}
+
+func TestContainerDependencyErrors(t *testing.T) {
+	c := NewAxisContainer()
+	for _, p := range []ServiceProvider{
+		&testProvider{name: "a", lazy: true, deps: []string{"b"}},
+		&testProvider{name: "b", lazy: true, deps: []string{"c"}},
+		&testProvider{name: "c", lazy: true, deps: []string{"a"}},
+		&testProvider{name: "self", lazy: true, deps: []string{"self"}},
+		&testProvider{name: "orphan", lazy: true, deps: []string{"missing"}},
+	} {
+		if err := c.Bind(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "a", want: "make service a -> b -> c -> a: circular dependency"},
+		{key: "b", want: "make service b -> c -> a -> b: circular dependency"},
+		{key: "self", want: "make service self -> self: circular dependency"},
+		{key: "orphan", want: "make service orphan -> missing: service not bound: missing"},
+	}
+	for _, tt := range tests {
+		_, err := c.Make(tt.key)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Make(%s) = %v, want %s", tt.key, err, tt.want)
+		}
+	}
+	if _, err := c.Make("orphan"); !errors.Is(err, ErrServiceNotBound) {
+		t.Errorf("missing dependency error = %v, want ErrServiceNotBound", err)
+	}
+	// 出错时不保存实例，修复依赖后可以创建
+	if err := c.Bind(&testProvider{name: "missing", lazy: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Make("orphan"); err != nil {
+		t.Fatalf("Make after binding dependency = %v", err)
+	}
+}
@@ -15,7 +15,7 @@ import (
 // Context 自定义 Context
 type Context struct {
 	request        *http.Request
-	responseWriter http.ResponseWriter
+	responseWriter *responseWriter
 	ctx            context.Context
 	handler        ControllerHandler
 
@@ -32,12 +32,29 @@ type Context struct {
 	index int
 
 	params map[string]string // url路由匹配的参数
+	route  string            // 匹配到的路由规则
+
+	core *Core // 处理当前请求的core
+
+	errors []error // 处理过程中记录的错误
+
+	services   map[string]interface{} // 当前请求中创建的服务实例
+	servicesMu sync.Mutex
+
+	logger   Logger // 当前请求的日志，第一次使用时创建
+	loggerMu sync.Mutex
+
+	requestID string           // 请求id，由RequestID中间件设置
+	inflight  *InflightRequest // 正在处理的请求的记录
+
+	timings  []TimingMetric // 记录的处理阶段耗时，按名称汇总
+	timingMu sync.Mutex
 }
 
 func NewContext(r *http.Request, w http.ResponseWriter) *Context {
 	return &Context{
 		request:        r,
-		responseWriter: w,
+		responseWriter: newResponseWriter(w),
 		ctx:            r.Context(),
 		writerMux:      &sync.Mutex{},
 		index:          -1,
@@ -77,6 +94,11 @@ func (ctx *Context) BaseContext() context.Context {
 	return ctx.request.Context()
 }
 
+// SetBaseContext 替换请求的context，例如加入trace信息，之后的BaseContext和Value使用新的context
+func (ctx *Context) SetBaseContext(c context.Context) {
+	ctx.request = ctx.request.WithContext(c)
+}
+
 func (ctx *Context) Deadline() (deadline time.Time, ok bool) {
 	return ctx.BaseContext().Deadline()
 }
@@ -236,3 +258,22 @@ func (ctx *Context) Next() error {
 func (ctx *Context) SetParams(params map[string]string) {
 	ctx.params = params
 }
+
+// AddError 记录处理过程中的错误，请求结束后交给core的错误处理函数
+func (ctx *Context) AddError(err error) {
+	if err != nil {
+		ctx.errors = append(ctx.errors, err)
+	}
+}
+
+// Errors 获取处理过程中记录的所有错误
+func (ctx *Context) Errors() []error {
+	return ctx.errors
+}
+
+func (ctx *Context) lastError() error {
+	if len(ctx.errors) == 0 {
+		return nil
+	}
+	return ctx.errors[len(ctx.errors)-1]
+}
@@ -1,9 +1,12 @@
 package framework
 
 import (
+	"context"
+	"encoding/json"
 	"log"
 	"net/http"
 	"strings"
+	"sync"
 )
 
 // Core 框架核心结构
@@ -18,16 +21,106 @@ type Core struct {
 
 	// 从core这边设置的中间件
 	middlewares []ControllerHandler
+
+	// 内容协商可选的输出格式
+	renderers []renderer
+
+	// html模版引擎
+	html *HTMLEngine
+
+	// 请求处理出错时的统一处理函数
+	errorHandler ErrorHandler
+
+	// 正在处理的请求
+	inflight *InflightTracker
+
+	// 配置服务
+	config *Config
+
+	// 服务容器
+	container *AxisContainer
+
+	// 运行模式，为空时使用全局模式
+	mode string
+
+	// 日志，logger为空时使用运行模式对应的日志
+	logger     Logger
+	modeLogger *AxisLogger
+
+	// 指标注册表，第一次使用时创建
+	metrics     *MetricsRegistry
+	metricsOnce sync.Once
+
+	// 健康检查注册表，第一次使用时创建
+	health     *HealthRegistry
+	healthOnce sync.Once
+
+	// release模式下是否注册调试接口
+	debugEndpoints bool
+
+	// 停止Admin启动的慢请求检查
+	adminMu       sync.Mutex
+	stopSlowWatch context.CancelFunc
 }
 
+// ErrorHandler 统一处理请求中出现的错误
+type ErrorHandler func(c *Context, err error)
+
 // NewCore 初始化对象Core
 func NewCore() *Core {
-	methods := []string{"GET", "POST", "PUT", "DELETE"}
+	methods := []string{"GET", "HEAD", "POST", "PUT", "DELETE"}
 	router := make(map[string]*Tree)
 	for _, method := range methods {
 		router[method] = NewTree()
 	}
-	return &Core{router: router}
+	return &Core{
+		router:       router,
+		renderers:    defaultRenderers(),
+		html:         NewHTMLEngine().Reload(IsDebugging()),
+		errorHandler: defaultErrorHandler,
+		inflight:     NewInflightTracker(),
+		container:    NewAxisContainer(),
+	}
+}
+
+// defaultErrorHandler 默认的错误处理，记录错误并返回500，debug模式下返回错误详情，已经输出响应时只记录错误
+func defaultErrorHandler(c *Context, err error) {
+	c.Logger().Error("request error", "method", c.Method(), "path", c.GetRequest().URL.Path, "error", err)
+	if c.Written() {
+		// 已经输出了部分响应，例如处理中途panic，不能再修改状态码
+		return
+	}
+	body := []byte(`"inner error"`)
+	if c.Mode() == DebugMode {
+		detail := map[string]interface{}{
+			"error":  "inner error",
+			"detail": err.Error(),
+			"route":  c.route,
+		}
+		if len(c.errors) > 1 {
+			all := make([]string, 0, len(c.errors))
+			for _, e := range c.errors {
+				all = append(all, e.Error())
+			}
+			detail["errors"] = all
+		}
+		body, _ = json.MarshalIndent(detail, "", "  ")
+	}
+	c.responseWriter.Header().Set("Content-Type", "application/json")
+	c.responseWriter.WriteHeader(http.StatusInternalServerError)
+	c.responseWriter.Write(body)
+}
+
+// notFoundHandler 没有匹配到路由时返回404
+func notFoundHandler(c *Context) error {
+	c.SetHeader("Content-Type", "application/json")
+	c.SetStatus(http.StatusNotFound).Json("not found")
+	return nil
+}
+
+// SetErrorHandler 设置统一的错误处理函数
+func (c *Core) SetErrorHandler(handler ErrorHandler) {
+	c.errorHandler = handler
 }
 
 // Use 注册中间件
@@ -35,38 +128,52 @@ func (c *Core) Use(middlewares ...ControllerHandler) {
 	c.middlewares = append(c.middlewares, middlewares...)
 }
 
-// Get GET方法路由注册
-func (c *Core) Get(url string, handlers ...ControllerHandler) {
-	//大小写不敏感，增加用户容错
-	upperUrl := strings.ToUpper(url)
-	allHandlers := append(c.middlewares, handlers...)
-	if err := c.router["GET"].AddRouter(upperUrl, allHandlers); err != nil {
+// addRoute 注册路由，core上设置的中间件会加在handlers之前
+func (c *Core) addRoute(method string, url string, handlers []ControllerHandler) {
+	allHandlers := make([]ControllerHandler, 0, len(c.middlewares)+len(handlers))
+	allHandlers = append(allHandlers, c.middlewares...)
+	allHandlers = append(allHandlers, handlers...)
+	// 前缀树内部会把静态segment转为大写，保证大小写不敏感，路由参数名保持原样
+	if err := c.router[method].AddRouter(url, allHandlers); err != nil {
 		log.Fatal("add router error: ", err)
 	}
+	c.debugPrintRoute(method, url, allHandlers)
+}
+
+// addRawRoute 同时注册GET和HEAD路由，不经过core上设置的中间件，用于框架内置的指标、健康检查等接口
+func (c *Core) addRawRoute(url string, handlers []ControllerHandler) error {
+	for _, method := range []string{"GET", "HEAD"} {
+		if err := c.router[method].AddRouter(url, handlers); err != nil {
+			return err
+		}
+		c.debugPrintRoute(method, url, handlers)
+	}
+	return nil
+}
+
+// Get GET方法路由注册
+func (c *Core) Get(url string, handlers ...ControllerHandler) {
+	c.addRoute("GET", url, handlers)
+}
+
+// Head HEAD方法路由注册
+func (c *Core) Head(url string, handlers ...ControllerHandler) {
+	c.addRoute("HEAD", url, handlers)
 }
 
 // Post POST方法路由注册
 func (c *Core) Post(url string, handlers ...ControllerHandler) {
-	upperUrl := strings.ToUpper(url)
-	if err := c.router["POST"].AddRouter(upperUrl, handlers); err != nil {
-		log.Fatal("add router error: ", err)
-	}
+	c.addRoute("POST", url, handlers)
 }
 
 // Put PUT方法路由注册
 func (c *Core) Put(url string, handlers ...ControllerHandler) {
-	upperUrl := strings.ToUpper(url)
-	if err := c.router["PUT"].AddRouter(upperUrl, handlers); err != nil {
-		log.Fatal("add router error: ", err)
-	}
+	c.addRoute("PUT", url, handlers)
 }
 
 // Delete DELETE方法路由注册
 func (c *Core) Delete(url string, handlers ...ControllerHandler) {
-	upperUrl := strings.ToUpper(url)
-	if err := c.router["DELETE"].AddRouter(upperUrl, handlers); err != nil {
-		log.Fatal("add router error: ", err)
-	}
+	c.addRoute("DELETE", url, handlers)
 }
 
 func (c *Core) Group(prefix string) IGroup {
@@ -107,6 +214,13 @@ func (c *Core) ServeHTTP(response http.ResponseWriter, request *http.Request) {
 
 	// 封装自定义context
 	ctx := NewContext(request, response)
+	ctx.core = c
+
+	// 记录正在处理的请求，优雅关闭时等待
+	inflight := c.inflight.begin(ctx)
+	defer c.inflight.end(inflight)
+	defer c.inflight.labelGoroutine(inflight)()
+	ctx.inflight = inflight
 
 	// 寻找路由
 	//handlers := c.FindRouteByRequest(request)
@@ -119,19 +233,31 @@ func (c *Core) ServeHTTP(response http.ResponseWriter, request *http.Request) {
 	// 寻找路由
 	noder := c.FindRouteNodeByRequest(request)
 	if noder == nil {
-		// 如果没有找到，这里打印日志
-		ctx.Json("not found")
-		return
-	}
+		// 没有匹配到路由时仍然经过core上的中间件，访问日志和指标会记录这些请求，路由规则为空
+		handlers := make([]ControllerHandler, 0, len(c.middlewares)+1)
+		handlers = append(handlers, c.middlewares...)
+		ctx.SetHandlers(append(handlers, notFoundHandler))
+	} else {
+		ctx.route = noder.pattern
+		c.inflight.setRoute(inflight, noder.pattern)
 
-	// 设置路由参数
-	params := noder.parseParamsFromEndNode(request.URL.Path)
-	ctx.SetParams(params)
-	ctx.SetHandlers(noder.handlers)
+		// 设置路由参数
+		params := noder.parseParamsFromEndNode(request.URL.Path)
+		ctx.SetParams(params)
+		ctx.SetHandlers(noder.handlers)
+	}
 
 	// 调用路由函数，如果返回err 代表存在内部错误，返回500状态码
-	if err := ctx.Next(); err != nil {
-		ctx.Json("inner error")
-		return
+	err := ctx.Next()
+	if err == nil {
+		// 输出过程中记录的错误，例如模版渲染失败
+		err = ctx.lastError()
+	}
+	if err != nil {
+		c.errorHandler(ctx, err)
+	}
+	// 没有写入响应时net/http在返回之后才写入200，这里先执行BeforeWriteHeader注册的函数
+	if !ctx.Written() {
+		ctx.responseWriter.runBeforeWrite()
 	}
 }
@@ -0,0 +1,191 @@
+package framework
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WrapHandler 把http.Handler转换为处理函数，例如挂载net/http中的handler
+func WrapHandler(h http.Handler) ControllerHandler {
+	return func(c *Context) error {
+		h.ServeHTTP(c.responseWriter, c.request)
+		return nil
+	}
+}
+
+// RouteInfo 一条注册的路由
+type RouteInfo struct {
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	Handler  string `json:"handler"`  // 最后一个处理函数的名称
+	Handlers int    `json:"handlers"` // 包含中间件的处理函数个数
+}
+
+// Routes 所有注册的路由，按路径和方法排序
+func (c *Core) Routes() []RouteInfo {
+	var routes []RouteInfo
+	for method, tree := range c.router {
+		tree.root.walk(func(n *node) {
+			if !n.isLast || len(n.handlers) == 0 {
+				return
+			}
+			routes = append(routes, RouteInfo{
+				Method:   method,
+				Path:     n.pattern,
+				Handler:  nameOfFunction(n.handlers[len(n.handlers)-1]),
+				Handlers: len(n.handlers),
+			})
+		})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// walk 深度优先遍历节点
+func (n *node) walk(fn func(n *node)) {
+	fn(n)
+	for _, child := range n.childs {
+		child.walk(fn)
+	}
+}
+
+// SetDebugEndpoints 设置release模式下Debug是否也注册调试接口，默认不注册
+func (c *Core) SetDebugEndpoints(enabled bool) {
+	c.debugEndpoints = enabled
+}
+
+// Debug 在group下注册调试接口，group需要自己加上鉴权中间件
+// release模式下只有调用过SetDebugEndpoints(true)才会注册，返回是否注册
+//
+//	/pprof/          pprof首页和各个profile，可以使用go tool pprof读取
+//	/vars            expvar变量，包括memstats和命令行参数
+//	/runtime         goroutine数、GC、内存等运行时信息
+//	/routes          路由表
+//	/config          当前配置，密码等敏感字段会被隐藏
+func (c *Core) Debug(group IGroup) bool {
+	if c.Mode() == ReleaseMode && !c.debugEndpoints {
+		return false
+	}
+	group.Get("/pprof", func(ctx *Context) error {
+		// 没有结尾的斜杠时首页中profile的相对链接会出错，重定向到/pprof/
+		http.Redirect(ctx.responseWriter, ctx.request, ctx.request.URL.Path+"/", http.StatusMovedPermanently)
+		return nil
+	})
+	group.Get("/pprof/cmdline", WrapHandler(http.HandlerFunc(pprof.Cmdline)))
+	group.Get("/pprof/profile", WrapHandler(http.HandlerFunc(pprof.Profile)))
+	group.Get("/pprof/symbol", WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	group.Post("/pprof/symbol", WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	group.Get("/pprof/trace", WrapHandler(http.HandlerFunc(pprof.Trace)))
+	group.Get("/pprof/*name", func(ctx *Context) error {
+		// pprof.Index只在/debug/pprof/路径下处理profile名称，这里自己分发
+		name, _ := ctx.ParamString("name", "")
+		if name == "" {
+			pprof.Index(ctx.responseWriter, ctx.request)
+			return nil
+		}
+		pprof.Handler(name).ServeHTTP(ctx.responseWriter, ctx.request)
+		return nil
+	})
+	group.Get("/vars", WrapHandler(expvar.Handler()))
+	group.Get("/runtime", func(ctx *Context) error {
+		return writeDebugJSON(ctx, c.runtimeStats())
+	})
+	group.Get("/routes", func(ctx *Context) error {
+		return writeDebugJSON(ctx, c.Routes())
+	})
+	group.Get("/config", func(ctx *Context) error {
+		if c.config == nil {
+			return writeDebugJSON(ctx, map[string]interface{}{})
+		}
+		return writeDebugJSON(ctx, redactConfig(c.config.All()))
+	})
+	return true
+}
+
+// 进程启动时间，用于输出运行时长
+var processStart = time.Now()
+
+// runtimeStats 运行时信息
+func (c *Core) runtimeStats() map[string]interface{} {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return map[string]interface{}{
+		"go_version":     runtime.Version(),
+		"mode":           c.Mode(),
+		"uptime":         time.Since(processStart).String(),
+		"num_cpu":        runtime.NumCPU(),
+		"gomaxprocs":     runtime.GOMAXPROCS(0),
+		"goroutines":     runtime.NumGoroutine(),
+		"inflight":       c.inflight.Count(),
+		"heap_alloc":     ms.HeapAlloc,
+		"heap_inuse":     ms.HeapInuse,
+		"heap_objects":   ms.HeapObjects,
+		"sys":            ms.Sys,
+		"num_gc":         ms.NumGC,
+		"pause_total_ns": ms.PauseTotalNs,
+		"last_gc":        time.Unix(0, int64(ms.LastGC)).Format(time.RFC3339Nano),
+	}
+}
+
+func writeDebugJSON(ctx *Context, obj interface{}) error {
+	body, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return err
+	}
+	header := ctx.responseWriter.Header()
+	header.Set("Content-Type", "application/json")
+	header.Set("Cache-Control", "no-store")
+	ctx.responseWriter.Write(body)
+	return nil
+}
+
+// 配置中包含这些字符串的key在调试接口中隐藏
+var sensitiveConfigKeys = []string{"password", "passwd", "secret", "token", "credential", "api_key", "apikey", "private_key"}
+
+// redactConfig 隐藏敏感字段的值
+func redactConfig(data map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		lower := strings.ToLower(key)
+		sensitive := false
+		for _, s := range sensitiveConfigKeys {
+			if strings.Contains(lower, s) {
+				sensitive = true
+				break
+			}
+		}
+		if sensitive {
+			out[key] = "******"
+		} else {
+			out[key] = redactConfigValue(value)
+		}
+	}
+	return out
+}
+
+// redactConfigValue 递归处理map和数组中的敏感字段，例如数据库列表中每一项的password
+func redactConfigValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return redactConfig(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = redactConfigValue(item)
+		}
+		return out
+	default:
+		return value
+	}
+}
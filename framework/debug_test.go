@@ -0,0 +1,98 @@
+package framework
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestDebugPprofRoutes(t *testing.T) {
+	core := NewCore()
+	core.SetMode(TestMode)
+	if !core.Debug(core.Group("/debug")) {
+		t.Fatal("debug endpoints not registered")
+	}
+	tests := []struct {
+		path     string
+		status   int
+		location string
+	}{
+		{path: "/debug/pprof", status: http.StatusMovedPermanently, location: "/debug/pprof/"},
+		{path: "/debug/pprof/", status: http.StatusOK},
+		{path: "/debug/pprof/goroutine", status: http.StatusOK},
+		{path: "/debug/pprof/cmdline", status: http.StatusOK},
+		{path: "/debug/routes", status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		core.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+		if rec.Code != tt.status || rec.Header().Get("Location") != tt.location {
+			t.Errorf("GET %s: status = %d, location = %q; want %d, %q", tt.path, rec.Code, rec.Header().Get("Location"), tt.status, tt.location)
+		}
+	}
+}
+
+func TestDebugDisabledInRelease(t *testing.T) {
+	core := NewCore()
+	core.SetMode(ReleaseMode)
+	if core.Debug(core.Group("/debug")) {
+		t.Fatal("debug endpoints registered in release mode")
+	}
+	core.SetDebugEndpoints(true)
+	if !core.Debug(core.Group("/debug")) {
+		t.Fatal("SetDebugEndpoints(true) should register debug endpoints")
+	}
+}
+
+func TestRedactConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		in   map[string]interface{}
+		want map[string]interface{}
+	}{
+		{
+			name: "top level",
+			in:   map[string]interface{}{"port": 8080, "Password": "p", "api_key": "k"},
+			want: map[string]interface{}{"port": 8080, "Password": "******", "api_key": "******"},
+		},
+		{
+			name: "nested map",
+			in:   map[string]interface{}{"database": map[string]interface{}{"host": "db", "db_password": "p"}},
+			want: map[string]interface{}{"database": map[string]interface{}{"host": "db", "db_password": "******"}},
+		},
+		{
+			name: "sensitive map",
+			in:   map[string]interface{}{"secrets": map[string]interface{}{"a": "b"}},
+			want: map[string]interface{}{"secrets": "******"},
+		},
+		{
+			name: "maps in list",
+			in: map[string]interface{}{"replicas": []interface{}{
+				map[string]interface{}{"host": "r1", "password": "p1"},
+				map[string]interface{}{"host": "r2", "auth": map[string]interface{}{"token": "t"}},
+			}},
+			want: map[string]interface{}{"replicas": []interface{}{
+				map[string]interface{}{"host": "r1", "password": "******"},
+				map[string]interface{}{"host": "r2", "auth": map[string]interface{}{"token": "******"}},
+			}},
+		},
+		{
+			name: "nested lists",
+			in:   map[string]interface{}{"groups": []interface{}{[]interface{}{"a", map[string]interface{}{"secret": "s"}}}},
+			want: map[string]interface{}{"groups": []interface{}{[]interface{}{"a", map[string]interface{}{"secret": "******"}}}},
+		},
+	}
+	for _, tt := range tests {
+		if got := redactConfig(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: redactConfig = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// 不能修改原来的配置
+	in := map[string]interface{}{"list": []interface{}{map[string]interface{}{"password": "p"}}}
+	redactConfig(in)
+	if in["list"].([]interface{})[0].(map[string]interface{})["password"] != "p" {
+		t.Fatal("redactConfig modified its input")
+	}
+}
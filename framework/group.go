@@ -1,13 +1,25 @@
 package framework
 
+import "net/http"
+
 // IGroup 代表前缀分组
 type IGroup interface {
 	Get(string, ...ControllerHandler)
+	Head(string, ...ControllerHandler)
 	Post(string, ...ControllerHandler)
 	Put(string, ...ControllerHandler)
 	Delete(string, ...ControllerHandler)
 	Use(middlewares ...ControllerHandler)
 	Group(uri string) IGroup
+
+	// 静态文件服务
+	Static(prefix string, root string)
+	StaticFS(prefix string, fsys http.FileSystem)
+	StaticFile(url string, file string)
+	StaticWithConfig(prefix string, config StaticConfig)
+
+	// websocket
+	WebSocket(url string, config *WebSocketConfig, handler WebSocketHandler, middlewares ...ControllerHandler)
 }
 
 // Group 前缀匹配的具体实现者
@@ -38,28 +50,43 @@ func (g *Group) getMiddlewares() []ControllerHandler {
 		return g.middlewares
 	}
 
-	return append(g.parent.getMiddlewares(), g.middlewares...)
+	parent := g.parent.getMiddlewares()
+	middlewares := make([]ControllerHandler, 0, len(parent)+len(g.middlewares))
+	middlewares = append(middlewares, parent...)
+	return append(middlewares, g.middlewares...)
+}
+
+// combineHandlers 将group的middleware加在handlers之前
+func (g *Group) combineHandlers(handlers []ControllerHandler) []ControllerHandler {
+	middlewares := g.getMiddlewares()
+	allHandlers := make([]ControllerHandler, 0, len(middlewares)+len(handlers))
+	allHandlers = append(allHandlers, middlewares...)
+	return append(allHandlers, handlers...)
 }
 
 func (g *Group) Get(uri string, handlers ...ControllerHandler) {
 	uri = g.prefix + uri
-	allHandlers := append(g.getMiddlewares(), handlers...)
-	g.core.Get(uri, allHandlers...)
+	g.core.Get(uri, g.combineHandlers(handlers)...)
+}
+
+func (g *Group) Head(uri string, handlers ...ControllerHandler) {
+	uri = g.prefix + uri
+	g.core.Head(uri, g.combineHandlers(handlers)...)
 }
 
 func (g *Group) Post(uri string, handlers ...ControllerHandler) {
 	uri = g.prefix + uri
-	g.core.Post(uri, handlers...)
+	g.core.Post(uri, g.combineHandlers(handlers)...)
 }
 
 func (g *Group) Put(uri string, handlers ...ControllerHandler) {
 	uri = g.prefix + uri
-	g.core.Put(uri, handlers...)
+	g.core.Put(uri, g.combineHandlers(handlers)...)
 }
 
 func (g *Group) Delete(uri string, handler ...ControllerHandler) {
 	uri = g.prefix + uri
-	g.core.Delete(uri, handler...)
+	g.core.Delete(uri, g.combineHandlers(handler)...)
 }
 
 // Group 实现 Group 方法
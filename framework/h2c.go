@@ -0,0 +1,256 @@
+package framework
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// http/2的连接前言，见 RFC 9113 3.4
+const http2ClientPreface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+const (
+	http2FrameHeaders      = 0x1
+	http2FrameSettings     = 0x4
+	http2FrameContinuation = 0x9
+
+	http2FlagEndStream  = 0x1
+	http2FlagEndHeaders = 0x4
+
+	// 对端确认SETTINGS之前只能使用默认的最大帧大小
+	http2DefaultFrameSize = 16384
+)
+
+// h2c升级后不能转发给http/2的逐跳头部
+var h2cHopHeaders = []string{"Connection", "Upgrade", "Http2-Settings", "Keep-Alive", "Proxy-Connection", "Transfer-Encoding", "Te", "Host"}
+
+// h2cUpgradeHandler 处理 Upgrade: h2c 请求，升级后的连接交给http.Server按prior knowledge方式处理
+// 升级请求本身作为stream 1重新进入http/2的处理流程
+type h2cUpgradeHandler struct {
+	next  http.Handler
+	conns *connListener
+}
+
+func (h *h2cUpgradeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	settings, ok := h2cUpgradeSettings(r)
+	if !ok {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	upgraded, err := h2cUpgrade(conn, rw.Reader, r, settings)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	h.conns.push(upgraded)
+}
+
+// h2cUpgradeSettings 是否是可以升级的h2c请求，返回HTTP2-Settings头解码后的SETTINGS负载
+// 带请求体的请求忽略升级，继续按http/1.1处理，RFC允许服务端忽略Upgrade
+func h2cUpgradeSettings(r *http.Request) ([]byte, bool) {
+	if r.ProtoMajor != 1 || r.ContentLength != 0 || len(r.TransferEncoding) > 0 {
+		return nil, false
+	}
+	if !headerContainsToken(r.Header, "Upgrade", "h2c") ||
+		!headerContainsToken(r.Header, "Connection", "upgrade") ||
+		!headerContainsToken(r.Header, "Connection", "http2-settings") {
+		return nil, false
+	}
+	// 必须有且只有一个HTTP2-Settings头，见 RFC 7540 3.2.1
+	values := r.Header.Values("Http2-Settings")
+	if len(values) != 1 {
+		return nil, false
+	}
+	settings, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(values[0], "="))
+	if err != nil || len(settings)%6 != 0 {
+		return nil, false
+	}
+	return settings, true
+}
+
+// h2cUpgrade 回复101，读取客户端的连接前言和SETTINGS，然后在其后插入升级请求对应的HEADERS帧
+// upgradeSettings为HTTP2-Settings头中的设置，需要当作客户端的第一个SETTINGS帧处理
+func h2cUpgrade(conn net.Conn, reader io.Reader, r *http.Request, upgradeSettings []byte) (net.Conn, error) {
+	if _, err := io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: h2c\r\n\r\n"); err != nil {
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	defer conn.SetReadDeadline(time.Time{})
+	preface := make([]byte, len(http2ClientPreface)+9)
+	if _, err := io.ReadFull(reader, preface); err != nil {
+		return nil, err
+	}
+	if string(preface[:len(http2ClientPreface)]) != http2ClientPreface {
+		return nil, errors.New("h2c: bad client preface")
+	}
+	head := preface[len(http2ClientPreface):]
+	if head[3] != http2FrameSettings {
+		return nil, errors.New("h2c: client preface must be followed by SETTINGS")
+	}
+	if head[4]&0x1 != 0 || binary.BigEndian.Uint32(head[5:])&0x7fffffff != 0 {
+		return nil, errors.New("h2c: bad client SETTINGS frame")
+	}
+	settings := make([]byte, int(head[0])<<16|int(head[1])<<8|int(head[2]))
+	if _, err := io.ReadFull(reader, settings); err != nil {
+		return nil, err
+	}
+
+	// HTTP2-Settings中的设置不需要确认，客户端只会等待它发送的SETTINGS帧的ACK
+	// 所以把两者合并为一个SETTINGS帧，按顺序处理时客户端SETTINGS帧中的值覆盖升级时的值
+	merged := append(upgradeSettings, settings...)
+	if len(merged) > http2DefaultFrameSize {
+		return nil, errors.New("h2c: SETTINGS too large")
+	}
+	head[0], head[1], head[2] = byte(len(merged)>>16), byte(len(merged)>>8), byte(len(merged))
+
+	var buf bytes.Buffer
+	buf.Write(preface)
+	buf.Write(merged)
+	writeHeadersFrames(&buf, 1, h2cRequestHeaders(r))
+	return &h2cConn{Conn: conn, reader: io.MultiReader(&buf, reader)}, nil
+}
+
+// h2cRequestHeaders 升级请求对应的http/2头部，伪头部在前
+func h2cRequestHeaders(r *http.Request) [][2]string {
+	fields := [][2]string{
+		{":method", r.Method},
+		{":scheme", "http"},
+		{":authority", r.Host},
+		{":path", r.URL.RequestURI()},
+	}
+	header := r.Header.Clone()
+	for _, key := range h2cHopHeaders {
+		header.Del(key)
+	}
+	for key, values := range header {
+		for _, value := range values {
+			fields = append(fields, [2]string{strings.ToLower(key), value})
+		}
+	}
+	return fields
+}
+
+// writeHeadersFrames 把头部编码为HEADERS帧，超过默认帧大小时拆分为CONTINUATION帧
+// 头部使用不进入动态表、不使用huffman的字面量编码，这样不需要维护hpack的状态
+func writeHeadersFrames(buf *bytes.Buffer, streamID uint32, fields [][2]string) {
+	var block []byte
+	for _, field := range fields {
+		block = append(block, 0x00)
+		block = appendHpackString(block, field[0])
+		block = appendHpackString(block, field[1])
+	}
+
+	frameType := byte(http2FrameHeaders)
+	flags := byte(http2FlagEndStream)
+	for {
+		chunk := block
+		if len(chunk) > http2DefaultFrameSize {
+			chunk = chunk[:http2DefaultFrameSize]
+		}
+		block = block[len(chunk):]
+		if len(block) == 0 {
+			flags |= http2FlagEndHeaders
+		}
+		head := make([]byte, 9)
+		head[0], head[1], head[2] = byte(len(chunk)>>16), byte(len(chunk)>>8), byte(len(chunk))
+		head[3] = frameType
+		head[4] = flags
+		binary.BigEndian.PutUint32(head[5:], streamID)
+		buf.Write(head)
+		buf.Write(chunk)
+		if len(block) == 0 {
+			return
+		}
+		frameType, flags = http2FrameContinuation, 0
+	}
+}
+
+// appendHpackString 编码hpack字符串，7位前缀的长度加原始字节
+func appendHpackString(dst []byte, s string) []byte {
+	const prefix = 1<<7 - 1
+	n := uint64(len(s))
+	if n < prefix {
+		dst = append(dst, byte(n))
+	} else {
+		dst = append(dst, prefix)
+		n -= prefix
+		for n >= 128 {
+			dst = append(dst, byte(n&0x7f|0x80))
+			n >>= 7
+		}
+		dst = append(dst, byte(n))
+	}
+	return append(dst, s...)
+}
+
+// h2cConn 升级后的连接，先读取插入的帧再读取连接上的数据
+type h2cConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c *h2cConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// connListener 把已经建立的连接交给http.Server处理
+type connListener struct {
+	conns     chan net.Conn
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func newConnListener() *connListener {
+	return &connListener{conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+// push 交给http.Server处理，listener已经关闭时关闭连接
+func (l *connListener) push(conn net.Conn) {
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		conn.Close()
+	}
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+	})
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return connListenerAddr{}
+}
+
+type connListenerAddr struct{}
+
+func (connListenerAddr) Network() string { return "h2c" }
+func (connListenerAddr) String() string  { return "h2c-upgrade" }
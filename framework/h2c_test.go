@@ -0,0 +1,274 @@
+package framework
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	h2cFrameData         = 0x0
+	h2cFrameRSTStream    = 0x3
+	h2cFrameGoAway       = 0x7
+	h2cFrameWindowUpdate = 0x8
+
+	h2cFlagAck = 0x1
+
+	h2cSettingEnablePush        = 0x2
+	h2cSettingInitialWindowSize = 0x4
+)
+
+// h2cTestBody 响应体，比升级时设置的初始窗口大
+var h2cTestBody = strings.Repeat("0123456789", 10)
+
+// h2cEcho 测试接口的响应，第一行是服务端看到的请求信息
+func h2cEcho(query string, test string, bigLen int) string {
+	return fmt.Sprintf("%s %s %s %d\n%s", "HTTP/2.0", query, test, bigLen, h2cTestBody)
+}
+
+// startH2CServer 启动开启h2c的服务，返回地址
+func startH2CServer(t *testing.T) string {
+	t.Helper()
+	core := NewCore()
+	core.Get("/echo", func(c *Context) error {
+		r := c.GetRequest()
+		fmt.Fprintf(c.GetResponse(), "%s %s %s %d\n%s", r.Proto, r.URL.RawQuery, r.Header.Get("X-Test"), len(r.Header.Get("X-Big")), h2cTestBody)
+		return nil
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(core)
+	server.H2C = true
+	server.ShutdownTimeout = time.Second
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Serve(ln)
+	}()
+	t.Cleanup(func() {
+		server.Stop()
+		<-done
+	})
+	return ln.Addr().String()
+}
+
+func TestH2CPriorKnowledge(t *testing.T) {
+	addr := startH2CServer(t)
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+	defer client.CloseIdleConnections()
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "http://"+addr+"/echo?a=1", nil)
+		req.Header.Set("X-Test", "prior")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.ProtoMajor != 2 {
+			t.Fatalf("proto = %s", resp.Proto)
+		}
+		if string(body) != h2cEcho("a=1", "prior", 0) {
+			t.Fatalf("unexpected response: %q", body)
+		}
+	}
+}
+
+// h2cSettings 编码SETTINGS帧的负载，参数为成对的id和值
+func h2cSettings(pairs ...uint32) []byte {
+	var b []byte
+	for i := 0; i+1 < len(pairs); i += 2 {
+		b = binary.BigEndian.AppendUint16(b, uint16(pairs[i]))
+		b = binary.BigEndian.AppendUint32(b, pairs[i+1])
+	}
+	return b
+}
+
+// h2cSettingsHeader 编码HTTP2-Settings头
+func h2cSettingsHeader(settings []byte) string {
+	return base64.RawURLEncoding.EncodeToString(settings)
+}
+
+// writeH2CFrame 写入一个http/2帧
+func writeH2CFrame(w io.Writer, frameType byte, flags byte, streamID uint32, payload []byte) error {
+	head := make([]byte, 9, 9+len(payload))
+	head[0], head[1], head[2] = byte(len(payload)>>16), byte(len(payload)>>8), byte(len(payload))
+	head[3] = frameType
+	head[4] = flags
+	binary.BigEndian.PutUint32(head[5:], streamID)
+	_, err := w.Write(append(head, payload...))
+	return err
+}
+
+// writeH2CWindowUpdate 增加stream的流控窗口，streamID为0时是连接的窗口
+func writeH2CWindowUpdate(w io.Writer, streamID uint32, n uint32) error {
+	return writeH2CFrame(w, h2cFrameWindowUpdate, 0, streamID, binary.BigEndian.AppendUint32(nil, n))
+}
+
+// h2cResponse stream上收到的响应
+type h2cResponse struct {
+	headerBlock []byte
+	body        []byte
+	ended       bool
+}
+
+// status200 响应头是否以:status 200开头，服务端使用hpack静态表的第8项编码
+func (r *h2cResponse) status200() bool {
+	return len(r.headerBlock) > 0 && r.headerBlock[0] == 0x80|8
+}
+
+// readH2CFrames 读取帧直到streams中的响应都结束或者超时，响应写入streams
+func readH2CFrames(t *testing.T, conn net.Conn, r io.Reader, wait time.Duration, streams map[uint32]*h2cResponse) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(wait))
+	defer conn.SetReadDeadline(time.Time{})
+	head := make([]byte, 9)
+	for {
+		if _, err := io.ReadFull(r, head); err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				return
+			}
+			t.Fatalf("read frame: %v", err)
+		}
+		payload := make([]byte, int(head[0])<<16|int(head[1])<<8|int(head[2]))
+		if _, err := io.ReadFull(r, payload); err != nil {
+			t.Fatalf("read frame payload: %v", err)
+		}
+		frameType, flags, streamID := head[3], head[4], binary.BigEndian.Uint32(head[5:])&0x7fffffff
+		switch frameType {
+		case http2FrameSettings:
+			if flags&h2cFlagAck == 0 {
+				writeH2CFrame(conn, http2FrameSettings, h2cFlagAck, 0, nil)
+			}
+		case http2FrameHeaders, http2FrameContinuation:
+			resp := streams[streamID]
+			resp.headerBlock = append(resp.headerBlock, payload...)
+			resp.ended = resp.ended || flags&http2FlagEndStream != 0
+		case h2cFrameData:
+			resp := streams[streamID]
+			resp.body = append(resp.body, payload...)
+			resp.ended = flags&http2FlagEndStream != 0
+		case h2cFrameGoAway:
+			t.Fatalf("goaway: %x", payload)
+		case h2cFrameRSTStream:
+			t.Fatalf("rst stream %d: %x", streamID, payload)
+		}
+		done := true
+		for _, resp := range streams {
+			done = done && resp.ended
+		}
+		if done {
+			return
+		}
+	}
+}
+
+func TestH2CUpgrade(t *testing.T) {
+	addr := startH2CServer(t)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// 升级时设置很小的初始窗口，客户端的SETTINGS帧不包含这个值，服务端应该使用升级时的值
+	big := strings.Repeat("x", 20000) // 超过默认帧大小，升级请求的头部需要拆分为CONTINUATION帧
+	fmt.Fprintf(conn, "GET /echo?a=1 HTTP/1.1\r\nHost: %s\r\nConnection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\n"+
+		"HTTP2-Settings: %s\r\nX-Test: upgrade\r\nX-Big: %s\r\n\r\n",
+		addr, h2cSettingsHeader(h2cSettings(h2cSettingInitialWindowSize, 10)), big)
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Upgrade") != "h2c" {
+		t.Fatalf("status = %d, upgrade = %q", resp.StatusCode, resp.Header.Get("Upgrade"))
+	}
+
+	if _, err := io.WriteString(conn, http2ClientPreface); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeH2CFrame(conn, http2FrameSettings, 0, 0, h2cSettings(h2cSettingEnablePush, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 升级请求作为stream 1，窗口只有10个字节，收不到完整的响应体
+	want := h2cEcho("a=1", "upgrade", len(big))
+	streams := map[uint32]*h2cResponse{1: {}}
+	readH2CFrames(t, conn, br, 300*time.Millisecond, streams)
+	up := streams[1]
+	if !up.status200() {
+		t.Fatalf("unexpected upgrade response header block: %x", up.headerBlock)
+	}
+	if string(up.body) != want[:10] || up.ended {
+		t.Fatalf("expected 10 bytes before WINDOW_UPDATE, got %q (ended=%v)", up.body, up.ended)
+	}
+
+	// 增加窗口后收到剩余的响应体，同一个连接上的新请求使用stream 3
+	writeH2CWindowUpdate(conn, 1, uint32(len(want)))
+	writeH2CWindowUpdate(conn, 0, 1<<20)
+	if err := writeH2CFrame(conn, http2FrameSettings, 0, 0, h2cSettings(h2cSettingInitialWindowSize, 1<<16)); err != nil {
+		t.Fatal(err)
+	}
+	var request bytes.Buffer
+	writeHeadersFrames(&request, 3, [][2]string{{":method", "GET"}, {":scheme", "http"}, {":authority", addr}, {":path", "/echo?b=2"}, {"x-test", "stream3"}})
+	if _, err := conn.Write(request.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	streams[3] = &h2cResponse{}
+	readH2CFrames(t, conn, br, 2*time.Second, streams)
+	if string(up.body) != want || !up.ended {
+		t.Fatalf("upgrade body = %q (ended=%v)", up.body, up.ended)
+	}
+	s3 := streams[3]
+	if !s3.status200() || string(s3.body) != h2cEcho("b=2", "stream3", 0) {
+		t.Fatalf("unexpected stream 3 response: %x %q", s3.headerBlock, s3.body)
+	}
+}
+
+func TestH2CUpgradeSettings(t *testing.T) {
+	valid := h2cSettingsHeader(h2cSettings(h2cSettingInitialWindowSize, 10))
+	tests := []struct {
+		name    string
+		header  http.Header
+		body    bool
+		wantOK  bool
+		wantLen int
+	}{
+		{name: "valid", header: http.Header{"Connection": {"Upgrade, HTTP2-Settings"}, "Upgrade": {"h2c"}, "Http2-Settings": {valid}}, wantOK: true, wantLen: 6},
+		{name: "empty settings", header: http.Header{"Connection": {"Upgrade, HTTP2-Settings"}, "Upgrade": {"h2c"}, "Http2-Settings": {""}}, wantOK: true},
+		{name: "padded base64", header: http.Header{"Connection": {"Upgrade, HTTP2-Settings"}, "Upgrade": {"h2c"}, "Http2-Settings": {valid + "=="}}, wantOK: true, wantLen: 6},
+		{name: "missing settings", header: http.Header{"Connection": {"Upgrade, HTTP2-Settings"}, "Upgrade": {"h2c"}}},
+		{name: "two settings", header: http.Header{"Connection": {"Upgrade, HTTP2-Settings"}, "Upgrade": {"h2c"}, "Http2-Settings": {valid, valid}}},
+		{name: "bad base64", header: http.Header{"Connection": {"Upgrade, HTTP2-Settings"}, "Upgrade": {"h2c"}, "Http2-Settings": {"!!!"}}},
+		{name: "partial setting", header: http.Header{"Connection": {"Upgrade, HTTP2-Settings"}, "Upgrade": {"h2c"}, "Http2-Settings": {"AAQAAA"}}},
+		{name: "no connection token", header: http.Header{"Connection": {"Upgrade"}, "Upgrade": {"h2c"}, "Http2-Settings": {valid}}},
+		{name: "other upgrade", header: http.Header{"Connection": {"Upgrade, HTTP2-Settings"}, "Upgrade": {"websocket"}, "Http2-Settings": {valid}}},
+		{name: "with body", header: http.Header{"Connection": {"Upgrade, HTTP2-Settings"}, "Upgrade": {"h2c"}, "Http2-Settings": {valid}}, body: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "http://example.com/", nil)
+			r.Header = tt.header
+			if tt.body {
+				r.ContentLength = 1
+			}
+			settings, ok := h2cUpgradeSettings(r)
+			if ok != tt.wantOK || len(settings) != tt.wantLen {
+				t.Fatalf("got ok=%v len=%d, want ok=%v len=%d", ok, len(settings), tt.wantOK, tt.wantLen)
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package framework
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 健康检查的状态
+const (
+	HealthUp       = "up"       // 所有检查通过
+	HealthDegraded = "degraded" // 只有非关键的检查失败，仍然可以接收流量
+	HealthDown     = "down"     // 关键的检查失败
+	HealthDraining = "draining" // 服务正在优雅关闭
+)
+
+// 检查没有设置超时时间时使用的超时时间
+const defaultHealthTimeout = 5 * time.Second
+
+// HealthCheck 一个命名的健康检查，例如数据库连接
+type HealthCheck struct {
+	Name string
+	// Check 执行检查，返回错误表示失败，ctx在超时后取消
+	Check func(ctx context.Context) error
+	// Timeout 检查的超时时间，默认5秒
+	Timeout time.Duration
+	// Critical 失败时readiness返回503，否则只把状态标记为degraded
+	Critical bool
+	// CacheTTL 结果的缓存时间，避免探针频繁访问依赖，为0时每次都检查
+	CacheTTL time.Duration
+	// Liveness 同时用于liveness检查，默认只用于readiness，liveness检查不应该依赖外部服务
+	Liveness bool
+}
+
+// HealthCheckResult 一个检查的结果
+type HealthCheckResult struct {
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Critical bool      `json:"critical"`
+	Duration string    `json:"duration"`
+	Time     time.Time `json:"time"` // 执行检查的时间，使用缓存时早于请求时间
+}
+
+// HealthReport 汇总的检查结果
+type HealthReport struct {
+	Status string                       `json:"status"`
+	Checks map[string]HealthCheckResult `json:"checks,omitempty"`
+}
+
+// healthEntry 注册的检查和缓存的结果
+type healthEntry struct {
+	check HealthCheck
+
+	mu     sync.Mutex // 同一个检查同时只执行一次
+	result HealthCheckResult
+	cached bool
+}
+
+// HealthRegistry 健康检查的注册表
+type HealthRegistry struct {
+	mu      sync.RWMutex
+	entries []*healthEntry
+}
+
+// NewHealthRegistry 初始化注册表
+func NewHealthRegistry() *HealthRegistry {
+	return &HealthRegistry{}
+}
+
+// Register 注册检查，名称为空或者重复时panic
+func (r *HealthRegistry) Register(check HealthCheck) {
+	if check.Name == "" || check.Check == nil {
+		panic("health: check name and func are required")
+	}
+	if check.Timeout <= 0 {
+		check.Timeout = defaultHealthTimeout
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, entry := range r.entries {
+		if entry.check.Name == check.Name {
+			panic("health: duplicate check " + check.Name)
+		}
+	}
+	r.entries = append(r.entries, &healthEntry{check: check})
+}
+
+// Check 并发执行检查并汇总，liveness为true时只执行Liveness的检查
+func (r *HealthRegistry) Check(ctx context.Context, liveness bool) HealthReport {
+	r.mu.RLock()
+	var entries []*healthEntry
+	for _, entry := range r.entries {
+		if !liveness || entry.check.Liveness {
+			entries = append(entries, entry)
+		}
+	}
+	r.mu.RUnlock()
+
+	results := make([]HealthCheckResult, len(entries))
+	var wg sync.WaitGroup
+	for i, entry := range entries {
+		wg.Add(1)
+		go func(i int, entry *healthEntry) {
+			defer wg.Done()
+			results[i] = entry.run(ctx)
+		}(i, entry)
+	}
+	wg.Wait()
+
+	report := HealthReport{Status: HealthUp, Checks: make(map[string]HealthCheckResult, len(entries))}
+	for i, entry := range entries {
+		result := results[i]
+		report.Checks[entry.check.Name] = result
+		if result.Status == HealthUp {
+			continue
+		}
+		if result.Critical {
+			report.Status = HealthDown
+		} else if report.Status == HealthUp {
+			report.Status = HealthDegraded
+		}
+	}
+	return report
+}
+
+// run 执行检查，缓存没有过期时直接返回缓存的结果
+func (e *healthEntry) run(ctx context.Context) HealthCheckResult {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.cached && e.check.CacheTTL > 0 && time.Since(e.result.Time) < e.check.CacheTTL {
+		return e.result
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, e.check.Timeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("panic: %v", p)
+			}
+		}()
+		done <- e.check.Check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// 检查没有响应ctx的取消，不再等待它
+		err = fmt.Errorf("timeout after %s", e.check.Timeout)
+	}
+
+	result := HealthCheckResult{
+		Status:   HealthUp,
+		Critical: e.check.Critical,
+		Duration: time.Since(start).String(),
+		Time:     start,
+	}
+	if err != nil {
+		result.Status = HealthDown
+		result.Error = err.Error()
+	}
+	// 探针请求被取消导致的失败不缓存
+	if parent.Err() == nil {
+		e.result = result
+		e.cached = true
+	}
+	return result
+}
+
+// HealthConfig 健康检查接口的配置
+type HealthConfig struct {
+	// LivenessPath liveness接口的路径，默认为/healthz，只执行Liveness的检查，关闭过程中仍然返回200
+	LivenessPath string
+	// ReadinessPath readiness接口的路径，默认为/readyz，优雅关闭开始后返回503
+	ReadinessPath string
+	// TrustedNetworks 允许通过?verbose查看每个检查详情的来源ip或者CIDR，默认只允许本机
+	// 使用连接的地址判断，不信任X-Forwarded-For，unix socket总是允许
+	TrustedNetworks []string
+}
+
+// Health core的健康检查注册表
+func (c *Core) Health() *HealthRegistry {
+	c.healthOnce.Do(func() {
+		c.health = NewHealthRegistry()
+	})
+	return c.health
+}
+
+// MountHealth 注册liveness和readiness接口的GET和HEAD路由，接口不经过core上注册的中间件
+// 状态为up和degraded时返回200，down和draining时返回503
+func (c *Core) MountHealth(config HealthConfig) error {
+	if config.LivenessPath == "" {
+		config.LivenessPath = "/healthz"
+	}
+	if config.ReadinessPath == "" {
+		config.ReadinessPath = "/readyz"
+	}
+	if len(config.TrustedNetworks) == 0 {
+		config.TrustedNetworks = []string{"127.0.0.0/8", "::1"}
+	}
+	trusted, err := ParseIPNets(config.TrustedNetworks)
+	if err != nil {
+		return err
+	}
+	registry := c.Health()
+	handler := func(liveness bool) ControllerHandler {
+		return func(ctx *Context) error {
+			verbose := ctx.request.URL.Query().Has("verbose") && RemoteAddrTrusted(ctx.request.RemoteAddr, trusted)
+			var report HealthReport
+			if !liveness && c.inflight.Draining() {
+				report.Status = HealthDraining
+			} else {
+				report = registry.Check(ctx.BaseContext(), liveness)
+			}
+			if !verbose {
+				report.Checks = nil
+			}
+			status := http.StatusOK
+			if report.Status == HealthDown || report.Status == HealthDraining {
+				status = http.StatusServiceUnavailable
+			}
+			body, err := json.Marshal(report)
+			if err != nil {
+				return err
+			}
+			header := ctx.responseWriter.Header()
+			header.Set("Content-Type", "application/json")
+			header.Set("Cache-Control", "no-store")
+			ctx.responseWriter.WriteHeader(status)
+			ctx.responseWriter.Write(body)
+			return nil
+		}
+	}
+	routes := []struct {
+		path    string
+		handler ControllerHandler
+	}{
+		{config.LivenessPath, handler(true)},
+		{config.ReadinessPath, handler(false)},
+	}
+	for _, route := range routes {
+		if err := c.addRawRoute(route.path, []ControllerHandler{route.handler}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoteAddrTrusted 连接的来源是否在nets中，没有ip的地址(unix socket)总是信任
+func RemoteAddrTrusted(remoteAddr string, nets []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return remoteAddr == "" || remoteAddr == "@"
+	}
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Names 已注册的检查名称，按名称排序
+func (r *HealthRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.entries))
+	for _, entry := range r.entries {
+		names = append(names, entry.check.Name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,57 @@
+package framework
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMountHealth(t *testing.T) {
+	core := NewCore()
+	core.SetMode(TestMode)
+	var failing bool
+	core.Health().Register(HealthCheck{Name: "db", Critical: true, Check: func(context.Context) error {
+		if failing {
+			return errors.New("connection refused")
+		}
+		return nil
+	}})
+	if err := core.MountHealth(HealthConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		core.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		failing  bool
+		draining bool
+		status   int
+	}{
+		{name: "liveness", method: "GET", path: "/healthz", status: http.StatusOK},
+		{name: "liveness head", method: "HEAD", path: "/healthz", status: http.StatusOK},
+		{name: "readiness", method: "GET", path: "/readyz", status: http.StatusOK},
+		{name: "readiness head", method: "HEAD", path: "/readyz", status: http.StatusOK},
+		{name: "liveness ignores readiness checks", method: "HEAD", path: "/healthz", failing: true, status: http.StatusOK},
+		{name: "readiness down", method: "GET", path: "/readyz", failing: true, status: http.StatusServiceUnavailable},
+		{name: "readiness down head", method: "HEAD", path: "/readyz", failing: true, status: http.StatusServiceUnavailable},
+		{name: "draining head", method: "HEAD", path: "/readyz", draining: true, status: http.StatusServiceUnavailable},
+		{name: "post", method: "POST", path: "/healthz", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		failing = tt.failing
+		if tt.draining {
+			core.inflight.SetDraining()
+		}
+		if rec := serve(tt.method, tt.path); rec.Code != tt.status {
+			t.Errorf("%s: %s %s status = %d, want %d", tt.name, tt.method, tt.path, rec.Code, tt.status)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package framework
+
+import (
+	"context"
+	"runtime/debug"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// InflightRequest 一个正在处理的请求
+type InflightRequest struct {
+	ID     uint64    `json:"id"`
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	Route  string    `json:"route"` // 匹配到的路由规则，没有匹配到时为空
+	Client string    `json:"client"`
+	Start  time.Time `json:"start"`
+
+	RequestID string `json:"request_id,omitempty"` // 请求id，由RequestID中间件设置
+}
+
+// InflightTracker 记录正在处理的请求和通过Context启动的goroutine
+// 优雅关闭时用来等待它们结束，并找出被强制中断的请求
+type InflightTracker struct {
+	mu         sync.Mutex
+	requests   map[uint64]*InflightRequest
+	goroutines int
+	waiters    []chan struct{} // 全部结束时通知Wait
+
+	drainHooks map[uint64]func() // 开始优雅关闭时执行，例如关闭websocket连接
+	nextHook   uint64
+
+	nextID   uint64
+	draining atomic.Bool
+	labels   atomic.Bool // 是否给处理请求的goroutine加上pprof标签
+}
+
+// NewInflightTracker 初始化请求跟踪
+func NewInflightTracker() *InflightTracker {
+	return &InflightTracker{requests: map[uint64]*InflightRequest{}}
+}
+
+// begin 开始跟踪一个请求
+func (t *InflightTracker) begin(ctx *Context) *InflightRequest {
+	req := &InflightRequest{
+		ID:     atomic.AddUint64(&t.nextID, 1),
+		Method: ctx.request.Method,
+		Path:   ctx.request.URL.Path,
+		Client: ctx.ClientIp(),
+		Start:  time.Now(),
+	}
+	t.mu.Lock()
+	t.requests[req.ID] = req
+	t.mu.Unlock()
+	return req
+}
+
+// setRoute 路由匹配后记录路由规则
+func (t *InflightTracker) setRoute(req *InflightRequest, route string) {
+	t.mu.Lock()
+	req.Route = route
+	t.mu.Unlock()
+}
+
+// setRequestID 记录请求id
+func (t *InflightTracker) setRequestID(req *InflightRequest, id string) {
+	t.mu.Lock()
+	req.RequestID = id
+	t.mu.Unlock()
+}
+
+// goroutineLabelKey 处理请求的goroutine的pprof标签，值为请求的ID
+const goroutineLabelKey = "axis_request"
+
+// EnableGoroutineLabels 给处理请求的goroutine加上pprof标签，用于找出慢请求的调用栈
+// 请求中启动的goroutine会继承标签
+func (t *InflightTracker) EnableGoroutineLabels() {
+	t.labels.Store(true)
+}
+
+// labelGoroutine 给当前goroutine加上请求的标签，返回的函数用于清除标签
+func (t *InflightTracker) labelGoroutine(req *InflightRequest) func() {
+	if !t.labels.Load() {
+		return func() {}
+	}
+	labels := pprof.Labels(goroutineLabelKey, strconv.FormatUint(req.ID, 10))
+	pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), labels))
+	return func() {
+		// http.Server的连接goroutine会处理同一个连接上的下一个请求
+		pprof.SetGoroutineLabels(context.Background())
+	}
+}
+
+// end 请求处理结束
+func (t *InflightTracker) end(req *InflightRequest) {
+	t.mu.Lock()
+	delete(t.requests, req.ID)
+	t.notifyLocked()
+	t.mu.Unlock()
+}
+
+// notifyLocked 没有请求和goroutine时唤醒等待者，调用方需要持有锁
+func (t *InflightTracker) notifyLocked() {
+	if len(t.requests) > 0 || t.goroutines > 0 {
+		return
+	}
+	for _, ch := range t.waiters {
+		close(ch)
+	}
+	t.waiters = nil
+}
+
+// Go 启动一个被跟踪的goroutine，优雅关闭时会等待它结束
+func (t *InflightTracker) Go(fn func()) {
+	t.mu.Lock()
+	t.goroutines++
+	t.mu.Unlock()
+
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				DefaultLogger().Error("inflight goroutine panic", "panic", p, "stack", string(debug.Stack()))
+			}
+			t.mu.Lock()
+			t.goroutines--
+			t.notifyLocked()
+			t.mu.Unlock()
+		}()
+		fn()
+	}()
+}
+
+// Count 正在处理的请求数
+func (t *InflightTracker) Count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.requests)
+}
+
+// Goroutines 正在运行的被跟踪goroutine数
+func (t *InflightTracker) Goroutines() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.goroutines
+}
+
+// Snapshot 正在处理的请求，按开始时间排序
+func (t *InflightTracker) Snapshot() []InflightRequest {
+	t.mu.Lock()
+	ret := make([]InflightRequest, 0, len(t.requests))
+	for _, req := range t.requests {
+		ret = append(ret, *req)
+	}
+	t.mu.Unlock()
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Start.Before(ret[j].Start) })
+	return ret
+}
+
+// SetDraining 标记服务正在关闭，健康检查应该返回未就绪，并执行通过onDrain注册的函数
+func (t *InflightTracker) SetDraining() {
+	if !t.draining.CompareAndSwap(false, true) {
+		return
+	}
+	t.mu.Lock()
+	hooks := t.drainHooks
+	t.drainHooks = nil
+	t.mu.Unlock()
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
+// onDrain 注册开始优雅关闭时执行的函数，已经在关闭时立即执行，返回的函数用于取消注册
+func (t *InflightTracker) onDrain(fn func()) func() {
+	t.mu.Lock()
+	if t.draining.Load() {
+		t.mu.Unlock()
+		fn()
+		return func() {}
+	}
+	if t.drainHooks == nil {
+		t.drainHooks = map[uint64]func(){}
+	}
+	t.nextHook++
+	id := t.nextHook
+	t.drainHooks[id] = fn
+	t.mu.Unlock()
+	return func() {
+		t.mu.Lock()
+		delete(t.drainHooks, id)
+		t.mu.Unlock()
+	}
+}
+
+// Draining 服务是否正在关闭
+func (t *InflightTracker) Draining() bool {
+	return t.draining.Load()
+}
+
+// Wait 等待所有请求和goroutine结束，ctx结束时返回ctx的错误
+func (t *InflightTracker) Wait(ctx context.Context) error {
+	t.mu.Lock()
+	if len(t.requests) == 0 && t.goroutines == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	ch := make(chan struct{})
+	t.waiters = append(t.waiters, ch)
+	t.mu.Unlock()
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Inflight 获取core的请求跟踪
+func (c *Core) Inflight() *InflightTracker {
+	return c.inflight
+}
+
+// Go 启动一个在请求结束后仍然可以继续运行的goroutine，优雅关闭时会等待它结束
+// 请求结束后不能再通过ctx读写请求和响应，fn需要的数据应该提前取出
+func (ctx *Context) Go(fn func()) {
+	if ctx.core == nil {
+		go fn()
+		return
+	}
+	ctx.core.inflight.Go(fn)
+}
+
+// RoutePattern 匹配到的路由规则，例如 /subject/:id，没有匹配到时为空
+func (ctx *Context) RoutePattern() string {
+	return ctx.route
+}
@@ -0,0 +1,172 @@
+package framework
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ListenerSpec 一个监听地址的配置，同一个Server可以同时监听多个地址
+type ListenerSpec struct {
+	Network string // tcp或者unix，默认tcp
+	Addr    string // tcp地址或者unix socket文件路径
+
+	CertFile           string        // 同时设置CertFile和KeyFile时提供https服务，文件变化后自动重新加载
+	KeyFile            string        // 证书私钥文件
+	CertReloadInterval time.Duration // 检查证书文件变化的间隔，默认10s，小于0时不检查
+	SelfSigned         bool          // 没有证书文件时使用自签名证书提供https服务，仅用于开发环境
+	TLSConfig          *tls.Config   // 自定义tls配置，设置后提供https服务，证书和客户端验证的配置会覆盖其中对应的字段
+
+	ClientAuth   tls.ClientAuthType // 客户端证书的验证方式，设置了CA时默认为验证提供的证书，否则不要求证书
+	ClientCAFile string             // 验证客户端证书的CA文件，pem格式
+	ClientCAs    *x509.CertPool     // 验证客户端证书的CA，优先于ClientCAFile
+
+	ProxyProtocol      bool          // 连接开头带有PROXY protocol v1/v2头部，RemoteAddr和ClientIp为头部中的客户端地址
+	TrustedProxies     []string      // 允许发送PROXY头部的ip或者CIDR，为空时信任所有来源，其他来源的连接按普通连接处理
+	ProxyHeaderTimeout time.Duration // 读取PROXY头部的超时时间，默认5s
+}
+
+// boundListener 已经监听的地址
+type boundListener struct {
+	raw      net.Listener  // 原始listener，平滑重启时传递给子进程
+	ln       net.Listener  // 包装了PROXY protocol和tls之后的listener
+	reloader *CertReloader // 证书文件的自动加载，没有时为nil
+}
+
+// release 释放listener之外的资源，listener由http.Server关闭
+func (b boundListener) release() {
+	if b.reloader != nil {
+		b.reloader.Close()
+	}
+}
+
+// RunListeners 同时监听多个地址并提供服务，阻塞到服务关闭，所有地址一起关闭
+// 任何一个地址监听失败时服务不会启动
+func (s *Server) RunListeners(specs ...ListenerSpec) error {
+	if len(specs) == 0 {
+		return errors.New("no listener")
+	}
+	bound := make([]boundListener, 0, len(specs))
+	for _, spec := range specs {
+		b, err := s.bind(spec)
+		if err != nil {
+			for _, b := range bound {
+				b.raw.Close()
+				b.release()
+			}
+			return err
+		}
+		bound = append(bound, b)
+	}
+	// 配置中去掉的地址不再监听，否则父进程传下来的端口会一直被占用
+	closeInherited()
+	return s.serve(bound)
+}
+
+// bind 按配置监听地址，依次包装PROXY protocol和tls
+// PROXY头部在tls握手之前发送，所以tls需要在最外层
+func (s *Server) bind(spec ListenerSpec) (boundListener, error) {
+	network := spec.Network
+	if network == "" {
+		network = "tcp"
+	}
+	tlsConfig, reloader, err := spec.tlsConfig()
+	if err != nil {
+		return boundListener{}, err
+	}
+	raw, err := s.listen(network, spec.Addr)
+	if err != nil {
+		if reloader != nil {
+			reloader.Close()
+		}
+		return boundListener{}, err
+	}
+	bound := boundListener{raw: raw, reloader: reloader}
+
+	ln := raw
+	if spec.ProxyProtocol {
+		timeout := spec.ProxyHeaderTimeout
+		if timeout <= 0 {
+			timeout = 5 * time.Second
+		}
+		pl, err := newProxyListener(ln, spec.TrustedProxies, timeout)
+		if err != nil {
+			raw.Close()
+			bound.release()
+			return boundListener{}, err
+		}
+		ln = pl
+	}
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	bound.ln = ln
+	return bound, nil
+}
+
+// tlsConfig 生成tls配置，不需要tls时返回nil
+// 使用证书文件时返回证书的自动加载，关闭服务时需要关闭
+func (spec ListenerSpec) tlsConfig() (*tls.Config, *CertReloader, error) {
+	if spec.TLSConfig == nil && spec.CertFile == "" && spec.KeyFile == "" && !spec.SelfSigned {
+		return nil, nil, nil
+	}
+	config := &tls.Config{}
+	if spec.TLSConfig != nil {
+		config = spec.TLSConfig.Clone()
+	}
+
+	if spec.ClientCAs != nil {
+		config.ClientCAs = spec.ClientCAs
+	} else if spec.ClientCAFile != "" {
+		pool, err := loadCertPool(spec.ClientCAFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		config.ClientCAs = pool
+	}
+	if spec.ClientAuth != tls.NoClientCert {
+		config.ClientAuth = spec.ClientAuth
+	} else if config.ClientCAs != nil && config.ClientAuth == tls.NoClientCert {
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	var reloader *CertReloader
+	switch {
+	case spec.CertFile != "" || spec.KeyFile != "":
+		interval := spec.CertReloadInterval
+		if interval == 0 {
+			interval = 10 * time.Second
+		}
+		var err error
+		reloader, err = NewCertReloader(spec.CertFile, spec.KeyFile, interval)
+		if err != nil {
+			return nil, nil, err
+		}
+		config.Certificates = nil
+		config.GetCertificate = reloader.GetCertificate
+	case spec.SelfSigned && len(config.Certificates) == 0 && config.GetCertificate == nil:
+		cert, err := SelfSignedCertificate()
+		if err != nil {
+			return nil, nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	// 和http.Server.ServeTLS一样协商http/2
+	if len(config.NextProtos) == 0 {
+		config.NextProtos = []string{"h2", "http/1.1"}
+	}
+	return config, reloader, nil
+}
+
+// serveListener 在一个地址上提供服务，正常关闭时返回nil
+func serveListener(srv *http.Server, ln net.Listener) error {
+	err := srv.Serve(ln)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
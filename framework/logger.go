@@ -0,0 +1,316 @@
+package framework
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Level 日志级别
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return "level(" + strconv.Itoa(int(l)) + ")"
+}
+
+// ParseLevel 解析日志级别，不区分大小写
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", s)
+}
+
+// Field 日志中的一个字段
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Entry 一条日志
+type Entry struct {
+	Time    time.Time
+	Level   Level
+	Message string
+	Fields  []Field
+}
+
+// Logger 日志接口，fields为交替出现的key和value，也可以直接传Field
+type Logger interface {
+	Debug(msg string, fields ...interface{})
+	Info(msg string, fields ...interface{})
+	Warn(msg string, fields ...interface{})
+	Error(msg string, fields ...interface{})
+	// With 返回带有固定字段的子日志
+	With(fields ...interface{}) Logger
+	// Enabled 是否输出level级别的日志
+	Enabled(level Level) bool
+}
+
+// Encoder 把日志编码后写入buf
+type Encoder interface {
+	Encode(buf *bytes.Buffer, entry *Entry)
+}
+
+// logOutput 同一个日志和它的子日志共用的输出
+type logOutput struct {
+	mu      sync.Mutex
+	w       io.Writer
+	encoder Encoder
+	level   atomic.Int32
+}
+
+// AxisLogger 日志的默认实现
+type AxisLogger struct {
+	out    *logOutput
+	fields []Field
+}
+
+// NewLogger 初始化日志，输出level及以上级别的日志
+func NewLogger(w io.Writer, level Level, encoder Encoder) *AxisLogger {
+	out := &logOutput{w: w, encoder: encoder}
+	out.level.Store(int32(level))
+	return &AxisLogger{out: out}
+}
+
+// SetLevel 修改日志级别，子日志一起生效，可以在运行中调整
+func (l *AxisLogger) SetLevel(level Level) {
+	l.out.level.Store(int32(level))
+}
+
+// Level 当前的日志级别
+func (l *AxisLogger) Level() Level {
+	return Level(l.out.level.Load())
+}
+
+// Enabled 是否输出level级别的日志
+func (l *AxisLogger) Enabled(level Level) bool {
+	return level >= l.Level()
+}
+
+// With 返回带有固定字段的子日志
+func (l *AxisLogger) With(fields ...interface{}) Logger {
+	child := &AxisLogger{out: l.out}
+	child.fields = append(append(child.fields, l.fields...), toFields(fields)...)
+	return child
+}
+
+func (l *AxisLogger) Debug(msg string, fields ...interface{}) { l.log(LevelDebug, msg, fields) }
+func (l *AxisLogger) Info(msg string, fields ...interface{})  { l.log(LevelInfo, msg, fields) }
+func (l *AxisLogger) Warn(msg string, fields ...interface{})  { l.log(LevelWarn, msg, fields) }
+func (l *AxisLogger) Error(msg string, fields ...interface{}) { l.log(LevelError, msg, fields) }
+
+func (l *AxisLogger) log(level Level, msg string, fields []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	entry := &Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+		Fields:  append(l.fields[:len(l.fields):len(l.fields)], toFields(fields)...),
+	}
+	var buf bytes.Buffer
+	l.out.encoder.Encode(&buf, entry)
+	l.out.mu.Lock()
+	l.out.w.Write(buf.Bytes())
+	l.out.mu.Unlock()
+}
+
+// toFields 把交替出现的key和value转换为字段，key不是字符串时记为!BADKEY
+func toFields(kvs []interface{}) []Field {
+	fields := make([]Field, 0, len(kvs)/2)
+	for i := 0; i < len(kvs); i++ {
+		switch k := kvs[i].(type) {
+		case Field:
+			fields = append(fields, k)
+		case string:
+			if i+1 < len(kvs) {
+				fields = append(fields, Field{Key: k, Value: kvs[i+1]})
+				i++
+			} else {
+				fields = append(fields, Field{Key: "!BADKEY", Value: k})
+			}
+		default:
+			fields = append(fields, Field{Key: "!BADKEY", Value: k})
+		}
+	}
+	return fields
+}
+
+// fieldValue 字段值的输出形式，error和Stringer使用字符串
+func fieldValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case nil:
+		return nil
+	case error:
+		return value.Error()
+	case time.Duration:
+		return value.String()
+	case time.Time:
+		return value.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return value.String()
+	}
+	return v
+}
+
+// JSONEncoder 每条日志输出为一行json，适合日志采集
+type JSONEncoder struct {
+	NoTime bool // 不输出时间，测试模式下使用
+}
+
+func (e JSONEncoder) Encode(buf *bytes.Buffer, entry *Entry) {
+	buf.WriteByte('{')
+	if !e.NoTime {
+		buf.WriteString(`"time":`)
+		writeJSON(buf, entry.Time.Format(time.RFC3339Nano))
+		buf.WriteByte(',')
+	}
+	buf.WriteString(`"level":`)
+	writeJSON(buf, entry.Level.String())
+	buf.WriteString(`,"msg":`)
+	writeJSON(buf, entry.Message)
+	for _, field := range entry.Fields {
+		buf.WriteByte(',')
+		writeJSON(buf, field.Key)
+		buf.WriteByte(':')
+		writeJSON(buf, fieldValue(field.Value))
+	}
+	buf.WriteString("}\n")
+}
+
+func writeJSON(buf *bytes.Buffer, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprint(v))
+	}
+	buf.Write(b)
+}
+
+// ConsoleEncoder 输出为便于阅读的一行文本，例如 2006-01-02 15:04:05.000 INFO  message key=value
+type ConsoleEncoder struct {
+	NoTime bool // 不输出时间，测试模式下使用
+}
+
+func (e ConsoleEncoder) Encode(buf *bytes.Buffer, entry *Entry) {
+	if !e.NoTime {
+		buf.WriteString(entry.Time.Format("2006-01-02 15:04:05.000"))
+		buf.WriteByte(' ')
+	}
+	fmt.Fprintf(buf, "%-5s %s", strings.ToUpper(entry.Level.String()), entry.Message)
+	for _, field := range entry.Fields {
+		buf.WriteByte(' ')
+		buf.WriteString(field.Key)
+		buf.WriteByte('=')
+		s := fmt.Sprint(fieldValue(field.Value))
+		if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+			s = strconv.Quote(s)
+		}
+		buf.WriteString(s)
+	}
+	buf.WriteByte('\n')
+}
+
+// newModeLogger 运行模式对应的默认日志
+// debug模式输出所有级别的文本日志，release模式输出info及以上级别的json日志，test模式输出不带时间的文本日志
+func newModeLogger(mode string) *AxisLogger {
+	switch mode {
+	case ReleaseMode:
+		return NewLogger(os.Stderr, LevelInfo, JSONEncoder{})
+	case TestMode:
+		return NewLogger(os.Stderr, LevelDebug, ConsoleEncoder{NoTime: true})
+	}
+	return NewLogger(os.Stderr, LevelDebug, ConsoleEncoder{})
+}
+
+var (
+	defaultLogger   atomic.Value // Logger
+	customLoggerSet atomic.Bool  // 是否通过SetDefaultLogger设置过，设置过时切换模式不再替换
+)
+
+// DefaultLogger 全局的默认日志，没有设置日志的Core和框架内部使用
+func DefaultLogger() Logger {
+	return defaultLogger.Load().(Logger)
+}
+
+// SetDefaultLogger 设置全局的默认日志
+func SetDefaultLogger(logger Logger) {
+	customLoggerSet.Store(true)
+	defaultLogger.Store(logger)
+}
+
+// SetLogger 设置core的日志
+func (c *Core) SetLogger(logger Logger) {
+	c.logger = logger
+}
+
+// Logger core的日志，没有设置时使用运行模式对应的日志
+func (c *Core) Logger() Logger {
+	if c.logger != nil {
+		return c.logger
+	}
+	if c.modeLogger != nil {
+		return c.modeLogger
+	}
+	return DefaultLogger()
+}
+
+// Logger 当前请求的日志，带有路由、客户端ip和请求id字段
+func (ctx *Context) Logger() Logger {
+	ctx.loggerMu.Lock()
+	defer ctx.loggerMu.Unlock()
+	if ctx.logger == nil {
+		var base Logger
+		if ctx.core != nil {
+			base = ctx.core.Logger()
+		} else {
+			base = DefaultLogger()
+		}
+		fields := []interface{}{"route", ctx.route, "client_ip", ctx.ClientIp()}
+		if ctx.requestID != "" {
+			fields = append(fields, "request_id", ctx.requestID)
+		}
+		ctx.logger = base.With(fields...)
+	}
+	return ctx.logger
+}
+
+// AddLogFields 给当前请求的日志增加字段，之后通过ctx.Logger()输出的日志都会带上
+func (ctx *Context) AddLogFields(fields ...interface{}) {
+	logger := ctx.Logger().With(fields...)
+	ctx.loggerMu.Lock()
+	ctx.logger = logger
+	ctx.loggerMu.Unlock()
+}
@@ -0,0 +1,521 @@
+package framework
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBuckets 默认的耗时直方图分桶，单位为秒
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// ExponentialBuckets 从start开始每个分桶乘以factor，共count个
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
+
+// metricsContentType Prometheus文本格式
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// collector 可以输出为Prometheus文本格式的指标
+type collector interface {
+	names() []string
+	write(buf *bytes.Buffer)
+}
+
+// MetricsRegistry 指标的注册表，输出为Prometheus文本格式
+type MetricsRegistry struct {
+	mu         sync.RWMutex
+	collectors []collector
+	names      map[string]bool
+}
+
+// NewMetricsRegistry 初始化注册表
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{names: map[string]bool{}}
+}
+
+// register 注册指标，名称重复或者不合法时panic
+func (r *MetricsRegistry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, name := range c.names() {
+		if !validMetricName(name) {
+			panic("metrics: invalid metric name " + strconv.Quote(name))
+		}
+		if r.names[name] {
+			panic("metrics: duplicate metric name " + strconv.Quote(name))
+		}
+	}
+	for _, name := range c.names() {
+		r.names[name] = true
+	}
+	r.collectors = append(r.collectors, c)
+}
+
+// NewCounter 注册只增不减的计数器，labels为标签名
+func (r *MetricsRegistry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{metric: newMetric(name, help, "counter", labels)}
+	r.register(c)
+	return c
+}
+
+// NewGauge 注册可增可减的指标
+func (r *MetricsRegistry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{metric: newMetric(name, help, "gauge", labels)}
+	r.register(g)
+	return g
+}
+
+// NewHistogram 注册直方图，buckets为空时使用DefaultBuckets
+func (r *MetricsRegistry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &Histogram{metric: newMetric(name, help, "histogram", labels), buckets: buckets}
+	r.register(h)
+	return h
+}
+
+// NewGaugeFunc 注册在输出时调用fn获取值的指标
+func (r *MetricsRegistry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&funcMetric{name: name, help: help, typ: "gauge", fn: fn})
+}
+
+// NewCounterFunc 注册在输出时调用fn获取值的计数器，fn的返回值不能减少
+func (r *MetricsRegistry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(&funcMetric{name: name, help: help, typ: "counter", fn: fn})
+}
+
+// WriteText 按注册顺序输出所有指标
+func (r *MetricsRegistry) WriteText(buf *bytes.Buffer) {
+	r.mu.RLock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.RUnlock()
+	for _, c := range collectors {
+		c.write(buf)
+	}
+}
+
+// Handler 输出指标的处理函数
+func (r *MetricsRegistry) Handler() ControllerHandler {
+	return func(c *Context) error {
+		var buf bytes.Buffer
+		r.WriteText(&buf)
+		c.GetResponse().Header().Set("Cache-Control", "no-store")
+		c.Data(metricsContentType, buf.Bytes())
+		return nil
+	}
+}
+
+// metric 带标签的指标的公共部分，每组标签值对应一个series
+type metric struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+
+	mu     sync.RWMutex
+	series map[string]interface{} // 标签值拼接的key -> series
+	values map[string][]string    // 标签值拼接的key -> 标签值
+}
+
+func newMetric(name, help, typ string, labels []string) metric {
+	for _, label := range labels {
+		if !validLabelName(label) {
+			panic("metrics: invalid label name " + strconv.Quote(label))
+		}
+	}
+	return metric{
+		name:   name,
+		help:   help,
+		typ:    typ,
+		labels: labels,
+		series: map[string]interface{}{},
+		values: map[string][]string{},
+	}
+}
+
+func (m *metric) names() []string {
+	return []string{m.name}
+}
+
+// get 获取标签值对应的series，不存在时用create创建，标签值个数不对时panic
+func (m *metric) get(values []string, create func() interface{}) interface{} {
+	if len(values) != len(m.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", m.name, len(m.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	m.mu.RLock()
+	s, ok := m.series[key]
+	m.mu.RUnlock()
+	if ok {
+		return s
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.series[key]; ok {
+		return s
+	}
+	s = create()
+	m.series[key] = s
+	m.values[key] = append([]string(nil), values...)
+	return s
+}
+
+// each 按标签值排序遍历series，输出稳定
+func (m *metric) each(fn func(values []string, s interface{})) {
+	m.mu.RLock()
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	type item struct {
+		values []string
+		s      interface{}
+	}
+	items := make([]item, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, item{m.values[key], m.series[key]})
+	}
+	m.mu.RUnlock()
+	for _, it := range items {
+		fn(it.values, it.s)
+	}
+}
+
+func (m *metric) writeHeader(buf *bytes.Buffer) {
+	writeMetricHeader(buf, m.name, m.help, m.typ)
+}
+
+// labelPairs 标签输出为 {a="1",b="2"}，extra为额外的标签，例如直方图的le
+func (m *metric) labelPairs(values []string, extra ...string) string {
+	if len(values) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, label := range m.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(label)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(values[i]))
+		b.WriteByte('"')
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extra[i])
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(extra[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// atomicFloat 用atomic实现的float64
+type atomicFloat struct {
+	bits atomic.Uint64
+}
+
+func (f *atomicFloat) Add(v float64) {
+	for {
+		old := f.bits.Load()
+		if f.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) Set(v float64) {
+	f.bits.Store(math.Float64bits(v))
+}
+
+func (f *atomicFloat) Load() float64 {
+	return math.Float64frombits(f.bits.Load())
+}
+
+// Counter 只增不减的计数器
+type Counter struct {
+	metric
+}
+
+func (c *Counter) value(values []string) *atomicFloat {
+	return c.get(values, func() interface{} { return new(atomicFloat) }).(*atomicFloat)
+}
+
+// Inc 加1，values为标签值，顺序和注册时的标签名一致
+func (c *Counter) Inc(values ...string) {
+	c.value(values).Add(1)
+}
+
+// Add 增加v，v小于0时panic
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic("metrics: counter " + c.name + " cannot decrease")
+	}
+	c.value(values).Add(v)
+}
+
+func (c *Counter) write(buf *bytes.Buffer) {
+	c.writeHeader(buf)
+	c.each(func(values []string, s interface{}) {
+		writeSample(buf, c.name, c.labelPairs(values), s.(*atomicFloat).Load())
+	})
+}
+
+// Gauge 可增可减的指标
+type Gauge struct {
+	metric
+}
+
+func (g *Gauge) value(values []string) *atomicFloat {
+	return g.get(values, func() interface{} { return new(atomicFloat) }).(*atomicFloat)
+}
+
+// Set 设置为v
+func (g *Gauge) Set(v float64, values ...string) {
+	g.value(values).Set(v)
+}
+
+// Add 增加v，v可以小于0
+func (g *Gauge) Add(v float64, values ...string) {
+	g.value(values).Add(v)
+}
+
+// Inc 加1
+func (g *Gauge) Inc(values ...string) {
+	g.value(values).Add(1)
+}
+
+// Dec 减1
+func (g *Gauge) Dec(values ...string) {
+	g.value(values).Add(-1)
+}
+
+func (g *Gauge) write(buf *bytes.Buffer) {
+	g.writeHeader(buf)
+	g.each(func(values []string, s interface{}) {
+		writeSample(buf, g.name, g.labelPairs(values), s.(*atomicFloat).Load())
+	})
+}
+
+// Histogram 直方图，记录值的分布、总和和个数
+type Histogram struct {
+	metric
+	buckets []float64
+}
+
+// histogramSeries 一组标签值的直方图数据，counts为每个分桶自己的计数，输出时累加
+type histogramSeries struct {
+	counts []atomic.Uint64 // 最后一个为+Inf
+	sum    atomicFloat
+}
+
+// Observe 记录一个值
+func (h *Histogram) Observe(v float64, values ...string) {
+	s := h.get(values, func() interface{} {
+		return &histogramSeries{counts: make([]atomic.Uint64, len(h.buckets)+1)}
+	}).(*histogramSeries)
+	i := sort.SearchFloat64s(h.buckets, v)
+	s.counts[i].Add(1)
+	s.sum.Add(v)
+}
+
+// ObserveDuration 以秒为单位记录耗时
+func (h *Histogram) ObserveDuration(d time.Duration, values ...string) {
+	h.Observe(d.Seconds(), values...)
+}
+
+func (h *Histogram) write(buf *bytes.Buffer) {
+	h.writeHeader(buf)
+	h.each(func(values []string, v interface{}) {
+		s := v.(*histogramSeries)
+		// count使用+Inf分桶的累计值，保证和分桶一致
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i].Load()
+			writeSample(buf, h.name+"_bucket", h.labelPairs(values, "le", formatFloat(upper)), float64(cumulative))
+		}
+		cumulative += s.counts[len(h.buckets)].Load()
+		writeSample(buf, h.name+"_bucket", h.labelPairs(values, "le", "+Inf"), float64(cumulative))
+		writeSample(buf, h.name+"_sum", h.labelPairs(values), s.sum.Load())
+		writeSample(buf, h.name+"_count", h.labelPairs(values), float64(cumulative))
+	})
+}
+
+// funcMetric 输出时调用fn获取值的指标
+type funcMetric struct {
+	name string
+	help string
+	typ  string
+	fn   func() float64
+}
+
+func (f *funcMetric) names() []string {
+	return []string{f.name}
+}
+
+func (f *funcMetric) write(buf *bytes.Buffer) {
+	writeMetricHeader(buf, f.name, f.help, f.typ)
+	writeSample(buf, f.name, "", f.fn())
+}
+
+// RegisterRuntimeMetrics 注册go运行时的指标，例如goroutine数量、内存和GC
+func (r *MetricsRegistry) RegisterRuntimeMetrics() {
+	r.register(&runtimeCollector{start: time.Now()})
+}
+
+// runtimeCollector 每次输出时读取一次MemStats
+type runtimeCollector struct {
+	start time.Time
+}
+
+func (rc *runtimeCollector) names() []string {
+	return []string{
+		"go_info", "go_goroutines", "go_threads",
+		"go_memstats_alloc_bytes", "go_memstats_sys_bytes", "go_memstats_heap_inuse_bytes",
+		"go_memstats_heap_objects", "go_memstats_mallocs_total", "go_memstats_frees_total",
+		"go_gc_cycles_total", "go_gc_pause_seconds_total", "process_start_time_seconds",
+	}
+}
+
+func (rc *runtimeCollector) write(buf *bytes.Buffer) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	threads, _ := runtime.ThreadCreateProfile(nil)
+
+	gauge := func(name, help string, v float64) {
+		writeMetricHeader(buf, name, help, "gauge")
+		writeSample(buf, name, "", v)
+	}
+	counter := func(name, help string, v float64) {
+		writeMetricHeader(buf, name, help, "counter")
+		writeSample(buf, name, "", v)
+	}
+	writeMetricHeader(buf, "go_info", "Information about the Go environment.", "gauge")
+	writeSample(buf, "go_info", `{version="`+escapeLabelValue(runtime.Version())+`"}`, 1)
+	gauge("go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine()))
+	gauge("go_threads", "Number of OS threads created.", float64(threads))
+	gauge("go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", float64(ms.Alloc))
+	gauge("go_memstats_sys_bytes", "Number of bytes obtained from system.", float64(ms.Sys))
+	gauge("go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", float64(ms.HeapInuse))
+	gauge("go_memstats_heap_objects", "Number of allocated objects.", float64(ms.HeapObjects))
+	counter("go_memstats_mallocs_total", "Total number of mallocs.", float64(ms.Mallocs))
+	counter("go_memstats_frees_total", "Total number of frees.", float64(ms.Frees))
+	counter("go_gc_cycles_total", "Number of completed GC cycles.", float64(ms.NumGC))
+	counter("go_gc_pause_seconds_total", "Total GC stop-the-world pause time in seconds.", float64(ms.PauseTotalNs)/1e9)
+	gauge("process_start_time_seconds", "Start time of the process since unix epoch in seconds.", float64(rc.start.UnixNano())/1e9)
+}
+
+func writeMetricHeader(buf *bytes.Buffer, name, help, typ string) {
+	if help != "" {
+		buf.WriteString("# HELP ")
+		buf.WriteString(name)
+		buf.WriteByte(' ')
+		buf.WriteString(escapeHelp(help))
+		buf.WriteByte('\n')
+	}
+	buf.WriteString("# TYPE ")
+	buf.WriteString(name)
+	buf.WriteByte(' ')
+	buf.WriteString(typ)
+	buf.WriteByte('\n')
+}
+
+func writeSample(buf *bytes.Buffer, name, labels string, v float64) {
+	buf.WriteString(name)
+	buf.WriteString(labels)
+	buf.WriteByte(' ')
+	buf.WriteString(formatFloat(v))
+	buf.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpReplacer       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+// validMetricName 指标名只能包含字母、数字、下划线和冒号，不能以数字开头
+func validMetricName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		b := name[i]
+		if !(b == '_' || b == ':' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || i > 0 && '0' <= b && b <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// validLabelName 标签名只能包含字母、数字和下划线，不能以数字开头，__开头的为保留名称
+func validLabelName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "__") || name == "le" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		b := name[i]
+		if !(b == '_' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || i > 0 && '0' <= b && b <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Metrics core的指标注册表，第一次调用时创建并注册go运行时的指标
+func (c *Core) Metrics() *MetricsRegistry {
+	c.metricsOnce.Do(func() {
+		c.metrics = NewMetricsRegistry()
+		c.metrics.RegisterRuntimeMetrics()
+	})
+	return c.metrics
+}
+
+// MountMetrics 在path注册GET和HEAD路由输出core的指标，例如 core.MountMetrics("/metrics")
+// 指标接口不经过core上注册的中间件，抓取请求不会计入访问日志和请求指标
+func (c *Core) MountMetrics(path string) {
+	if err := c.addRawRoute(path, []ControllerHandler{c.Metrics().Handler()}); err != nil {
+		panic("mount metrics: " + err.Error())
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"github.com/iceymoss/axis/framework"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AccessLog 访问日志，在请求处理完之后记录方法、路由、状态码、字节数、耗时和错误
+// 使用ctx.Timing记录了处理阶段时，加上timings字段，例如 db=12.5ms render=1.2ms
+// 5xx和处理出错的请求记录为error级别，4xx记录为warn级别
+func AccessLog() framework.ControllerHandler {
+	return func(c *framework.Context) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Status()
+		if err != nil && !c.Written() {
+			// 错误由core的错误处理函数输出，默认为500
+			status = http.StatusInternalServerError
+		}
+		request := c.GetRequest()
+		fields := []interface{}{
+			"method", request.Method,
+			"path", request.URL.Path,
+			"status", status,
+			"bytes", c.ResponseSize(),
+			"latency", time.Since(start),
+		}
+		if timings := c.Timings(); len(timings) > 0 {
+			fields = append(fields, "timings", formatTimings(timings))
+		}
+		if err != nil {
+			fields = append(fields, "error", err)
+		} else if errs := c.Errors(); len(errs) > 0 {
+			fields = append(fields, "error", errs[len(errs)-1])
+		}
+
+		logger := c.Logger()
+		switch {
+		case err != nil || status >= http.StatusInternalServerError:
+			logger.Error("access", fields...)
+		case status >= http.StatusBadRequest:
+			logger.Warn("access", fields...)
+		default:
+			logger.Info("access", fields...)
+		}
+		return err
+	}
+}
+
+// formatTimings 把处理阶段的耗时编码为一个日志字段
+func formatTimings(timings []framework.TimingMetric) string {
+	parts := make([]string, 0, len(timings))
+	for _, t := range timings {
+		parts = append(parts, t.Name+"="+t.Duration.Round(time.Microsecond).String())
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"github.com/iceymoss/axis/framework"
+	"net/http"
+)
+
+// BasicAuth HTTP基本认证，accounts为用户名到密码的映射，认证失败时返回401
+// 密码比较使用固定时间，避免通过响应时间猜测密码
+func BasicAuth(accounts map[string]string) framework.ControllerHandler {
+	hashed := make(map[string][32]byte, len(accounts))
+	for user, password := range accounts {
+		hashed[user] = sha256.Sum256([]byte(password))
+	}
+	return func(c *framework.Context) error {
+		user, password, ok := c.GetRequest().BasicAuth()
+		if ok {
+			expected, found := hashed[user]
+			actual := sha256.Sum256([]byte(password))
+			if subtle.ConstantTimeCompare(expected[:], actual[:]) == 1 && found {
+				return c.Next()
+			}
+		}
+		c.SetHeader("WWW-Authenticate", `Basic realm="Authorization Required", charset="UTF-8"`)
+		c.SetStatus(http.StatusUnauthorized)
+		return nil
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"github.com/iceymoss/axis/framework"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Metrics 按路由记录请求数、错误和耗时(RED指标)，以及正在处理的请求数和响应大小
+// ctx.Timing记录的处理阶段按名称记录耗时，阶段名称应该是固定的几个值
+// 标签使用路由规则而不是原始url，例如 /subject/:id，避免标签值无限增长，没有匹配到路由的请求route为空
+// 同一个registry只能调用一次，例如 core.Use(middleware.Metrics(core.Metrics()))
+func Metrics(registry *framework.MetricsRegistry) framework.ControllerHandler {
+	requests := registry.NewCounter("axis_http_requests_total",
+		"Total number of HTTP requests by method, route and status code.", "method", "route", "status")
+	duration := registry.NewHistogram("axis_http_request_duration_seconds",
+		"HTTP request latency in seconds.", framework.DefaultBuckets, "method", "route")
+	size := registry.NewHistogram("axis_http_response_size_bytes",
+		"HTTP response body size in bytes.", framework.ExponentialBuckets(100, 10, 7), "method", "route")
+	phases := registry.NewHistogram("axis_http_phase_duration_seconds",
+		"Duration of named request phases recorded with Context.Timing in seconds.", framework.DefaultBuckets, "method", "route", "phase")
+	inflight := registry.NewGauge("axis_http_requests_in_flight",
+		"Number of HTTP requests currently being served.")
+
+	return func(c *framework.Context) error {
+		start := time.Now()
+		inflight.Inc()
+		defer inflight.Dec()
+
+		err := c.Next()
+
+		status := c.Status()
+		if err != nil && !c.Written() {
+			// 错误由core的错误处理函数输出，默认为500
+			status = http.StatusInternalServerError
+		}
+		method := c.GetRequest().Method
+		route := c.RoutePattern()
+		requests.Inc(method, route, strconv.Itoa(status))
+		duration.ObserveDuration(time.Since(start), method, route)
+		size.Observe(float64(c.ResponseSize()), method, route)
+		for _, t := range c.Timings() {
+			phases.ObserveDuration(t.Duration, method, route, t.Name)
+		}
+		return err
+	}
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iceymoss/axis/framework"
+)
+
+func TestMetricsUnmatchedRoute(t *testing.T) {
+	core := framework.NewCore()
+	core.SetMode(framework.TestMode)
+	core.Use(Metrics(core.Metrics()))
+	core.Get("/subject/:id", func(c *framework.Context) error {
+		c.Text("ok")
+		return nil
+	})
+	core.MountMetrics("/metrics")
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{"GET", "/subject/1", http.StatusOK},
+		{"GET", "/missing", http.StatusNotFound},
+		{"POST", "/subject/1", http.StatusNotFound},
+		{"HEAD", "/metrics", http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		core.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.status {
+			t.Fatalf("%s %s: status = %d, want %d", tt.method, tt.path, rec.Code, tt.status)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	core.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`axis_http_requests_total{method="GET",route="/subject/:id",status="200"} 1`,
+		`axis_http_requests_total{method="GET",route="",status="404"} 1`,
+		`axis_http_requests_total{method="POST",route="",status="404"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %s", want)
+		}
+	}
+	if strings.Contains(body, `route="/metrics"`) {
+		t.Error("metrics endpoint should not pass through the metrics middleware")
+	}
+}
@@ -1,17 +1,180 @@
 package middleware
 
-import "github.com/iceymoss/axis/framework"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/iceymoss/axis/framework"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+)
 
+// PanicError 处理函数panic时返回给core的错误，由core的错误处理函数输出500
+type PanicError struct {
+	Value interface{} // recover得到的值
+	Stack []byte      // 带源码行的调用栈
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap panic的值是error时返回它，可以用errors.Is判断
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// RecoveryFunc panic时的回调，例如发送告警，在记录日志之后调用
+type RecoveryFunc func(c *framework.Context, err *PanicError)
+
+// RecoveryConfig Recovery中间件的配置
+type RecoveryConfig struct {
+	// RedactHeaders 日志中隐藏值的请求头，为空时隐藏Authorization、Proxy-Authorization和Cookie
+	RedactHeaders []string
+	// OnPanic panic时的回调，客户端断开连接引起的panic不会调用
+	OnPanic RecoveryFunc
+}
+
+// 默认在日志中隐藏的请求头
+var defaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// Recovery 捕获处理函数的panic，记录调用栈并交给core的错误处理函数返回500
 func Recovery() framework.ControllerHandler {
-	return func(c *framework.Context) error {
+	return RecoveryWithConfig(RecoveryConfig{})
+}
+
+// RecoveryWithConfig 使用指定配置的Recovery中间件
+// 写响应时客户端断开连接(broken pipe、connection reset)引起的panic只记录warn日志，不再输出响应
+// panic的值为http.ErrAbortHandler时继续panic，由net/http中断连接
+func RecoveryWithConfig(config RecoveryConfig) framework.ControllerHandler {
+	redact := config.RedactHeaders
+	if len(redact) == 0 {
+		redact = defaultRedactHeaders
+	}
+	return func(c *framework.Context) (err error) {
 		defer func() {
-			// 核心在增加这个recover机制，捕获c.Next()出现的panic
-			if err := recover(); err != nil {
-				c.Json(err)
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			request := dumpRequest(c.GetRequest(), redact)
+			if isBrokenPipe(p) {
+				c.Logger().Warn("client disconnected", "error", p, "request", request)
+				err = nil
+				return
 			}
+
+			panicErr := &PanicError{Value: p, Stack: stack(3)}
+			if c.Mode() == framework.DebugMode {
+				c.Debugf("[Recovery] panic recovered:\n%s\n%v\n%s", request, p, panicErr.Stack)
+				c.Logger().Error("panic recovered", "panic", p)
+			} else {
+				c.Logger().Error("panic recovered", "panic", p, "request", request, "stack", string(panicErr.Stack))
+			}
+			if config.OnPanic != nil {
+				config.OnPanic(c, panicErr)
+			}
+			err = panicErr
 		}()
 		// 使用next执行具体的业务逻辑
-		c.Next()
-		return nil
+		return c.Next()
+	}
+}
+
+// isBrokenPipe panic是否由客户端断开连接引起，这种情况不需要调用栈
+func isBrokenPipe(p interface{}) bool {
+	err, ok := p.(error)
+	if !ok {
+		return false
+	}
+	if errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+}
+
+// dumpRequest 请求行和请求头，redact中的请求头的值替换为*
+func dumpRequest(req *http.Request, redact []string) string {
+	dump, _ := httputil.DumpRequest(req, false)
+	lines := strings.Split(strings.TrimSpace(string(dump)), "\r\n")
+	for i, line := range lines {
+		name, _, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		for _, header := range redact {
+			if strings.EqualFold(strings.TrimSpace(name), header) {
+				lines[i] = name + ": *"
+				break
+			}
+		}
+	}
+	return strings.Join(lines, "\r\n")
+}
+
+var (
+	dunno     = []byte("???")
+	centerDot = []byte("·")
+	dot       = []byte(".")
+	slash     = []byte("/")
+)
+
+// stack 跳过skip层之后的调用栈，每一层带有函数名和源码行
+func stack(skip int) []byte {
+	buf := new(bytes.Buffer)
+	// 读取过的源文件缓存起来，同一个文件通常出现多次
+	var lines [][]byte
+	var lastFile string
+	for i := skip; ; i++ {
+		pc, file, line, ok := runtime.Caller(i)
+		if !ok {
+			break
+		}
+		fmt.Fprintf(buf, "%s:%d (0x%x)\n", file, line, pc)
+		if file != lastFile {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				continue
+			}
+			lines = bytes.Split(data, []byte{'\n'})
+			lastFile = file
+		}
+		fmt.Fprintf(buf, "\t%s: %s\n", function(pc), source(lines, line))
+	}
+	return buf.Bytes()
+}
+
+// source 第n行的源码，去掉首尾空白
+func source(lines [][]byte, n int) []byte {
+	n-- // 栈中的行号从1开始
+	if n < 0 || n >= len(lines) {
+		return dunno
+	}
+	return bytes.TrimSpace(lines[n])
+}
+
+// function 函数名，去掉包路径，例如 main.(*T).Handler 输出为 (*T).Handler
+func function(pc uintptr) []byte {
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return dunno
+	}
+	name := []byte(fn.Name())
+	if lastSlash := bytes.LastIndex(name, slash); lastSlash >= 0 {
+		name = name[lastSlash+1:]
+	}
+	if period := bytes.Index(name, dot); period >= 0 {
+		name = name[period+1:]
 	}
+	name = bytes.ReplaceAll(name, centerDot, dot)
+	return name
 }
@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/iceymoss/axis/framework"
+)
+
+// newRecoveryCore 使用Recovery中间件的core，日志写入logs
+func newRecoveryCore(logs io.Writer, config RecoveryConfig, panicValue interface{}) *framework.Core {
+	core := framework.NewCore()
+	core.SetMode(framework.TestMode)
+	core.SetLogger(framework.NewLogger(logs, framework.LevelDebug, framework.JSONEncoder{}))
+	core.Use(RecoveryWithConfig(config))
+	core.Get("/panic", func(c *framework.Context) error {
+		panic(panicValue) // recovery test panic
+	})
+	return core
+}
+
+func TestRecovery(t *testing.T) {
+	var logs bytes.Buffer
+	var hooked *PanicError
+	core := newRecoveryCore(&logs, RecoveryConfig{
+		OnPanic: func(c *framework.Context, err *PanicError) { hooked = err },
+	}, "boom")
+
+	req := httptest.NewRequest("GET", "/panic?q=1", nil)
+	req.Header.Set("Authorization", "Bearer secret-token")
+	req.Header.Set("Cookie", "session=secret-cookie")
+	req.Header.Set("X-Trace", "visible")
+	rec := httptest.NewRecorder()
+	core.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError || rec.Body.String() != `"inner error"` {
+		t.Fatalf("response = %d %q", rec.Code, rec.Body.String())
+	}
+	if hooked == nil || hooked.Value != "boom" || hooked.Error() != "panic: boom" {
+		t.Fatalf("OnPanic got %v", hooked)
+	}
+	log := logs.String()
+	for _, want := range []string{
+		"panic recovered",
+		"GET /panic?q=1",
+		"Authorization: *",
+		"Cookie: *",
+		"X-Trace: visible",
+		"recovery_test.go",
+		"newRecoveryCore.func1: panic(panicValue) // recovery test panic", // 函数名去掉了包路径，带有源码行
+	} {
+		if !strings.Contains(log, want) {
+			t.Errorf("log missing %q: %s", want, log)
+		}
+	}
+	if strings.Contains(log, "secret") {
+		t.Errorf("log contains redacted header value: %s", log)
+	}
+	// 调用栈从panic的位置开始，不包含Recovery自己的defer和runtime的panic处理
+	first, _, _ := bytes.Cut(hooked.Stack, []byte("\n"))
+	if !bytes.Contains(first, []byte("recovery_test.go")) {
+		t.Errorf("stack starts at %s", first)
+	}
+}
+
+func TestRecoveryErrorHandler(t *testing.T) {
+	core := newRecoveryCore(io.Discard, RecoveryConfig{}, io.ErrUnexpectedEOF)
+	var handled error
+	core.SetErrorHandler(func(c *framework.Context, err error) {
+		handled = err
+		c.SetStatus(http.StatusServiceUnavailable).Text("handled")
+	})
+	rec := httptest.NewRecorder()
+	core.ServeHTTP(rec, httptest.NewRequest("GET", "/panic", nil))
+
+	var panicErr *PanicError
+	if !errors.As(handled, &panicErr) || !errors.Is(handled, io.ErrUnexpectedEOF) {
+		t.Fatalf("error handler got %v", handled)
+	}
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "handled" {
+		t.Fatalf("response = %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRecoveryRedactHeaders(t *testing.T) {
+	var logs bytes.Buffer
+	core := newRecoveryCore(&logs, RecoveryConfig{RedactHeaders: []string{"x-api-key"}}, "boom")
+	req := httptest.NewRequest("GET", "/panic", nil)
+	req.Header.Set("X-Api-Key", "secret-key")
+	req.Header.Set("Authorization", "Basic visible")
+	core.ServeHTTP(httptest.NewRecorder(), req)
+
+	log := logs.String()
+	if strings.Contains(log, "secret-key") || !strings.Contains(log, "X-Api-Key: *") {
+		t.Errorf("custom header not redacted: %s", log)
+	}
+	// 自定义后不再使用默认的列表
+	if !strings.Contains(log, "Authorization: Basic visible") {
+		t.Errorf("authorization redacted with custom list: %s", log)
+	}
+}
+
+func TestRecoveryBrokenPipe(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{name: "epipe", value: &net.OpError{Op: "write", Net: "tcp", Err: syscall.EPIPE}},
+		{name: "connection reset", value: fmt.Errorf("write response: %w", syscall.ECONNRESET)},
+		{name: "message only", value: errors.New("write tcp 127.0.0.1:80: broken pipe")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			called := false
+			core := newRecoveryCore(&logs, RecoveryConfig{
+				OnPanic: func(*framework.Context, *PanicError) { called = true },
+			}, tt.value)
+			handled := false
+			core.SetErrorHandler(func(*framework.Context, error) { handled = true })
+			core.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+
+			if called || handled {
+				t.Fatalf("client disconnect reported as panic, OnPanic=%v errorHandler=%v", called, handled)
+			}
+			log := logs.String()
+			if !strings.Contains(log, "client disconnected") || !strings.Contains(log, `"level":"warn"`) || strings.Contains(log, "recovery_test.go") {
+				t.Fatalf("log = %s", log)
+			}
+		})
+	}
+}
+
+func TestRecoveryAbortHandler(t *testing.T) {
+	called := false
+	core := newRecoveryCore(io.Discard, RecoveryConfig{
+		OnPanic: func(*framework.Context, *PanicError) { called = true },
+	}, http.ErrAbortHandler)
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Fatalf("recovered %v, want http.ErrAbortHandler", p)
+		}
+		if called {
+			t.Fatal("OnPanic called for http.ErrAbortHandler")
+		}
+	}()
+	core.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+}
+
+func TestRecoveryDebugMode(t *testing.T) {
+	var logs, debug bytes.Buffer
+	old := framework.DebugWriter
+	framework.DebugWriter = &debug
+	defer func() { framework.DebugWriter = old }()
+
+	core := newRecoveryCore(&logs, RecoveryConfig{}, "boom")
+	core.SetMode(framework.DebugMode)
+	rec := httptest.NewRecorder()
+	core.ServeHTTP(rec, httptest.NewRequest("GET", "/panic", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	// debug模式下调用栈输出到调试信息，日志中只有panic的值
+	if !strings.Contains(debug.String(), "[Recovery] panic recovered") || !strings.Contains(debug.String(), "recovery test panic") {
+		t.Errorf("debug output = %s", debug.String())
+	}
+	if log := logs.String(); !strings.Contains(log, "boom") || strings.Contains(log, "recovery_test.go") {
+		t.Errorf("log = %s", log)
+	}
+}
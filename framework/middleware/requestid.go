@@ -0,0 +1,62 @@
+package middleware
+
+import "github.com/iceymoss/axis/framework"
+
+// RequestIDConfig RequestID中间件的配置
+type RequestIDConfig struct {
+	// Header 读取和返回请求id的请求头，默认为X-Request-ID
+	Header string
+	// Generator 生成请求id，默认为framework.NewRequestID
+	Generator func() string
+	// Validator 检查客户端传入的请求id，不通过时重新生成，默认为validRequestID
+	Validator func(id string) bool
+}
+
+// RequestID 请求id中间件，使用RequestIDConfig的默认配置
+func RequestID() framework.ControllerHandler {
+	return RequestIDWithConfig(RequestIDConfig{})
+}
+
+// RequestIDWithConfig 请求id中间件
+// 请求头中有合法的请求id时沿用，否则生成新的id，保存到ctx、日志字段和响应头中
+// 需要放在AccessLog之前，访问日志才会带上请求id
+func RequestIDWithConfig(config RequestIDConfig) framework.ControllerHandler {
+	if config.Header == "" {
+		config.Header = framework.HeaderRequestID
+	}
+	if config.Generator == nil {
+		config.Generator = framework.NewRequestID
+	}
+	if config.Validator == nil {
+		config.Validator = validRequestID
+	}
+	return func(c *framework.Context) error {
+		id := c.GetRequest().Header.Get(config.Header)
+		if id == "" || !config.Validator(id) {
+			id = config.Generator()
+		}
+		c.SetRequestID(id)
+		c.GetResponse().Header().Set(config.Header, id)
+		return c.Next()
+	}
+}
+
+// 客户端传入的请求id的最大长度
+const maxRequestIDLength = 128
+
+// validRequestID 长度不超过128，只包含字母、数字和-_.:，防止日志注入
+func validRequestID(id string) bool {
+	if len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		b := id[i]
+		switch {
+		case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9':
+		case b == '-' || b == '_' || b == '.' || b == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
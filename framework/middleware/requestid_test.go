@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iceymoss/axis/framework"
+)
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"", true},
+		{"01ARYZ6S41TSV4RRFFQ69G5FAV", true},
+		{"0f3c9b7e-8d2a-4c1b-9e6f-2a7d5c3b1e90", true},
+		{"svc.order:123_abc-DEF", true},
+		{strings.Repeat("a", 128), true},
+		{strings.Repeat("a", 129), false},
+		{strings.Repeat("a", 4096), false},
+		{"has space", false},
+		{"line\nbreak", false},
+		{"carriage\rreturn", false},
+		{"tab\t", false},
+		{"quote\"", false},
+		{"key=value", false},
+		{"a/b", false},
+		{"a,b", false},
+		{"null\x00byte", false},
+		{"ünïcode", false},
+		{"emoji😀", false},
+	}
+	for _, tt := range tests {
+		if got := validRequestID(tt.id); got != tt.want {
+			t.Errorf("validRequestID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   RequestIDConfig
+		header   string // 读取和返回请求id的请求头
+		incoming string
+		want     string // 为空表示应该重新生成
+	}{
+		{name: "generate", header: framework.HeaderRequestID},
+		{name: "keep valid", header: framework.HeaderRequestID, incoming: "client-id.1", want: "client-id.1"},
+		{name: "replace invalid", header: framework.HeaderRequestID, incoming: "bad id\n"},
+		{name: "replace too long", header: framework.HeaderRequestID, incoming: strings.Repeat("a", 129)},
+		{name: "custom header", config: RequestIDConfig{Header: "X-Trace-Id"}, header: "X-Trace-Id", incoming: "abc", want: "abc"},
+		{name: "custom generator", config: RequestIDConfig{Generator: func() string { return "generated" }}, header: framework.HeaderRequestID, want: "generated"},
+		{name: "custom validator", config: RequestIDConfig{Validator: func(id string) bool { return len(id) == 3 }}, header: framework.HeaderRequestID, incoming: "abcd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core := framework.NewCore()
+			core.SetMode(framework.TestMode)
+			core.Use(RequestIDWithConfig(tt.config))
+			var seen, fromCtx string
+			core.Get("/", func(c *framework.Context) error {
+				seen = c.RequestID()
+				fromCtx = framework.RequestIDFromContext(c)
+				return nil
+			})
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(tt.header, tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+			core.ServeHTTP(rec, req)
+
+			if seen == "" || seen != fromCtx || rec.Header().Get(tt.header) != seen {
+				t.Fatalf("request id = %q, context = %q, response header = %q", seen, fromCtx, rec.Header().Get(tt.header))
+			}
+			if tt.want != "" && seen != tt.want {
+				t.Fatalf("request id = %q, want %q", seen, tt.want)
+			}
+			if tt.want == "" && seen == tt.incoming {
+				t.Fatalf("request id %q should have been regenerated", seen)
+			}
+		})
+	}
+}
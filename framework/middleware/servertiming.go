@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"github.com/iceymoss/axis/framework"
+	"time"
+)
+
+// ServerTimingConfig ServerTiming中间件的配置
+type ServerTimingConfig struct {
+	// TrustedNetworks 返回Server-Timing头的来源ip或者CIDR，为空时对所有客户端返回
+	// 耗时会暴露内部实现，公网服务建议只对内网返回，使用连接的地址判断，不信任X-Forwarded-For
+	TrustedNetworks []string
+}
+
+// ServerTiming 对所有客户端返回Server-Timing头，使用ServerTimingConfig的默认配置
+func ServerTiming() framework.ControllerHandler {
+	return ServerTimingWithConfig(ServerTimingConfig{})
+}
+
+// ServerTimingWithConfig 在写入响应之前把ctx.Timing记录的阶段写入Server-Timing头
+// 另外加上total，为从中间件开始到写入响应的耗时，TrustedNetworks不合法时panic
+func ServerTimingWithConfig(config ServerTimingConfig) framework.ControllerHandler {
+	trusted, err := framework.ParseIPNets(config.TrustedNetworks)
+	if err != nil {
+		panic("server timing: " + err.Error())
+	}
+	return func(c *framework.Context) error {
+		if len(trusted) > 0 && !framework.RemoteAddrTrusted(c.GetRequest().RemoteAddr, trusted) {
+			return c.Next()
+		}
+		start := time.Now()
+		c.BeforeWriteHeader(func() {
+			timings := append(c.Timings(), framework.TimingMetric{Name: "total", Duration: time.Since(start)})
+			c.GetResponse().Header().Add(framework.HeaderServerTiming, framework.FormatServerTiming(timings))
+		})
+		return c.Next()
+	}
+}
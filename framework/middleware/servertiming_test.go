@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iceymoss/axis/framework"
+)
+
+func TestServerTiming(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     ServerTimingConfig
+		remoteAddr string
+		handler    framework.ControllerHandler
+		status     int
+		want       bool
+	}{
+		{name: "written", remoteAddr: "192.0.2.1:1234", status: http.StatusOK, want: true, handler: func(c *framework.Context) error {
+			c.Timing("db").Stop()
+			c.Text("ok")
+			return nil
+		}},
+		{name: "nothing written", remoteAddr: "192.0.2.1:1234", status: http.StatusOK, want: true, handler: func(c *framework.Context) error {
+			c.AddTiming("db", time.Millisecond, "")
+			return nil
+		}},
+		{name: "error handler", remoteAddr: "192.0.2.1:1234", status: http.StatusInternalServerError, want: true, handler: func(c *framework.Context) error {
+			c.AddTiming("db", time.Millisecond, "")
+			return errors.New("boom")
+		}},
+		{name: "trusted", config: ServerTimingConfig{TrustedNetworks: []string{"127.0.0.1", "::1"}}, remoteAddr: "[::1]:1234",
+			status: http.StatusOK, want: true, handler: func(c *framework.Context) error {
+				c.AddTiming("db", time.Millisecond, "")
+				return nil
+			}},
+		{name: "untrusted", config: ServerTimingConfig{TrustedNetworks: []string{"127.0.0.1", "::1"}}, remoteAddr: "10.1.2.3:1234",
+			status: http.StatusOK, handler: func(c *framework.Context) error {
+				c.AddTiming("db", time.Millisecond, "")
+				return nil
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core := framework.NewCore()
+			core.SetMode(framework.TestMode)
+			core.Use(ServerTimingWithConfig(tt.config))
+			core.Get("/", tt.handler)
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			rec := httptest.NewRecorder()
+			core.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			header := rec.Header().Get(framework.HeaderServerTiming)
+			if !tt.want {
+				if header != "" {
+					t.Fatalf("unexpected Server-Timing %q", header)
+				}
+				return
+			}
+			if !strings.Contains(header, "db;dur=") || !strings.Contains(header, "total;dur=") {
+				t.Fatalf("Server-Timing = %q", header)
+			}
+		})
+	}
+}
@@ -1,16 +1,15 @@
 package middleware
 
 import (
-	"fmt"
 	"github.com/iceymoss/axis/framework"
 )
 
 func Test1() framework.ControllerHandler {
 	// 使用函数回调
 	return func(c *framework.Context) error {
-		fmt.Println("middleware pre test1")
+		c.Debugf("middleware pre test1")
 		c.Next()
-		fmt.Println("middleware post test1")
+		c.Debugf("middleware post test1")
 		return nil
 	}
 }
@@ -18,9 +17,9 @@ func Test1() framework.ControllerHandler {
 func Test2() framework.ControllerHandler {
 	// 使用函数回调
 	return func(c *framework.Context) error {
-		fmt.Println("middleware pre test2")
+		c.Debugf("middleware pre test2")
 		c.Next()
-		fmt.Println("middleware post test2")
+		c.Debugf("middleware post test2")
 		return nil
 	}
 }
@@ -28,9 +27,9 @@ func Test2() framework.ControllerHandler {
 func Test3() framework.ControllerHandler {
 	// 使用函数回调
 	return func(c *framework.Context) error {
-		fmt.Println("middleware pre test3")
+		c.Debugf("middleware pre test3")
 		c.Next()
-		fmt.Println("middleware post test3")
+		c.Debugf("middleware post test3")
 		return nil
 	}
 }
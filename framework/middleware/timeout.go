@@ -2,9 +2,7 @@ package middleware
 
 import (
 	"context"
-	"fmt"
 	"github.com/iceymoss/axis/framework"
-	"log"
 	"time"
 )
 
@@ -29,10 +27,10 @@ func Timeout(d time.Duration) framework.ControllerHandler {
 
 		select {
 		case p := <-panicChan:
-			log.Println(p)
+			c.Logger().Error("handler panic", "panic", p)
 			c.Json("")
 		case <-finish:
-			fmt.Println("finish")
+			c.Debugf("finish")
 		case <-durationCtx.Done():
 			c.SetHasTimeout()
 			c.Json("time out")
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"github.com/iceymoss/axis/framework"
+	"net/http"
+	"sort"
+)
+
+// Tracing 为每个请求创建server类型的span，名称为方法加路由规则，例如 GET /subject/:id，没有匹配到路由时只有方法
+// 请求头中有合法的traceparent时加入调用方的trace，trace_id和span_id会加入日志字段
+// 需要放在AccessLog之前，访问日志才会带上trace_id
+func Tracing(tracer *framework.Tracer) framework.ControllerHandler {
+	return func(c *framework.Context) error {
+		request := c.GetRequest()
+		parent := c.BaseContext()
+		if sc, ok := framework.ExtractTraceContext(request.Header); ok {
+			parent = framework.ContextWithRemoteSpanContext(parent, sc)
+		}
+		route := c.RoutePattern()
+		attrs := []interface{}{
+			"http.request.method", request.Method,
+			"http.route", route,
+			"url.path", request.URL.Path,
+		}
+		params := c.Params()
+		keys := make([]string, 0, len(params))
+		for key := range params {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			attrs = append(attrs, "http.route.param."+key, params[key])
+		}
+		name := request.Method
+		if route != "" {
+			name += " " + route
+		}
+		ctx, span := tracer.Start(parent, name, framework.SpanKindServer, attrs...)
+		c.SetBaseContext(ctx)
+		sc := span.SpanContext()
+		c.AddLogFields("trace_id", sc.TraceID.String(), "span_id", sc.SpanID.String())
+
+		err := c.Next()
+
+		status := c.Status()
+		if err != nil && !c.Written() {
+			// 错误由core的错误处理函数输出，默认为500
+			status = http.StatusInternalServerError
+		}
+		span.SetAttributes("http.response.status_code", status)
+		if err != nil {
+			span.RecordError(err)
+		} else if status >= http.StatusInternalServerError {
+			span.SetStatus(framework.SpanStatusError, http.StatusText(status))
+		}
+		span.End()
+		return err
+	}
+}
@@ -0,0 +1,141 @@
+package framework
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
+)
+
+// 运行模式
+const (
+	// DebugMode 开发模式，打印路由和调试信息，错误页面包含错误详情，每次渲染都重新加载模版
+	DebugMode = "debug"
+	// ReleaseMode 生产模式，不输出调试信息
+	ReleaseMode = "release"
+	// TestMode 测试模式，不输出调试信息，日志不带时间，方便比较输出
+	TestMode = "test"
+)
+
+// EnvAxisMode 设置运行模式的环境变量
+const EnvAxisMode = "AXIS_MODE"
+
+// DebugWriter 调试信息的输出，默认为标准输出
+var DebugWriter io.Writer = os.Stdout
+
+var axisMode atomic.Value
+
+func init() {
+	SetMode(os.Getenv(EnvAxisMode))
+}
+
+// ParseMode 检查运行模式是否有效，为空时返回debug模式，用于检查配置文件等外部输入
+func ParseMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return DebugMode, nil
+	case DebugMode, ReleaseMode, TestMode:
+		return mode, nil
+	}
+	return "", fmt.Errorf("axis mode unknown: %s (available mode: debug release test)", mode)
+}
+
+// SetMode 设置全局的运行模式，为空时使用debug模式，没有单独设置模式的Core使用全局模式
+// 模式无效时panic，外部输入需要先使用ParseMode检查
+func SetMode(mode string) {
+	mode, err := ParseMode(mode)
+	if err != nil {
+		panic(err.Error())
+	}
+	axisMode.Store(mode)
+	if !customLoggerSet.Load() {
+		defaultLogger.Store(Logger(newModeLogger(mode)))
+	}
+}
+
+// Mode 全局的运行模式
+func Mode() string {
+	return axisMode.Load().(string)
+}
+
+// IsDebugging 全局的运行模式是否为debug模式
+func IsDebugging() bool {
+	return Mode() == DebugMode
+}
+
+// DebugPrintf 在debug模式下输出调试信息
+func DebugPrintf(format string, values ...interface{}) {
+	if IsDebugging() {
+		debugPrintf(format, values...)
+	}
+}
+
+func debugPrintf(format string, values ...interface{}) {
+	if !strings.HasSuffix(format, "\n") {
+		format += "\n"
+	}
+	fmt.Fprintf(DebugWriter, "[AXIS-debug] "+format, values...)
+}
+
+// SetMode 设置core的运行模式，会同时调整模版是否每次重新加载和没有单独设置时使用的日志
+func (c *Core) SetMode(mode string) {
+	switch mode {
+	case DebugMode, ReleaseMode, TestMode:
+	default:
+		panic("axis mode unknown: " + mode + " (available mode: debug release test)")
+	}
+	c.mode = mode
+	c.modeLogger = newModeLogger(mode)
+	c.html.Reload(mode == DebugMode)
+}
+
+// Mode core的运行模式，没有单独设置时为全局模式
+func (c *Core) Mode() string {
+	if c.mode == "" {
+		return Mode()
+	}
+	return c.mode
+}
+
+// IsDebugging core是否为debug模式
+func (c *Core) IsDebugging() bool {
+	return c.Mode() == DebugMode
+}
+
+// debugPrintf core为debug模式时输出调试信息
+func (c *Core) debugPrintf(format string, values ...interface{}) {
+	if c.IsDebugging() {
+		debugPrintf(format, values...)
+	}
+}
+
+// debugPrintRoute 注册路由时输出路由信息
+func (c *Core) debugPrintRoute(method string, url string, handlers []ControllerHandler) {
+	if !c.IsDebugging() || len(handlers) == 0 {
+		return
+	}
+	c.debugPrintf("%-6s %-25s --> %s (%d handlers)", method, url, nameOfFunction(handlers[len(handlers)-1]), len(handlers))
+}
+
+// nameOfFunction 函数的完整名称
+func nameOfFunction(f interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+}
+
+// Mode 处理当前请求的core的运行模式
+func (ctx *Context) Mode() string {
+	if ctx.core == nil {
+		return Mode()
+	}
+	return ctx.core.Mode()
+}
+
+// Debugf 在debug模式下输出调试信息，用于中间件和业务中的调试输出
+func (ctx *Context) Debugf(format string, values ...interface{}) {
+	if ctx.Mode() == DebugMode {
+		debugPrintf(format, values...)
+	}
+}
@@ -0,0 +1,38 @@
+package framework
+
+import "testing"
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		mode    string
+		want    string
+		wantErr bool
+	}{
+		{mode: "", want: DebugMode},
+		{mode: "debug", want: DebugMode},
+		{mode: "release", want: ReleaseMode},
+		{mode: "test", want: TestMode},
+		{mode: "prod", wantErr: true},
+		{mode: "Release", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMode(tt.mode)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseMode(%q) = %q, %v", tt.mode, got, err)
+		}
+	}
+}
+
+func TestSetModeUnknown(t *testing.T) {
+	old := Mode()
+	defer SetMode(old)
+	defer func() {
+		if recover() == nil {
+			t.Fatal("SetMode with unknown mode did not panic")
+		}
+		if Mode() != old {
+			t.Fatalf("mode changed to %s", Mode())
+		}
+	}()
+	SetMode("prod")
+}
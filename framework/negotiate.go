@@ -0,0 +1,183 @@
+package framework
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// RenderFunc 将obj编码为某种格式的输出
+type RenderFunc func(obj interface{}) ([]byte, error)
+
+// renderer 一个已注册的输出格式
+type renderer struct {
+	contentType string
+	render      RenderFunc
+}
+
+// defaultRenderers 框架默认提供的输出格式，顺序即为优先级
+func defaultRenderers() []renderer {
+	return []renderer{
+		{contentType: "application/json", render: json.Marshal},
+		{contentType: "application/xml", render: xml.Marshal},
+		{contentType: "application/x-yaml", render: yaml.Marshal},
+		{contentType: "application/yaml", render: yaml.Marshal},
+		{contentType: "text/plain", render: renderText},
+		{contentType: "text/xml", render: xml.Marshal},
+	}
+}
+
+func renderText(obj interface{}) ([]byte, error) {
+	return []byte(fmt.Sprint(obj)), nil
+}
+
+// SetRenderer 注册或者替换某个Content-Type的输出格式，新注册的格式优先级最低
+func (c *Core) SetRenderer(contentType string, render RenderFunc) {
+	contentType = strings.ToLower(contentType)
+	for i := range c.renderers {
+		if c.renderers[i].contentType == contentType {
+			c.renderers[i].render = render
+			return
+		}
+	}
+	c.renderers = append(c.renderers, renderer{contentType: contentType, render: render})
+}
+
+// acceptRange Accept头中的一个媒体类型范围，形如 text/*;q=0.8
+type acceptRange struct {
+	typ     string
+	subtype string
+	q       float64
+}
+
+// match 判断媒体类型是否落在范围内，返回匹配的精确程度，-1表示不匹配
+func (a acceptRange) match(typ, subtype string) int {
+	switch {
+	case a.typ == "*" && a.subtype == "*":
+		return 0
+	case a.typ == typ && a.subtype == "*":
+		return 1
+	case a.typ == typ && a.subtype == subtype:
+		return 2
+	}
+	return -1
+}
+
+// parseAccept 解析Accept头，忽略格式错误的条目
+func parseAccept(header string) []acceptRange {
+	ranges := make([]acceptRange, 0, 4)
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		typ, subtype, ok := strings.Cut(mediaType, "/")
+		if !ok || typ == "" || subtype == "" || (typ == "*" && subtype != "*") {
+			continue
+		}
+
+		q := 1.0
+		valid := true
+		for _, param := range params[1:] {
+			key, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.ToLower(strings.TrimSpace(key)) != "q" {
+				continue
+			}
+			f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+			if err != nil || f < 0 || f > 1 {
+				valid = false
+				break
+			}
+			q = f
+		}
+		if !valid {
+			continue
+		}
+		ranges = append(ranges, acceptRange{typ: typ, subtype: subtype, q: q})
+	}
+	return ranges
+}
+
+// NegotiateFormat 根据请求的Accept头从offered中选出最合适的格式
+// Accept为空时返回第一个offered，没有可接受的格式时返回空字符串
+func (ctx *Context) NegotiateFormat(offered ...string) string {
+	if len(offered) == 0 {
+		return ""
+	}
+	accept, _ := ctx.Header("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return offered[0]
+	}
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		return offered[0]
+	}
+
+	best := ""
+	bestQ := 0.0
+	for _, offer := range offered {
+		typ, subtype, ok := strings.Cut(strings.ToLower(offer), "/")
+		if !ok {
+			continue
+		}
+		// 以最精确匹配的范围的q值作为该格式的权重
+		q, precision := 0.0, -1
+		for _, r := range ranges {
+			if p := r.match(typ, subtype); p > precision {
+				q, precision = r.q, p
+			}
+		}
+		// q值相同时保留先出现的格式
+		if precision >= 0 && q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// renderers 获取当前可用的输出格式
+func (ctx *Context) renderers() []renderer {
+	if ctx.core != nil {
+		return ctx.core.renderers
+	}
+	return defaultRenderers()
+}
+
+// Negotiate 根据Accept头选择已注册的输出格式输出obj，没有可接受的格式时返回406，编码失败时记录错误
+func (ctx *Context) Negotiate(status int, obj interface{}) IResponse {
+	renderers := ctx.renderers()
+	offered := make([]string, 0, len(renderers))
+	for _, r := range renderers {
+		offered = append(offered, r.contentType)
+	}
+	ctx.responseWriter.Header().Add("Vary", "Accept")
+
+	format := ctx.NegotiateFormat(offered...)
+	if format == "" {
+		ctx.responseWriter.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		ctx.responseWriter.WriteHeader(http.StatusNotAcceptable)
+		ctx.responseWriter.Write([]byte("not acceptable, available: " + strings.Join(offered, ", ")))
+		return ctx
+	}
+
+	for _, r := range renderers {
+		if r.contentType != format {
+			continue
+		}
+		byt, err := r.render(obj)
+		if err != nil {
+			// 还没有输出响应，交给core的错误处理函数记录并返回500
+			ctx.AddError(fmt.Errorf("render %s: %w", format, err))
+			return ctx
+		}
+		// header需要在状态码之前设置
+		ctx.responseWriter.Header().Set("Content-Type", format)
+		ctx.responseWriter.WriteHeader(status)
+		ctx.responseWriter.Write(byt)
+		break
+	}
+	return ctx
+}
@@ -0,0 +1,105 @@
+package framework
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAccept(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []acceptRange
+	}{
+		{header: "", want: []acceptRange{}},
+		{header: "application/json", want: []acceptRange{{"application", "json", 1}}},
+		{header: "Text/HTML; Q=0.5", want: []acceptRange{{"text", "html", 0.5}}},
+		{header: "text/*;q=0.3, */*;q=0", want: []acceptRange{{"text", "*", 0.3}, {"*", "*", 0}}},
+		{header: "text/html;level=1;q=0.7", want: []acceptRange{{"text", "html", 0.7}}},
+		{header: "text/html;q=1.5, text/plain;q=-1, text/xml;q=abc, application/json", want: []acceptRange{{"application", "json", 1}}},
+		{header: "*/json, text, /plain, text/, application/xml", want: []acceptRange{{"application", "xml", 1}}},
+		{header: " , application/json ,", want: []acceptRange{{"application", "json", 1}}},
+	}
+	for _, tt := range tests {
+		if got := parseAccept(tt.header); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseAccept(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	offered := []string{"application/json", "application/xml", "text/plain"}
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{name: "no accept", accept: "", want: "application/json"},
+		{name: "only invalid ranges", accept: "foo", want: "application/json"},
+		{name: "exact", accept: "application/xml", want: "application/xml"},
+		{name: "case insensitive", accept: "TEXT/PLAIN", want: "text/plain"},
+		{name: "highest q", accept: "application/json;q=0.5, text/plain;q=0.9", want: "text/plain"},
+		{name: "same q keeps offer order", accept: "text/plain, application/xml", want: "application/xml"},
+		{name: "type wildcard", accept: "text/*", want: "text/plain"},
+		{name: "any", accept: "*/*", want: "application/json"},
+		{name: "specific beats wildcard", accept: "*/*;q=0.1, application/xml;q=0.5", want: "application/xml"},
+		{name: "q=0 excludes", accept: "application/json;q=0, */*", want: "application/xml"},
+		{name: "q=0 exact overrides wildcard", accept: "text/*, text/plain;q=0, application/xml;q=0.1", want: "application/xml"},
+		{name: "all q=0", accept: "*/*;q=0", want: ""},
+		{name: "not acceptable", accept: "image/png", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			ctx := NewContext(req, httptest.NewRecorder())
+			if got := ctx.NegotiateFormat(offered...); got != tt.want {
+				t.Fatalf("NegotiateFormat(%q) = %q, want %q", tt.accept, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	core := NewCore()
+	core.SetMode(TestMode)
+	core.SetLogger(NewLogger(&strings.Builder{}, LevelError, JSONEncoder{}))
+	core.SetRenderer("application/broken", func(interface{}) ([]byte, error) {
+		return nil, errors.New("cannot encode")
+	})
+	core.Get("/", func(c *Context) error {
+		c.Negotiate(http.StatusCreated, map[string]string{"name": "axis"})
+		return nil
+	})
+	tests := []struct {
+		accept      string
+		status      int
+		contentType string
+		body        string
+	}{
+		{accept: "application/json", status: http.StatusCreated, contentType: "application/json", body: `{"name":"axis"}`},
+		{accept: "application/x-yaml", status: http.StatusCreated, contentType: "application/x-yaml", body: "name: axis\n"},
+		{accept: "image/png", status: http.StatusNotAcceptable, contentType: "text/plain; charset=utf-8"},
+		{accept: "application/broken", status: http.StatusInternalServerError, contentType: "application/json", body: `"inner error"`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", tt.accept)
+		rec := httptest.NewRecorder()
+		core.ServeHTTP(rec, req)
+		if rec.Code != tt.status || rec.Header().Get("Content-Type") != tt.contentType {
+			t.Errorf("Accept %s: status = %d, content type = %q", tt.accept, rec.Code, rec.Header().Get("Content-Type"))
+		}
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("Accept %s: body = %q, want %q", tt.accept, rec.Body, tt.body)
+		}
+		if rec.Header().Get("Vary") != "Accept" {
+			t.Errorf("Accept %s: Vary = %q", tt.accept, rec.Header().Get("Vary"))
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package framework
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PROXY protocol v2的头部签名，见 https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// ErrProxyHeader PROXY protocol头部格式错误
+var ErrProxyHeader = errors.New("invalid PROXY protocol header")
+
+// proxyListener 接受带PROXY protocol头部的连接，连接的RemoteAddr为头部中的客户端地址
+type proxyListener struct {
+	net.Listener
+	trusted []*net.IPNet  // 允许发送PROXY头的来源，为空时信任所有来源
+	timeout time.Duration // 读取PROXY头的超时时间
+}
+
+// newProxyListener 包装listener，trusted为允许发送PROXY头的ip或者CIDR
+func newProxyListener(ln net.Listener, trusted []string, timeout time.Duration) (*proxyListener, error) {
+	nets, err := ParseIPNets(trusted)
+	if err != nil {
+		return nil, err
+	}
+	return &proxyListener{Listener: ln, trusted: nets, timeout: timeout}, nil
+}
+
+// ParseIPNets 解析ip或者CIDR列表，单个ip按/32或者/128处理
+func ParseIPNets(list []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// Accept 接受连接，PROXY头在第一次读取或者获取地址时才解析，不阻塞Accept
+func (l *proxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.isTrusted(conn.RemoteAddr()) {
+		return conn, nil
+	}
+	return &proxyConn{Conn: conn, reader: bufio.NewReader(conn), timeout: l.timeout}, nil
+}
+
+// isTrusted 判断来源是否允许发送PROXY头，unix socket总是信任
+func (l *proxyListener) isTrusted(addr net.Addr) bool {
+	if len(l.trusted) == 0 {
+		return true
+	}
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return true
+	}
+	for _, ipNet := range l.trusted {
+		if ipNet.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyConn 带PROXY protocol头部的连接
+type proxyConn struct {
+	net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+
+	once   sync.Once
+	remote net.Addr
+	local  net.Addr
+	err    error
+}
+
+// init 读取并解析PROXY头，只执行一次
+func (c *proxyConn) init() {
+	c.once.Do(func() {
+		if c.timeout > 0 {
+			c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+			defer c.Conn.SetReadDeadline(time.Time{})
+		}
+		c.remote, c.local, c.err = readProxyHeader(c.reader)
+		if c.err != nil {
+			c.err = fmt.Errorf("%w from %s: %v", ErrProxyHeader, c.Conn.RemoteAddr(), c.err)
+		}
+	})
+}
+
+// Read 读取PROXY头之后的数据，头部错误时返回错误
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr 客户端地址，PROXY头中没有地址时为代理的地址
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.init()
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr 客户端连接的目标地址，PROXY头中没有地址时为本地地址
+func (c *proxyConn) LocalAddr() net.Addr {
+	c.init()
+	if c.local != nil {
+		return c.local
+	}
+	return c.Conn.LocalAddr()
+}
+
+// readProxyHeader 读取v1或者v2格式的PROXY头，返回客户端地址和目标地址
+// LOCAL命令和UNKNOWN协议返回nil地址，表示使用连接本身的地址
+func readProxyHeader(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch first[0] {
+	case 'P':
+		return readProxyHeaderV1(r)
+	case proxyV2Signature[0]:
+		return readProxyHeaderV2(r)
+	}
+	return nil, nil, errors.New("missing header")
+}
+
+// readProxyHeaderV1 解析文本格式，例如 PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n
+func readProxyHeaderV1(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	// v1头部最长107个字节
+	var line []byte
+	for len(line) < 107 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, errors.New("v1 header too long")
+	}
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if fields[0] != "PROXY" || len(fields) < 2 {
+		return nil, nil, errors.New("bad v1 header")
+	}
+	if fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, errors.New("bad v1 header")
+	}
+	src, err := parseProxyTCPAddr(fields[2], fields[4])
+	if err != nil {
+		return nil, nil, err
+	}
+	dst, err := parseProxyTCPAddr(fields[3], fields[5])
+	if err != nil {
+		return nil, nil, err
+	}
+	return src, dst, nil
+}
+
+func parseProxyTCPAddr(ip string, port string) (*net.TCPAddr, error) {
+	addr := &net.TCPAddr{IP: net.ParseIP(ip)}
+	if addr.IP == nil {
+		return nil, fmt.Errorf("bad address %q", ip)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("bad port %q", port)
+	}
+	addr.Port = int(p)
+	return addr, nil
+}
+
+// readProxyHeaderV2 解析二进制格式
+func readProxyHeaderV2(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	head := make([]byte, 16)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, nil, err
+	}
+	if !bytes.Equal(head[:12], proxyV2Signature) {
+		return nil, nil, errors.New("bad v2 signature")
+	}
+	if head[12]>>4 != 2 {
+		return nil, nil, fmt.Errorf("unsupported version %d", head[12]>>4)
+	}
+	body := make([]byte, binary.BigEndian.Uint16(head[14:16]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, nil, err
+	}
+
+	command := head[12] & 0x0f
+	switch command {
+	case 0x0:
+		// LOCAL，例如代理自己的健康检查
+		return nil, nil, nil
+	case 0x1:
+	default:
+		return nil, nil, fmt.Errorf("unsupported command %d", command)
+	}
+
+	// 地址之后可能有TLV扩展，忽略
+	switch head[13] {
+	case 0x11, 0x12: // TCP或UDP over IPv4
+		if len(body) < 12 {
+			return nil, nil, errors.New("short v2 ipv4 address")
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:4]), Port: int(binary.BigEndian.Uint16(body[8:10]))},
+			&net.TCPAddr{IP: net.IP(body[4:8]), Port: int(binary.BigEndian.Uint16(body[10:12]))}, nil
+	case 0x21, 0x22: // TCP或UDP over IPv6
+		if len(body) < 36 {
+			return nil, nil, errors.New("short v2 ipv6 address")
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:16]), Port: int(binary.BigEndian.Uint16(body[32:34]))},
+			&net.TCPAddr{IP: net.IP(body[16:32]), Port: int(binary.BigEndian.Uint16(body[34:36]))}, nil
+	case 0x31, 0x32: // unix socket
+		if len(body) < 216 {
+			return nil, nil, errors.New("short v2 unix address")
+		}
+		return &net.UnixAddr{Net: "unix", Name: string(bytes.TrimRight(body[0:108], "\x00"))},
+			&net.UnixAddr{Net: "unix", Name: string(bytes.TrimRight(body[108:216], "\x00"))}, nil
+	}
+	// UNSPEC或者未知协议
+	return nil, nil, nil
+}
@@ -0,0 +1,274 @@
+package framework
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// proxyV2Header 生成v2头部，length为负数时使用body的长度
+func proxyV2Header(verCmd byte, family byte, body []byte, length int) []byte {
+	if length < 0 {
+		length = len(body)
+	}
+	b := append([]byte{}, proxyV2Signature...)
+	b = append(b, verCmd, family)
+	b = binary.BigEndian.AppendUint16(b, uint16(length))
+	return append(b, body...)
+}
+
+func proxyV2IPv4Body() []byte {
+	body := []byte{192, 168, 0, 1, 10, 0, 0, 1}
+	body = binary.BigEndian.AppendUint16(body, 56324)
+	return binary.BigEndian.AppendUint16(body, 443)
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	ipv6 := make([]byte, 36)
+	copy(ipv6, net.ParseIP("2001:db8::1"))
+	copy(ipv6[16:], net.ParseIP("2001:db8::2"))
+	binary.BigEndian.PutUint16(ipv6[32:], 1234)
+	binary.BigEndian.PutUint16(ipv6[34:], 443)
+	withTLV := append(proxyV2IPv4Body(), 0x04, 0x00, 0x03, 'a', 'b', 'c')
+
+	tests := []struct {
+		name    string
+		input   []byte
+		src     string // 为空表示没有地址
+		dst     string
+		wantErr bool
+		rest    string // 头部之后剩余的数据
+	}{
+		{name: "v1 tcp4", input: []byte("PROXY TCP4 192.168.0.1 10.0.0.1 56324 443\r\nGET"), src: "192.168.0.1:56324", dst: "10.0.0.1:443", rest: "GET"},
+		{name: "v1 tcp6", input: []byte("PROXY TCP6 2001:db8::1 2001:db8::2 1234 443\r\n"), src: "[2001:db8::1]:1234", dst: "[2001:db8::2]:443"},
+		{name: "v1 unknown", input: []byte("PROXY UNKNOWN ffff::1 ffff::2 1 2\r\nGET"), rest: "GET"},
+		{name: "v1 missing crlf", input: []byte("PROXY TCP4 192.168.0.1 10.0.0.1 56324 443\n"), wantErr: true},
+		{name: "v1 too long", input: []byte("PROXY TCP4 " + strings.Repeat("1", 120) + "\r\n"), wantErr: true},
+		{name: "v1 truncated", input: []byte("PROXY TCP4 192.168.0.1"), wantErr: true},
+		{name: "v1 too few fields", input: []byte("PROXY TCP4 192.168.0.1 10.0.0.1 56324\r\n"), wantErr: true},
+		{name: "v1 bad protocol", input: []byte("PROXY UDP4 192.168.0.1 10.0.0.1 56324 443\r\n"), wantErr: true},
+		{name: "v1 bad address", input: []byte("PROXY TCP4 192.168.0.300 10.0.0.1 56324 443\r\n"), wantErr: true},
+		{name: "v1 port out of range", input: []byte("PROXY TCP4 192.168.0.1 10.0.0.1 65536 443\r\n"), wantErr: true},
+		{name: "v1 lowercase signature", input: []byte("proxy TCP4 192.168.0.1 10.0.0.1 56324 443\r\n"), wantErr: true},
+		{name: "no header", input: []byte("GET / HTTP/1.1\r\n"), wantErr: true},
+		{name: "empty", input: nil, wantErr: true},
+
+		{name: "v2 tcp4", input: append(proxyV2Header(0x21, 0x11, proxyV2IPv4Body(), -1), "GET"...), src: "192.168.0.1:56324", dst: "10.0.0.1:443", rest: "GET"},
+		{name: "v2 tcp6", input: proxyV2Header(0x21, 0x21, ipv6, -1), src: "[2001:db8::1]:1234", dst: "[2001:db8::2]:443"},
+		{name: "v2 with tlv", input: append(proxyV2Header(0x21, 0x11, withTLV, -1), "GET"...), src: "192.168.0.1:56324", dst: "10.0.0.1:443", rest: "GET"},
+		{name: "v2 local", input: append(proxyV2Header(0x20, 0x00, nil, -1), "GET"...), rest: "GET"},
+		{name: "v2 local skips body", input: append(proxyV2Header(0x20, 0x11, proxyV2IPv4Body(), -1), "GET"...), rest: "GET"},
+		{name: "v2 unspec", input: append(proxyV2Header(0x21, 0x00, []byte{1, 2, 3}, -1), "GET"...), rest: "GET"},
+		{name: "v2 truncated signature", input: proxyV2Signature[:8], wantErr: true},
+		{name: "v2 truncated fixed header", input: proxyV2Header(0x21, 0x11, nil, -1)[:14], wantErr: true},
+		{name: "v2 truncated body", input: proxyV2Header(0x21, 0x11, proxyV2IPv4Body()[:6], 12), wantErr: true},
+		{name: "v2 length larger than data", input: proxyV2Header(0x21, 0x11, proxyV2IPv4Body(), 0xffff), wantErr: true},
+		{name: "v2 short ipv4 address", input: proxyV2Header(0x21, 0x11, proxyV2IPv4Body()[:8], -1), wantErr: true},
+		{name: "v2 short ipv6 address", input: proxyV2Header(0x21, 0x21, ipv6[:32], -1), wantErr: true},
+		{name: "v2 short unix address", input: proxyV2Header(0x21, 0x31, make([]byte, 108), -1), wantErr: true},
+		{name: "v2 bad signature", input: append([]byte("\r\n\r\n\x00\r\nQUIX\n"), 0x21, 0x11, 0, 0), wantErr: true},
+		{name: "v2 version 1", input: proxyV2Header(0x11, 0x11, proxyV2IPv4Body(), -1), wantErr: true},
+		{name: "v2 version 3", input: proxyV2Header(0x31, 0x11, proxyV2IPv4Body(), -1), wantErr: true},
+		{name: "v2 unknown command", input: proxyV2Header(0x22, 0x11, proxyV2IPv4Body(), -1), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReader(bytes.NewReader(tt.input))
+			src, dst, err := readProxyHeader(r)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got src=%v dst=%v", src, dst)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := addrString(src); got != tt.src {
+				t.Errorf("src = %q, want %q", got, tt.src)
+			}
+			if got := addrString(dst); got != tt.dst {
+				t.Errorf("dst = %q, want %q", got, tt.dst)
+			}
+			rest, _ := io.ReadAll(r)
+			if string(rest) != tt.rest {
+				t.Errorf("rest = %q, want %q", rest, tt.rest)
+			}
+		})
+	}
+}
+
+func TestReadProxyHeaderV2Unix(t *testing.T) {
+	body := make([]byte, 216)
+	copy(body, "/tmp/client.sock")
+	copy(body[108:], "/tmp/server.sock")
+	src, dst, err := readProxyHeader(bufio.NewReader(bytes.NewReader(proxyV2Header(0x21, 0x31, body, -1))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.String() != "/tmp/client.sock" || dst.String() != "/tmp/server.sock" {
+		t.Fatalf("got src=%v dst=%v", src, dst)
+	}
+}
+
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
+
+func TestParseIPNets(t *testing.T) {
+	tests := []struct {
+		input   []string
+		want    []string
+		wantErr bool
+	}{
+		{input: nil, want: nil},
+		{input: []string{"10.0.0.1"}, want: []string{"10.0.0.1/32"}},
+		{input: []string{"::1"}, want: []string{"::1/128"}},
+		{input: []string{"10.0.0.0/8", "fd00::/8"}, want: []string{"10.0.0.0/8", "fd00::/8"}},
+		{input: []string{"10.0.0.1/33"}, wantErr: true},
+		{input: []string{"example.com"}, wantErr: true},
+		{input: []string{""}, wantErr: true},
+	}
+	for _, tt := range tests {
+		nets, err := ParseIPNets(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseIPNets(%q) expected error", tt.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseIPNets(%q) unexpected error: %v", tt.input, err)
+			continue
+		}
+		var got []string
+		for _, n := range nets {
+			got = append(got, n.String())
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("ParseIPNets(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestProxyListenerTrust(t *testing.T) {
+	header := "PROXY TCP4 192.168.0.1 10.0.0.1 56324 443\r\n"
+	tests := []struct {
+		name       string
+		trusted    []string
+		wantRemote string // 为空表示连接本身的地址
+		wantData   string
+	}{
+		{name: "trust all", trusted: nil, wantRemote: "192.168.0.1:56324", wantData: "hello"},
+		{name: "trusted source", trusted: []string{"127.0.0.0/8"}, wantRemote: "192.168.0.1:56324", wantData: "hello"},
+		{name: "untrusted source", trusted: []string{"10.0.0.0/8"}, wantData: header + "hello"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ln, err := newProxyListener(raw, tt.trusted, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+
+			client, err := net.Dial("tcp", raw.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+			client.Write([]byte(header + "hello"))
+			client.(*net.TCPConn).CloseWrite()
+
+			conn, err := ln.Accept()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			want := tt.wantRemote
+			if want == "" {
+				want = client.LocalAddr().String()
+			}
+			if got := conn.RemoteAddr().String(); got != want {
+				t.Errorf("RemoteAddr = %q, want %q", got, want)
+			}
+			data, err := io.ReadAll(conn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.wantData {
+				t.Errorf("data = %q, want %q", data, tt.wantData)
+			}
+		})
+	}
+}
+
+func TestProxyConnBadHeader(t *testing.T) {
+	raw, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := newProxyListener(raw, nil, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	client, err := net.Dial("tcp", raw.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Read(make([]byte, 10)); err == nil || !strings.Contains(err.Error(), ErrProxyHeader.Error()) {
+		t.Fatalf("expected ErrProxyHeader, got %v", err)
+	}
+}
+
+func TestProxyConnHeaderTimeout(t *testing.T) {
+	raw, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := newProxyListener(raw, nil, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	client, err := net.Dial("tcp", raw.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	// 只发送部分v2头部，读取应该在超时后失败而不是一直阻塞
+	client.Write(proxyV2Header(0x21, 0x11, proxyV2IPv4Body()[:4], 12))
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	start := time.Now()
+	if _, err := conn.Read(make([]byte, 10)); err == nil {
+		t.Fatal("expected error for truncated header")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("read blocked for %s", elapsed)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/spf13/cast"
 	"io/ioutil"
 	"mime/multipart"
+	"strings"
 )
 
 const defaultMultipartMemory = 32 << 20 // 32 MB
@@ -168,15 +169,26 @@ func (ctx *Context) Query(key string) interface{} {
 }
 
 // Param 获取路由参数
+// 参数名按注册时的写法保存，找不到时忽略大小写再查找，兼容以前按大写参数名读取的代码
 func (ctx *Context) Param(key string) interface{} {
 	if ctx.params != nil {
 		if val, ok := ctx.params[key]; ok {
 			return val
 		}
+		for name, val := range ctx.params {
+			if strings.EqualFold(name, key) {
+				return val
+			}
+		}
 	}
 	return nil
 }
 
+// Params 获取所有路由参数
+func (ctx *Context) Params() map[string]string {
+	return ctx.params
+}
+
 // ParamInt 路由匹配中带的参数
 // 形如 /book/:id
 func (ctx *Context) ParamInt(key string, def int) (int, bool) {
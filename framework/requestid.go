@@ -0,0 +1,128 @@
+package framework
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HeaderRequestID 传递请求id的请求头和响应头
+const HeaderRequestID = "X-Request-ID"
+
+// requestIDKey 请求id在context中的key
+type requestIDKey struct{}
+
+// WithRequestID 返回带有请求id的context，用于把请求id传给后台任务
+func WithRequestID(parent context.Context, id string) context.Context {
+	return context.WithValue(parent, requestIDKey{}, id)
+}
+
+// RequestIDFromContext 获取context中的请求id，没有时返回空字符串
+// *Context的Value使用请求的context，可以直接传入
+func RequestIDFromContext(c context.Context) string {
+	id, _ := c.Value(requestIDKey{}).(string)
+	return id
+}
+
+// InjectRequestID 把req的context中的请求id设置到请求头，用于调用其他服务
+func InjectRequestID(req *http.Request) {
+	if id := RequestIDFromContext(req.Context()); id != "" && req.Header.Get(HeaderRequestID) == "" {
+		req.Header.Set(HeaderRequestID, id)
+	}
+}
+
+// RequestIDTransport 发出请求时自动带上context中的请求id
+// 例如 client := &http.Client{Transport: &RequestIDTransport{}}
+type RequestIDTransport struct {
+	Base http.RoundTripper // 为空时使用http.DefaultTransport
+}
+
+func (t *RequestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if id := RequestIDFromContext(req.Context()); id != "" && req.Header.Get(HeaderRequestID) == "" {
+		// RoundTripper不能修改传入的请求
+		req = req.Clone(req.Context())
+		req.Header.Set(HeaderRequestID, id)
+	}
+	return base.RoundTrip(req)
+}
+
+// RequestID 当前请求的id，没有设置时返回空字符串
+func (ctx *Context) RequestID() string {
+	ctx.loggerMu.Lock()
+	defer ctx.loggerMu.Unlock()
+	return ctx.requestID
+}
+
+// SetRequestID 设置当前请求的id，同时写入请求的context和日志字段
+func (ctx *Context) SetRequestID(id string) {
+	ctx.SetBaseContext(WithRequestID(ctx.BaseContext(), id))
+	ctx.loggerMu.Lock()
+	ctx.requestID = id
+	if ctx.logger != nil {
+		ctx.logger = ctx.logger.With("request_id", id)
+	}
+	ctx.loggerMu.Unlock()
+	if ctx.core != nil && ctx.inflight != nil {
+		ctx.core.inflight.setRequestID(ctx.inflight, id)
+	}
+}
+
+// crockford base32字符集，和ULID一致
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var requestIDGen struct {
+	sync.Mutex
+	lastMs  uint64
+	entropy [10]byte
+}
+
+// NewRequestID 生成26个字符的ULID，前48位为毫秒时间戳，按字符串排序即为生成顺序
+// 同一毫秒内的id在随机部分上递增，保证单调
+func NewRequestID() string {
+	ms := uint64(time.Now().UnixMilli())
+	g := &requestIDGen
+	g.Lock()
+	if ms <= g.lastMs {
+		// 同一毫秒或者时钟回拨，沿用上一个时间戳并递增随机部分
+		ms = g.lastMs
+		carry := true
+		for i := len(g.entropy) - 1; i >= 0 && carry; i-- {
+			g.entropy[i]++
+			carry = g.entropy[i] == 0
+		}
+		if carry {
+			// 随机部分溢出时进入下一毫秒，保证id仍然递增
+			g.lastMs++
+			ms = g.lastMs
+		}
+	} else {
+		g.lastMs = ms
+		rand.Read(g.entropy[:])
+	}
+	var raw [16]byte
+	binary.BigEndian.PutUint16(raw[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(raw[2:6], uint32(ms))
+	copy(raw[6:], g.entropy[:])
+	g.Unlock()
+	return encodeULID(raw)
+}
+
+// encodeULID 128位按5位一组编码为26个字符，第一个字符只有3位
+func encodeULID(raw [16]byte) string {
+	hi := binary.BigEndian.Uint64(raw[0:8])
+	lo := binary.BigEndian.Uint64(raw[8:16])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockfordAlphabet[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
@@ -0,0 +1,165 @@
+package framework
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncodeULID(t *testing.T) {
+	var ones [16]byte
+	for i := range ones {
+		ones[i] = 0xff
+	}
+	tests := []struct {
+		name string
+		raw  [16]byte
+		want string
+	}{
+		{name: "zero", raw: [16]byte{}, want: "00000000000000000000000000"},
+		{name: "max", raw: ones, want: "7ZZZZZZZZZZZZZZZZZZZZZZZZZ"},
+		{name: "last bit", raw: [16]byte{15: 1}, want: "00000000000000000000000001"},
+		{name: "last group", raw: [16]byte{15: 0x1f}, want: "0000000000000000000000000Z"},
+		{name: "first bit", raw: [16]byte{0: 0x80}, want: "40000000000000000000000000"},
+		// 1469918176385毫秒，ULID规范中的示例时间戳
+		{name: "timestamp", raw: [16]byte{0x01, 0x56, 0x3d, 0xf3, 0x64, 0x81}, want: "01ARYZ6S410000000000000000"},
+	}
+	for _, tt := range tests {
+		if got := encodeULID(tt.raw); got != tt.want {
+			t.Errorf("%s: encodeULID = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// decodeULIDTime 解析ULID前10个字符表示的毫秒时间戳
+func decodeULIDTime(t *testing.T, id string) int64 {
+	t.Helper()
+	var ms int64
+	for i := 0; i < 10; i++ {
+		v := strings.IndexByte(crockfordAlphabet, id[i])
+		if v < 0 {
+			t.Fatalf("invalid character %q in %q", id[i], id)
+		}
+		ms = ms<<5 | int64(v)
+	}
+	return ms
+}
+
+func TestNewRequestID(t *testing.T) {
+	before := time.Now().UnixMilli()
+	id := NewRequestID()
+	after := time.Now().UnixMilli()
+
+	if len(id) != 26 {
+		t.Fatalf("len(%q) = %d, want 26", id, len(id))
+	}
+	for i := 0; i < len(id); i++ {
+		if strings.IndexByte(crockfordAlphabet, id[i]) < 0 {
+			t.Fatalf("invalid character %q in %q", id[i], id)
+		}
+	}
+	if id[0] > '7' {
+		t.Fatalf("first character of %q exceeds 128 bits", id)
+	}
+	if ms := decodeULIDTime(t, id); ms < before || ms > after+1 {
+		t.Fatalf("timestamp %d not in [%d, %d]", ms, before, after)
+	}
+}
+
+func TestNewRequestIDMonotonic(t *testing.T) {
+	prev := NewRequestID()
+	seen := map[string]bool{prev: true}
+	for i := 0; i < 10000; i++ {
+		id := NewRequestID()
+		if id <= prev {
+			t.Fatalf("id %q is not greater than %q", id, prev)
+		}
+		if seen[id] {
+			t.Fatalf("duplicate id %q", id)
+		}
+		seen[id] = true
+		prev = id
+	}
+}
+
+func TestNewRequestIDClockRollbackAndOverflow(t *testing.T) {
+	g := &requestIDGen
+	g.Lock()
+	saved, savedEntropy := g.lastMs, g.entropy
+	// 时钟回拨：上一个时间戳在未来，随机部分即将溢出
+	future := uint64(time.Now().Add(time.Hour).UnixMilli())
+	g.lastMs = future
+	for i := range g.entropy {
+		g.entropy[i] = 0xff
+	}
+	g.entropy[len(g.entropy)-1] = 0xfd
+	g.Unlock()
+	defer func() {
+		g.Lock()
+		g.lastMs, g.entropy = saved, savedEntropy
+		g.Unlock()
+	}()
+
+	first := NewRequestID()
+	second := NewRequestID()
+	third := NewRequestID()
+	if !(first < second && second < third) {
+		t.Fatalf("ids not increasing: %q %q %q", first, second, third)
+	}
+	if ms := decodeULIDTime(t, first); uint64(ms) != future {
+		t.Fatalf("rolled back clock: timestamp %d, want %d", ms, future)
+	}
+	if !strings.HasSuffix(first, "ZZZZZZZZZZZZZZZY") || !strings.HasSuffix(second, "ZZZZZZZZZZZZZZZZ") {
+		t.Fatalf("unexpected entropy increment: %q %q", first, second)
+	}
+	// 随机部分溢出后进入下一毫秒
+	if ms := decodeULIDTime(t, third); uint64(ms) != future+1 {
+		t.Fatalf("overflow: timestamp %d, want %d", ms, future+1)
+	}
+}
+
+func TestRequestIDContext(t *testing.T) {
+	if id := RequestIDFromContext(context.Background()); id != "" {
+		t.Fatalf("empty context returned %q", id)
+	}
+	ctx := WithRequestID(context.Background(), "abc")
+	if id := RequestIDFromContext(ctx); id != "abc" {
+		t.Fatalf("RequestIDFromContext = %q", id)
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://example.com", nil)
+	InjectRequestID(req)
+	if got := req.Header.Get(HeaderRequestID); got != "abc" {
+		t.Fatalf("InjectRequestID header = %q", got)
+	}
+	req.Header.Set(HeaderRequestID, "explicit")
+	InjectRequestID(req)
+	if got := req.Header.Get(HeaderRequestID); got != "explicit" {
+		t.Fatalf("InjectRequestID overwrote header: %q", got)
+	}
+}
+
+func TestRequestIDTransport(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(HeaderRequestID)
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: &RequestIDTransport{}}
+
+	req, _ := http.NewRequestWithContext(WithRequestID(context.Background(), "from-ctx"), "GET", srv.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got != "from-ctx" {
+		t.Fatalf("server saw %q", got)
+	}
+	if req.Header.Get(HeaderRequestID) != "" {
+		t.Fatal("RoundTrip modified the caller's request")
+	}
+}
@@ -1,12 +1,17 @@
 package framework
 
 import (
+	"bytes"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"html/template"
+	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // IResponse 代表返回方法
@@ -40,6 +45,21 @@ type IResponse interface {
 
 	// 设置200状态
 	SetOkStatus() IResponse
+
+	// 根据Accept头选择输出格式
+	Negotiate(status int, obj interface{}) IResponse
+
+	// 输出文件
+	File(filepath string) IResponse
+
+	// 以附件形式下载文件
+	FileAttachment(filepath string, filename string) IResponse
+
+	// 输出字节流
+	Data(contentType string, data []byte) IResponse
+
+	// 输出reader中的数据
+	DataFromReader(length int64, contentType string, reader io.Reader, extraHeaders map[string]string) IResponse
 }
 
 // Jsonp 输出
@@ -89,18 +109,32 @@ func (ctx *Context) Xml(obj interface{}) IResponse {
 }
 
 // Html 输出
+// core加载了模版时file为模版名称，否则为模版文件路径
+// 渲染失败时不输出任何内容，错误交给core的错误处理函数
 func (ctx *Context) Html(file string, obj interface{}) IResponse {
-	// 读取模版文件，创建template实例
-	t, err := template.New("output").ParseFiles(file)
-	if err != nil {
-		return ctx
-	}
-	// 执行Execute方法将obj和模版进行结合
-	if err := t.Execute(ctx.responseWriter, obj); err != nil {
-		return ctx
+	buf := &bytes.Buffer{}
+	if ctx.core != nil && ctx.core.html.Loaded() {
+		if err := ctx.core.html.Render(buf, file, obj); err != nil {
+			ctx.AddError(err)
+			return ctx
+		}
+	} else {
+		// 读取模版文件，创建template实例
+		t, err := template.ParseFiles(file)
+		if err != nil {
+			ctx.AddError(err)
+			return ctx
+		}
+		// 执行Execute方法将obj和模版进行结合
+		if err := t.Execute(buf, obj); err != nil {
+			ctx.AddError(err)
+			return ctx
+		}
 	}
 
-	ctx.SetHeader("Content-Type", "application/html")
+	// header需要在输出内容之前设置
+	ctx.responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	ctx.responseWriter.Write(buf.Bytes())
 	return ctx
 }
 
@@ -163,3 +197,88 @@ func (ctx *Context) Json(obj interface{}) IResponse {
 	ctx.responseWriter.Write(byt)
 	return ctx
 }
+
+// File 输出本地文件，支持Range和HEAD请求
+func (ctx *Context) File(filepath string) IResponse {
+	ctx.AddError(ctx.serveFile(filepath, ""))
+	return ctx
+}
+
+// FileAttachment 以附件形式下载本地文件，filename为浏览器保存的文件名
+func (ctx *Context) FileAttachment(filepath string, filename string) IResponse {
+	ctx.AddError(ctx.serveFile(filepath, contentDisposition("attachment", filename)))
+	return ctx
+}
+
+// Data 输出字节流，支持Range和HEAD请求
+func (ctx *Context) Data(contentType string, data []byte) IResponse {
+	ctx.responseWriter.Header().Set("Content-Type", contentType)
+	http.ServeContent(ctx.responseWriter, ctx.request, "", time.Time{}, bytes.NewReader(data))
+	return ctx
+}
+
+// DataFromReader 输出reader中的数据，length小于0表示长度未知
+// reader实现了io.ReadSeeker时支持Range请求，否则总是输出全部数据
+func (ctx *Context) DataFromReader(length int64, contentType string, reader io.Reader, extraHeaders map[string]string) IResponse {
+	header := ctx.responseWriter.Header()
+	for key, val := range extraHeaders {
+		header.Set(key, val)
+	}
+	header.Set("Content-Type", contentType)
+
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		http.ServeContent(ctx.responseWriter, ctx.request, "", time.Time{}, seeker)
+		return ctx
+	}
+
+	if length >= 0 {
+		header.Set("Content-Length", strconv.FormatInt(length, 10))
+	}
+	ctx.responseWriter.WriteHeader(http.StatusOK)
+	if ctx.request.Method == http.MethodHead {
+		return ctx
+	}
+	// header已经输出，出错时只能记录日志
+	if _, err := io.Copy(ctx.responseWriter, reader); err != nil {
+		ctx.Logger().Error("write data error", "error", err)
+	}
+	return ctx
+}
+
+// contentDisposition 按RFC 6266生成Content-Disposition
+// 非ASCII文件名使用filename*编码，同时提供ASCII的filename兼容旧客户端
+func contentDisposition(disposition string, filename string) string {
+	fallback := make([]byte, 0, len(filename))
+	plain := true
+	for _, r := range filename {
+		if r < 0x20 || r >= 0x7f || r == '"' || r == '\\' {
+			fallback = append(fallback, '_')
+			plain = false
+			continue
+		}
+		fallback = append(fallback, byte(r))
+	}
+	if plain {
+		return fmt.Sprintf(`%s; filename="%s"`, disposition, filename)
+	}
+
+	// RFC 5987 ext-value，只保留attr-char，其余字节百分号编码
+	encoded := make([]byte, 0, len(filename)*3)
+	for i := 0; i < len(filename); i++ {
+		b := filename[i]
+		if isAttrChar(b) {
+			encoded = append(encoded, b)
+			continue
+		}
+		encoded = append(encoded, fmt.Sprintf("%%%02X", b)...)
+	}
+	return fmt.Sprintf(`%s; filename="%s"; filename*=UTF-8''%s`, disposition, fallback, encoded)
+}
+
+func isAttrChar(b byte) bool {
+	switch {
+	case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+}
@@ -0,0 +1,163 @@
+package framework
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{filename: "report.pdf", want: `attachment; filename="report.pdf"`},
+		{filename: "a b.txt", want: `attachment; filename="a b.txt"`},
+		{filename: `a"b\c.txt`, want: `attachment; filename="a_b_c.txt"; filename*=UTF-8''a%22b%5Cc.txt`},
+		{filename: "报告.pdf", want: `attachment; filename="__.pdf"; filename*=UTF-8''%E6%8A%A5%E5%91%8A.pdf`},
+		{filename: "evil\r\nSet-Cookie: x", want: `attachment; filename="evil__Set-Cookie: x"; filename*=UTF-8''evil%0D%0ASet-Cookie%3A%20x`},
+	}
+	for _, tt := range tests {
+		if got := contentDisposition("attachment", tt.filename); got != tt.want {
+			t.Errorf("contentDisposition(%q) = %s, want %s", tt.filename, got, tt.want)
+		}
+	}
+}
+
+func TestFileResponses(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "data.txt")
+	if err := os.WriteFile(file, []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	core := NewCore()
+	core.SetMode(TestMode)
+	core.Get("/file", func(c *Context) error {
+		c.File(file)
+		return nil
+	})
+	core.Get("/attachment", func(c *Context) error {
+		c.FileAttachment(file, "报告.txt")
+		return nil
+	})
+	core.Get("/missing", func(c *Context) error {
+		c.FileAttachment(filepath.Join(dir, "missing.txt"), "missing.txt")
+		return nil
+	})
+	core.Get("/dir", func(c *Context) error {
+		c.FileAttachment(dir, "dir.txt")
+		return nil
+	})
+
+	tests := []struct {
+		path        string
+		rng         string
+		status      int
+		body        string
+		disposition string
+	}{
+		{path: "/file", status: http.StatusOK, body: "0123456789"},
+		{path: "/file", rng: "bytes=3-5", status: http.StatusPartialContent, body: "345"},
+		{path: "/file", rng: "bytes=20-", status: http.StatusRequestedRangeNotSatisfiable},
+		{path: "/attachment", status: http.StatusOK, body: "0123456789", disposition: `attachment; filename="__.txt"; filename*=UTF-8''%E6%8A%A5%E5%91%8A.txt`},
+		{path: "/attachment", rng: "bytes=-2", status: http.StatusPartialContent, body: "89", disposition: `attachment; filename="__.txt"; filename*=UTF-8''%E6%8A%A5%E5%91%8A.txt`},
+		// 文件不存在时不能带着附件头返回404页面
+		{path: "/missing", status: http.StatusNotFound},
+		{path: "/dir", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		if tt.rng != "" {
+			req.Header.Set("Range", tt.rng)
+		}
+		rec := httptest.NewRecorder()
+		core.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: status = %d, want %d", tt.path, tt.rng, rec.Code, tt.status)
+			continue
+		}
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("%s %s: body = %q, want %q", tt.path, tt.rng, rec.Body, tt.body)
+		}
+		if got := rec.Header().Get("Content-Disposition"); got != tt.disposition {
+			t.Errorf("%s %s: Content-Disposition = %q, want %q", tt.path, tt.rng, got, tt.disposition)
+		}
+	}
+}
+
+// onlyReader 隐藏io.Seeker，模拟不能Seek的reader
+type onlyReader struct{ io.Reader }
+
+func TestDataResponses(t *testing.T) {
+	core := NewCore()
+	core.SetMode(TestMode)
+	core.Get("/data", func(c *Context) error {
+		c.Data("application/octet-stream", []byte("0123456789"))
+		return nil
+	})
+	core.Get("/seeker", func(c *Context) error {
+		c.DataFromReader(10, "text/plain", strings.NewReader("0123456789"), map[string]string{
+			"Content-Disposition": contentDisposition("inline", "a.txt"),
+		})
+		return nil
+	})
+	core.Get("/stream", func(c *Context) error {
+		c.DataFromReader(10, "text/plain", onlyReader{strings.NewReader("0123456789")}, nil)
+		return nil
+	})
+	core.Get("/unknown", func(c *Context) error {
+		c.DataFromReader(-1, "text/plain", onlyReader{strings.NewReader("0123456789")}, nil)
+		return nil
+	})
+	core.Head("/stream", func(c *Context) error {
+		c.DataFromReader(10, "text/plain", onlyReader{strings.NewReader("0123456789")}, nil)
+		return nil
+	})
+
+	tests := []struct {
+		method        string
+		path          string
+		rng           string
+		status        int
+		body          string
+		contentType   string
+		contentLength string
+	}{
+		{method: "GET", path: "/data", status: http.StatusOK, body: "0123456789", contentType: "application/octet-stream", contentLength: "10"},
+		{method: "GET", path: "/data", rng: "bytes=0-1", status: http.StatusPartialContent, body: "01", contentType: "application/octet-stream", contentLength: "2"},
+		{method: "GET", path: "/seeker", rng: "bytes=8-", status: http.StatusPartialContent, body: "89", contentType: "text/plain", contentLength: "2"},
+		// 不能Seek的reader忽略Range，输出全部数据
+		{method: "GET", path: "/stream", rng: "bytes=8-", status: http.StatusOK, body: "0123456789", contentType: "text/plain", contentLength: "10"},
+		{method: "GET", path: "/unknown", status: http.StatusOK, body: "0123456789", contentType: "text/plain"},
+		{method: "HEAD", path: "/stream", status: http.StatusOK, contentType: "text/plain", contentLength: "10"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.rng != "" {
+			req.Header.Set("Range", tt.rng)
+		}
+		rec := httptest.NewRecorder()
+		core.ServeHTTP(rec, req)
+		if rec.Code != tt.status || rec.Body.String() != tt.body {
+			t.Errorf("%s %s %s: status = %d body = %q, want %d %q", tt.method, tt.path, tt.rng, rec.Code, rec.Body, tt.status, tt.body)
+			continue
+		}
+		if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("%s %s: Content-Type = %q, want %q", tt.method, tt.path, got, tt.contentType)
+		}
+		if got := rec.Header().Get("Content-Length"); got != tt.contentLength {
+			t.Errorf("%s %s: Content-Length = %q, want %q", tt.method, tt.path, got, tt.contentLength)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	core.ServeHTTP(rec, httptest.NewRequest("GET", "/seeker", nil))
+	if got := rec.Header().Get("Content-Disposition"); got != `inline; filename="a.txt"` {
+		t.Errorf("extra header Content-Disposition = %q", got)
+	}
+}
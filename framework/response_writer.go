@@ -0,0 +1,128 @@
+package framework
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+// responseWriter 包装http.ResponseWriter，记录状态码和写入的字节数，用于访问日志和监控
+// Flush、Hijack和ReadFrom透传给原始的ResponseWriter，Unwrap用于http.ResponseController
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	size        int64
+	wroteHeader bool
+	beforeWrite []func() // 写入状态码之前执行，用于补充响应头
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	if rw, ok := w.(*responseWriter); ok {
+		return rw
+	}
+	return &responseWriter{ResponseWriter: w}
+}
+
+// WriteHeader 记录第一次写入的状态码，1xx的中间状态不记录
+func (w *responseWriter) WriteHeader(code int) {
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if !w.wroteHeader {
+		w.runBeforeWrite()
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// runBeforeWrite 按注册的相反顺序执行BeforeWriteHeader注册的函数，每个函数只执行一次
+func (w *responseWriter) runBeforeWrite() {
+	hooks := w.beforeWrite
+	w.beforeWrite = nil
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// ReadFrom 原始的ResponseWriter支持时使用sendfile等优化
+func (w *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	var n int64
+	var err error
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(w.ResponseWriter, r)
+	}
+	w.size += n
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack 接管连接，例如websocket，之后的状态码记录为101
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil && !w.wroteHeader {
+		w.status = http.StatusSwitchingProtocols
+		w.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status 响应的状态码，还没有写入时为200
+func (ctx *Context) Status() int {
+	if !ctx.responseWriter.wroteHeader {
+		return http.StatusOK
+	}
+	return ctx.responseWriter.status
+}
+
+// ResponseSize 已经写入的响应体字节数
+func (ctx *Context) ResponseSize() int64 {
+	return ctx.responseWriter.size
+}
+
+// Written 是否已经写入了状态码或者响应体
+func (ctx *Context) Written() bool {
+	return ctx.responseWriter.wroteHeader
+}
+
+// BeforeWriteHeader 注册在写入状态码之前执行的函数，用于根据处理结果补充响应头
+// 后注册的先执行，已经写入后注册的函数不会执行，处理函数没有写入响应时在请求处理结束后执行
+func (ctx *Context) BeforeWriteHeader(fn func()) {
+	if ctx.responseWriter.wroteHeader {
+		return
+	}
+	ctx.responseWriter.beforeWrite = append(ctx.responseWriter.beforeWrite, fn)
+}
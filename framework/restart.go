@@ -0,0 +1,220 @@
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 平滑重启时父进程通过环境变量告诉子进程继承的文件描述符
+const (
+	envListenFds = "AXIS_LISTEN_FDS" // 继承的listener数量，从fd 3开始
+	envReadyFd   = "AXIS_READY_FD"   // 子进程就绪后写入的管道
+
+	// systemd socket activation，见 sd_listen_fds(3)
+	envSystemdListenFds = "LISTEN_FDS"
+	envSystemdListenPid = "LISTEN_PID"
+
+	listenFdsStart = 3
+)
+
+var (
+	inheritOnce sync.Once
+	inheritMu   sync.Mutex
+	inherited   []net.Listener // 还没有被使用的继承listener
+)
+
+// inheritListeners 解析从父进程或者systemd继承的listener，只在第一次调用时解析
+func inheritListeners() {
+	inheritOnce.Do(func() {
+		count := 0
+		if n, err := strconv.Atoi(os.Getenv(envListenFds)); err == nil {
+			count = n
+		} else if pid, err := strconv.Atoi(os.Getenv(envSystemdListenPid)); err == nil && pid == os.Getpid() {
+			count, _ = strconv.Atoi(os.Getenv(envSystemdListenFds))
+		}
+		// 避免之后启动的子进程误用这些变量
+		os.Unsetenv(envListenFds)
+		os.Unsetenv(envSystemdListenFds)
+		os.Unsetenv(envSystemdListenPid)
+		os.Unsetenv("LISTEN_FDNAMES")
+
+		for i := 0; i < count; i++ {
+			f := os.NewFile(uintptr(listenFdsStart+i), "listener"+strconv.Itoa(i))
+			ln, err := net.FileListener(f)
+			f.Close()
+			if err != nil {
+				continue
+			}
+			inherited = append(inherited, ln)
+		}
+	})
+}
+
+// takeInherited 取出和地址匹配的继承listener，没有时返回nil
+func takeInherited(network string, addr string) net.Listener {
+	inheritListeners()
+	inheritMu.Lock()
+	defer inheritMu.Unlock()
+	for i, ln := range inherited {
+		if listenerMatches(ln, network, addr) {
+			inherited = append(inherited[:i], inherited[i+1:]...)
+			return ln
+		}
+	}
+	return nil
+}
+
+// closeInherited 关闭没有被任何地址使用的继承listener，避免继续占用端口
+func closeInherited() {
+	inheritListeners()
+	inheritMu.Lock()
+	defer inheritMu.Unlock()
+	for _, ln := range inherited {
+		ln.Close()
+	}
+	inherited = nil
+}
+
+// listenerMatches 判断listener是否监听在network和addr上
+func listenerMatches(ln net.Listener, network string, addr string) bool {
+	switch la := ln.Addr().(type) {
+	case *net.TCPAddr:
+		if network != "tcp" && network != "tcp4" && network != "tcp6" {
+			return false
+		}
+		want, err := net.ResolveTCPAddr(network, addr)
+		if err != nil || want.Port != la.Port {
+			return false
+		}
+		// 监听所有地址时不区分ipv4和ipv6
+		if len(want.IP) == 0 || want.IP.IsUnspecified() {
+			return la.IP.IsUnspecified()
+		}
+		return want.IP.Equal(la.IP)
+	case *net.UnixAddr:
+		return network == "unix" && la.Name == addr
+	}
+	return false
+}
+
+// listen 优先使用继承的listener，没有时新建
+func (s *Server) listen(network string, addr string) (net.Listener, error) {
+	if ln := takeInherited(network, addr); ln != nil {
+		return ln, nil
+	}
+	if network == "unix" {
+		// 清理上次异常退出遗留的socket文件
+		if err := os.Remove(addr); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return net.Listen(network, addr)
+}
+
+// notifyReady 通知父进程子进程已经开始接受请求
+func notifyReady() {
+	fd, err := strconv.Atoi(os.Getenv(envReadyFd))
+	os.Unsetenv(envReadyFd)
+	if err != nil {
+		return
+	}
+	f := os.NewFile(uintptr(fd), "ready")
+	f.Write([]byte{1})
+	f.Close()
+}
+
+// restart 启动新的进程并传递listener，等待新进程就绪
+// 返回nil表示新进程已经开始接受请求，当前进程可以停止接受请求并优雅关闭
+func (s *Server) restart(listeners []net.Listener) error {
+	files := make([]*os.File, 0, len(listeners)+1)
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, ln := range listeners {
+		f, err := listenerFile(ln)
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+	}
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyR.Close()
+	files = append(files, readyW)
+
+	env := make([]string, 0, len(os.Environ())+2)
+	for _, kv := range os.Environ() {
+		switch strings.SplitN(kv, "=", 2)[0] {
+		case envListenFds, envReadyFd, envSystemdListenFds, envSystemdListenPid, "LISTEN_FDNAMES":
+			continue
+		}
+		env = append(env, kv)
+	}
+	env = append(env,
+		envListenFds+"="+strconv.Itoa(len(listeners)),
+		envReadyFd+"="+strconv.Itoa(listenFdsStart+len(listeners)),
+	)
+	process, err := startProcess(files, env)
+	if err != nil {
+		return err
+	}
+	// 关闭当前进程持有的写端，子进程退出时读端才能读到EOF
+	readyW.Close()
+	files = files[:len(files)-1]
+
+	timeout := s.RestartTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	readyR.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 1)
+	if _, err := readyR.Read(buf); err != nil {
+		process.Kill()
+		return fmt.Errorf("child process %d not ready: %w", process.Pid, err)
+	}
+	// 当前进程退出后子进程由init接管
+	go process.Wait()
+
+	// unix socket文件由子进程继续使用，关闭listener时不能删除
+	for _, ln := range listeners {
+		if ul, ok := ln.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+	return nil
+}
+
+// listenerFile 获取listener对应的文件，用于传递给子进程
+func listenerFile(ln net.Listener) (*os.File, error) {
+	switch l := ln.(type) {
+	case *net.TCPListener:
+		return l.File()
+	case *net.UnixListener:
+		return l.File()
+	}
+	return nil, fmt.Errorf("listener %T can not be passed to child process", ln)
+}
+
+// startProcess 使用相同的参数启动当前程序，files从fd 3开始传递给子进程
+func startProcess(files []*os.File, env []string) (*os.Process, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	attrFiles := append([]*os.File{os.Stdin, os.Stdout, os.Stderr}, files...)
+	return os.StartProcess(path, os.Args, &os.ProcAttr{
+		Env:   env,
+		Files: attrFiles,
+	})
+}
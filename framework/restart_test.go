@@ -0,0 +1,56 @@
+package framework
+
+import (
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestListenerMatches(t *testing.T) {
+	ln4, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln4.Close()
+	port := ln4.Addr().(*net.TCPAddr).Port
+
+	all, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer all.Close()
+	allPort := all.Addr().(*net.TCPAddr).Port
+
+	sock := filepath.Join(t.TempDir(), "axis.sock")
+	unix, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("unix socket not supported: %v", err)
+	}
+	defer unix.Close()
+
+	tests := []struct {
+		name    string
+		ln      net.Listener
+		network string
+		addr    string
+		want    bool
+	}{
+		{name: "same address", ln: ln4, network: "tcp", addr: ln4.Addr().String(), want: true},
+		{name: "tcp4", ln: ln4, network: "tcp4", addr: ln4.Addr().String(), want: true},
+		{name: "other port", ln: ln4, network: "tcp", addr: "127.0.0.1:1", want: false},
+		{name: "other ip", ln: ln4, network: "tcp", addr: net.JoinHostPort("127.0.0.2", strconv.Itoa(port)), want: false},
+		{name: "unspecified wants unspecified", ln: ln4, network: "tcp", addr: net.JoinHostPort("", strconv.Itoa(port)), want: false},
+		{name: "all addresses", ln: all, network: "tcp", addr: net.JoinHostPort("", strconv.Itoa(allPort)), want: true},
+		{name: "all addresses ipv4", ln: all, network: "tcp", addr: net.JoinHostPort("0.0.0.0", strconv.Itoa(allPort)), want: true},
+		{name: "tcp listener unix network", ln: ln4, network: "unix", addr: ln4.Addr().String(), want: false},
+		{name: "unix", ln: unix, network: "unix", addr: sock, want: true},
+		{name: "unix other path", ln: unix, network: "unix", addr: sock + ".old", want: false},
+		{name: "unix listener tcp network", ln: unix, network: "tcp", addr: sock, want: false},
+	}
+	for _, tt := range tests {
+		if got := listenerMatches(tt.ln, tt.network, tt.addr); got != tt.want {
+			t.Errorf("%s: listenerMatches(%s, %s, %s) = %v, want %v", tt.name, tt.ln.Addr(), tt.network, tt.addr, got, tt.want)
+		}
+	}
+}
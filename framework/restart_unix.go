@@ -0,0 +1,12 @@
+//go:build !windows
+
+package framework
+
+import (
+	"os"
+	"syscall"
+)
+
+// defaultRestartSignals 默认触发平滑重启的信号
+// 日志文件开启了ReopenOnSignal时需要从RestartSignals中去掉SIGHUP，见RotateWriter.Signals
+var defaultRestartSignals = []os.Signal{syscall.SIGHUP, syscall.SIGUSR2}
@@ -0,0 +1,162 @@
+//go:build !windows
+
+package framework
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// envRestartHelper 设置时测试进程作为继承listener的子进程运行
+const envRestartHelper = "AXIS_TEST_RESTART_HELPER"
+
+// TestRestartHelperProcess 子进程的入口，使用继承的listener在AXIS_TEST_ADDR上提供服务
+func TestRestartHelperProcess(t *testing.T) {
+	if os.Getenv(envRestartHelper) != "1" {
+		return
+	}
+	core := NewCore()
+	core.SetMode(TestMode)
+	core.Get("/pid", func(c *Context) error {
+		c.Text("%d", os.Getpid())
+		return nil
+	})
+	s := NewServer(core)
+	s.RestartSignals = nil
+	if err := s.RunListeners(ListenerSpec{Addr: os.Getenv("AXIS_TEST_ADDR")}); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func TestInheritListeners(t *testing.T) {
+	used, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unused, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	usedAddr, unusedAddr := used.Addr().String(), unused.Addr().String()
+	usedFile, err := used.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unusedFile, err := unused.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer readyR.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRestartHelperProcess$")
+	cmd.Env = append(os.Environ(),
+		envRestartHelper+"=1",
+		"AXIS_TEST_ADDR="+usedAddr,
+		envListenFds+"=2",
+		envReadyFd+"=5",
+	)
+	// ExtraFiles从fd 3开始
+	cmd.ExtraFiles = []*os.File{usedFile, unusedFile, readyW}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	reaped := false
+	defer func() {
+		if !reaped {
+			cmd.Process.Kill()
+			<-exited
+		}
+	}()
+
+	// 之后只有子进程持有这些listener
+	for _, c := range []io.Closer{used, unused, usedFile, unusedFile, readyW} {
+		c.Close()
+	}
+
+	readyR.SetReadDeadline(time.Now().Add(10 * time.Second))
+	buf := make([]byte, 1)
+	if _, err := readyR.Read(buf); err != nil || buf[0] != 1 {
+		t.Fatalf("ready notification = %v, %v", buf, err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	resp, err := client.Get("http://" + usedAddr + "/pid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	body := string(pid)
+	if body != strconv.Itoa(cmd.Process.Pid) {
+		t.Fatalf("served by pid %s, want child %d", body, cmd.Process.Pid)
+	}
+
+	// 没有匹配到地址的继承listener在子进程中被关闭
+	conn, err := net.DialTimeout("tcp", unusedAddr, time.Second)
+	if err == nil {
+		conn.Close()
+		t.Fatalf("unused inherited listener %s still accepts connections", unusedAddr)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Fatalf("dial unused listener = %v, want connection refused", err)
+	}
+
+	cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case err := <-exited:
+		reaped = true
+		if err != nil {
+			t.Fatalf("child exit = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("child did not exit after SIGTERM")
+	}
+}
+
+func TestNotifyReady(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	// notifyReady会关闭传入的fd，给它一个单独的副本
+	fd, err := syscall.Dup(int(w.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(envReadyFd, strconv.Itoa(fd))
+
+	notifyReady()
+	if _, ok := os.LookupEnv(envReadyFd); ok {
+		t.Fatal("ready fd env not cleared")
+	}
+	buf := make([]byte, 1)
+	if _, err := r.Read(buf); err != nil || buf[0] != 1 {
+		t.Fatalf("read ready = %v, %v", buf, err)
+	}
+	// 环境变量已经清除，再次调用不会写入
+	notifyReady()
+	w.Close()
+	if n, err := r.Read(buf); n != 0 || err != io.EOF {
+		t.Fatalf("second notify wrote %d bytes, err %v", n, err)
+	}
+
+	// 没有环境变量时什么都不做
+	notifyReady()
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package framework
+
+import "os"
+
+// defaultRestartSignals windows不支持传递文件描述符，默认不启用平滑重启
+var defaultRestartSignals []os.Signal
@@ -0,0 +1,422 @@
+package framework
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 备份文件名中的时间格式，例如 app-2006-01-02T15-04-05.000.log
+// 同一毫秒内切分多次时在时间后面加序号，例如 app-2006-01-02T15-04-05.000-1.log
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// renameFile 切分时重命名文件，测试中替换为会失败的实现
+var renameFile = os.Rename
+
+// RotateConfig 日志文件切分的配置
+type RotateConfig struct {
+	Filename   string        // 日志文件路径
+	MaxSize    int64         // 文件超过这个字节数时切分，为0时不按大小切分
+	Interval   time.Duration // 按时间切分的间隔，例如24h在每天0点切分，为0时不按时间切分
+	MaxBackups int           // 最多保留的备份文件数，为0时不限制
+	MaxAge     time.Duration // 备份文件最长保留时间，为0时不限制
+	Compress   bool          // 使用gzip压缩备份文件
+	BufferSize int           // 异步写入的队列长度，队列满时丢弃日志，默认1024
+
+	// ReopenOnSignal 收到SIGHUP时重新打开文件，配合外部的logrotate使用，windows下无效
+	// SIGHUP默认也会触发平滑重启，开启后需要从Server.RestartSignals中去掉Signals()
+	ReopenOnSignal bool
+}
+
+// RotateWriter 按大小或者时间切分的日志文件
+// 写入是异步的，磁盘慢时不会阻塞请求处理，队列满时丢弃并计数
+type RotateWriter struct {
+	config RotateConfig
+
+	queue   chan []byte
+	control chan rotateRequest
+	done    chan struct{}
+	dropped atomic.Uint64
+
+	// 以下字段只在写入goroutine中使用
+	file        *os.File
+	size        int64
+	periodStart time.Time
+
+	mill     chan struct{} // 通知清理备份文件
+	millDone chan struct{}
+
+	closeMu sync.RWMutex // 保证关闭队列之后不再写入
+	closed  bool
+	signals chan os.Signal
+}
+
+// rotateRequest 在写入goroutine中执行的操作
+type rotateRequest struct {
+	reopen bool // true为重新打开，false为切分
+	result chan error
+}
+
+// NewRotateWriter 打开日志文件并启动写入goroutine，文件已经存在时追加写入
+func NewRotateWriter(config RotateConfig) (*RotateWriter, error) {
+	if config.Filename == "" {
+		return nil, errors.New("rotate writer: empty filename")
+	}
+	if config.BufferSize <= 0 {
+		config.BufferSize = 1024
+	}
+	w := &RotateWriter{
+		config:   config,
+		queue:    make(chan []byte, config.BufferSize),
+		control:  make(chan rotateRequest),
+		done:     make(chan struct{}),
+		mill:     make(chan struct{}, 1),
+		millDone: make(chan struct{}),
+	}
+	if err := w.openExisting(); err != nil {
+		return nil, err
+	}
+	go w.run()
+	go w.runMill()
+	if config.ReopenOnSignal && len(reopenSignals) > 0 {
+		w.signals = make(chan os.Signal, 1)
+		signal.Notify(w.signals, reopenSignals...)
+		go w.watchSignals()
+	}
+	// 启动时清理一次过期的备份
+	w.notifyMill()
+	return w, nil
+}
+
+// Write 把日志放入队列，队列满或者已经关闭时丢弃，总是返回成功
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	buf := make([]byte, len(p))
+	copy(buf, p)
+	w.closeMu.RLock()
+	defer w.closeMu.RUnlock()
+	if w.closed {
+		w.dropped.Add(1)
+		return len(p), nil
+	}
+	select {
+	case w.queue <- buf:
+	default:
+		w.dropped.Add(1)
+	}
+	return len(p), nil
+}
+
+// Signals 触发重新打开文件的信号，没有开启ReopenOnSignal时为空
+func (w *RotateWriter) Signals() []os.Signal {
+	if !w.config.ReopenOnSignal {
+		return nil
+	}
+	return reopenSignals
+}
+
+// Dropped 因为队列满或者已经关闭而丢弃的日志条数
+func (w *RotateWriter) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// Rotate 立即切分文件
+func (w *RotateWriter) Rotate() error {
+	return w.request(false)
+}
+
+// Reopen 重新打开文件，外部工具移动了日志文件之后调用
+func (w *RotateWriter) Reopen() error {
+	return w.request(true)
+}
+
+func (w *RotateWriter) request(reopen bool) error {
+	req := rotateRequest{reopen: reopen, result: make(chan error, 1)}
+	select {
+	case w.control <- req:
+		return <-req.result
+	case <-w.done:
+		return os.ErrClosed
+	}
+}
+
+// Close 写完队列中的日志后关闭文件
+func (w *RotateWriter) Close() error {
+	w.closeMu.Lock()
+	if w.closed {
+		w.closeMu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	w.closeMu.Unlock()
+
+	if w.signals != nil {
+		signal.Stop(w.signals)
+	}
+	<-w.done
+	close(w.mill)
+	<-w.millDone
+	return nil
+}
+
+// run 写入goroutine，按顺序处理日志和切分请求
+func (w *RotateWriter) run() {
+	defer close(w.done)
+	for {
+		select {
+		case p, ok := <-w.queue:
+			if !ok {
+				if w.file != nil {
+					w.file.Close()
+				}
+				return
+			}
+			w.write(p)
+		case req := <-w.control:
+			if req.reopen {
+				req.result <- w.reopen()
+			} else {
+				req.result <- w.rotate()
+			}
+		}
+	}
+}
+
+func (w *RotateWriter) write(p []byte) {
+	now := time.Now()
+	if w.config.Interval > 0 && !w.period(now).Equal(w.periodStart) {
+		w.rotate()
+	} else if w.config.MaxSize > 0 && w.size+int64(len(p)) > w.config.MaxSize && w.size > 0 {
+		w.rotate()
+	}
+	if w.file == nil {
+		// 之前打开文件失败，重试，文件可能是没有切分成功的日志，不能清空
+		if err := w.openExisting(); err != nil {
+			w.dropped.Add(1)
+			return
+		}
+	}
+	n, _ := w.file.Write(p)
+	w.size += int64(n)
+}
+
+// period 当前时间所在的切分周期的开始时间，按本地时间对齐
+func (w *RotateWriter) period(t time.Time) time.Time {
+	_, offset := t.Zone()
+	shift := time.Duration(offset) * time.Second
+	return t.Add(shift).Truncate(w.config.Interval).Add(-shift)
+}
+
+// openExisting 追加打开已经存在的文件，不存在时新建
+func (w *RotateWriter) openExisting() error {
+	if err := os.MkdirAll(filepath.Dir(w.config.Filename), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(w.config.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file = f
+	w.size = info.Size()
+	if w.config.Interval > 0 {
+		w.periodStart = w.period(time.Now())
+	}
+	return nil
+}
+
+// rotate 把当前文件重命名为备份文件，然后新建文件
+// 重命名失败时继续追加写入原来的文件，按大小切分时下一次写入会重试
+func (w *RotateWriter) rotate() error {
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+	if _, err := os.Stat(w.config.Filename); err == nil {
+		if err := renameFile(w.config.Filename, w.backupName(time.Now())); err != nil {
+			return errors.Join(err, w.openExisting())
+		}
+	}
+	err := w.openExisting()
+	w.notifyMill()
+	return err
+}
+
+// reopen 重新打开文件，文件被外部移动后会新建
+func (w *RotateWriter) reopen() error {
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+	return w.openExisting()
+}
+
+// backupName 备份文件名，和已有的备份文件(包括压缩后的)重名时加序号
+func (w *RotateWriter) backupName(t time.Time) string {
+	dir, prefix, ext := w.nameParts()
+	stamp := t.Format(backupTimeFormat)
+	name := filepath.Join(dir, prefix+stamp+ext)
+	for seq := 1; backupExists(name); seq++ {
+		name = filepath.Join(dir, prefix+stamp+"-"+strconv.Itoa(seq)+ext)
+	}
+	return name
+}
+
+func backupExists(name string) bool {
+	for _, path := range []string{name, name + ".gz"} {
+		if _, err := os.Lstat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// nameParts 备份文件名的目录、前缀和扩展名
+func (w *RotateWriter) nameParts() (string, string, string) {
+	dir := filepath.Dir(w.config.Filename)
+	base := filepath.Base(w.config.Filename)
+	ext := filepath.Ext(base)
+	return dir, strings.TrimSuffix(base, ext) + "-", ext
+}
+
+func (w *RotateWriter) notifyMill() {
+	select {
+	case w.mill <- struct{}{}:
+	default:
+	}
+}
+
+// runMill 压缩和清理备份文件，和写入分开执行，避免影响写入
+func (w *RotateWriter) runMill() {
+	defer close(w.millDone)
+	for range w.mill {
+		w.millBackups()
+	}
+}
+
+// backupFile 一个备份文件
+type backupFile struct {
+	path string
+	time time.Time
+	seq  int // 同一时间的第几个备份
+}
+
+// parseBackupStamp 解析备份文件名中的时间和序号
+func parseBackupStamp(stamp string) (time.Time, int, bool) {
+	if t, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local); err == nil {
+		return t, 0, true
+	}
+	i := strings.LastIndex(stamp, "-")
+	if i < 0 {
+		return time.Time{}, 0, false
+	}
+	seq, err := strconv.Atoi(stamp[i+1:])
+	if err != nil || seq <= 0 {
+		return time.Time{}, 0, false
+	}
+	t, err := time.ParseInLocation(backupTimeFormat, stamp[:i], time.Local)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	return t, seq, true
+}
+
+func (w *RotateWriter) millBackups() {
+	dir, prefix, ext := w.nameParts()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var backups []backupFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimPrefix(name, prefix)
+		stamp = strings.TrimSuffix(strings.TrimSuffix(stamp, ".gz"), ext)
+		t, seq, ok := parseBackupStamp(stamp)
+		if !ok {
+			continue
+		}
+		backups = append(backups, backupFile{path: filepath.Join(dir, name), time: t, seq: seq})
+	}
+	// 从新到旧排序
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].time.Equal(backups[j].time) {
+			return backups[i].time.After(backups[j].time)
+		}
+		return backups[i].seq > backups[j].seq
+	})
+
+	var keep []backupFile
+	for i, b := range backups {
+		expired := w.config.MaxAge > 0 && time.Since(b.time) > w.config.MaxAge
+		if (w.config.MaxBackups > 0 && i >= w.config.MaxBackups) || expired {
+			os.Remove(b.path)
+			continue
+		}
+		keep = append(keep, b)
+	}
+	if !w.config.Compress {
+		return
+	}
+	for _, b := range keep {
+		if !strings.HasSuffix(b.path, ".gz") {
+			compressFile(b.path)
+		}
+	}
+}
+
+// compressFile gzip压缩文件，成功后删除原文件
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
+
+// watchSignals 收到信号时重新打开文件
+func (w *RotateWriter) watchSignals() {
+	for {
+		select {
+		case <-w.signals:
+			if err := w.Reopen(); err != nil {
+				return
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
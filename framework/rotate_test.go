@@ -0,0 +1,189 @@
+package framework
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readBackups 按从旧到新的顺序读取备份文件的内容
+func readBackups(t *testing.T, w *RotateWriter) []string {
+	t.Helper()
+	dir, prefix, ext := w.nameParts()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var backups []backupFile
+	for _, entry := range entries {
+		stamp, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok {
+			continue
+		}
+		tm, seq, ok := parseBackupStamp(strings.TrimSuffix(stamp, ext))
+		if !ok {
+			t.Fatalf("unexpected file %s", entry.Name())
+		}
+		backups = append(backups, backupFile{path: filepath.Join(dir, entry.Name()), time: tm, seq: seq})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].time.Equal(backups[j].time) {
+			return backups[i].time.Before(backups[j].time)
+		}
+		return backups[i].seq < backups[j].seq
+	})
+	contents := make([]string, 0, len(backups))
+	for _, b := range backups {
+		data, err := os.ReadFile(b.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents = append(contents, string(data))
+	}
+	return contents
+}
+
+// waitFileContent 等待异步写入完成
+func waitFileContent(t *testing.T, file string, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, _ := os.ReadFile(file)
+		if string(data) == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s = %q, want %q", file, data, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRotateWriterSize(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "logs", "app.log")
+	w, err := NewRotateWriter(RotateConfig{Filename: file, MaxSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 每行6个字节，写第二行时超过10个字节切分，同一毫秒内的切分使用序号区分
+	for _, line := range []string{"line1\n", "line2\n", "line3\n"} {
+		w.Write([]byte(line))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFileContent(t, file, "line3\n")
+	backups := readBackups(t, w)
+	if strings.Join(backups, "") != "line1\nline2\n" {
+		t.Fatalf("backups = %q", backups)
+	}
+	if w.Dropped() != 0 {
+		t.Fatalf("dropped %d", w.Dropped())
+	}
+
+	// 已有的文件追加写入
+	w, err = NewRotateWriter(RotateConfig{Filename: file, MaxSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("line4\n"))
+	w.Close()
+	waitFileContent(t, file, "line3\nline4\n")
+	if w.Write([]byte("late\n")); w.Dropped() != 1 {
+		t.Fatalf("write after close dropped %d", w.Dropped())
+	}
+}
+
+func TestRotateWriterMaxBackups(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.log")
+	w, err := NewRotateWriter(RotateConfig{Filename: file, MaxSize: 6, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"line1\n", "line2\n", "line3\n", "line4\n", "line5\n"} {
+		w.Write([]byte(line))
+	}
+	w.Close()
+
+	waitFileContent(t, file, "line5\n")
+	// 只保留最新的备份
+	if backups := readBackups(t, w); strings.Join(backups, "") != "line3\nline4\n" {
+		t.Fatalf("backups = %q", backups)
+	}
+}
+
+func TestRotateWriterRenameFails(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.log")
+	renameErr := errors.New("rename failed")
+	renameFile = func(string, string) error { return renameErr }
+	defer func() { renameFile = os.Rename }()
+
+	w, err := NewRotateWriter(RotateConfig{Filename: file, MaxSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Write([]byte("line1\n"))
+	w.Write([]byte("line2\n"))
+	// 切分失败时继续追加写入，不能清空原来的日志
+	waitFileContent(t, file, "line1\nline2\n")
+	if err := w.Rotate(); !errors.Is(err, renameErr) {
+		t.Fatalf("Rotate = %v, want rename error", err)
+	}
+	w.Write([]byte("line3\n"))
+	waitFileContent(t, file, "line1\nline2\nline3\n")
+	if backups := readBackups(t, w); len(backups) != 0 {
+		t.Fatalf("backups after failed rename = %q", backups)
+	}
+
+	renameFile = os.Rename
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("line4\n"))
+	waitFileContent(t, file, "line4\n")
+	if backups := readBackups(t, w); len(backups) != 1 || backups[0] != "line1\nline2\nline3\n" {
+		t.Fatalf("backups = %q", backups)
+	}
+}
+
+func TestRotateWriterBackupName(t *testing.T) {
+	dir := t.TempDir()
+	w := &RotateWriter{config: RotateConfig{Filename: filepath.Join(dir, "app.log")}}
+	now := time.Date(2024, 5, 6, 7, 8, 9, 123e6, time.Local)
+	base := filepath.Join(dir, "app-2024-05-06T07-08-09.123")
+
+	if name := w.backupName(now); name != base+".log" {
+		t.Fatalf("backupName = %s", name)
+	}
+	os.WriteFile(base+".log", nil, 0o644)
+	// 压缩后的备份也算重名
+	os.WriteFile(base+"-1.log.gz", nil, 0o644)
+	if name := w.backupName(now); name != base+"-2.log" {
+		t.Fatalf("backupName with collisions = %s", name)
+	}
+
+	tests := []struct {
+		stamp string
+		seq   int
+		ok    bool
+	}{
+		{stamp: "2024-05-06T07-08-09.123", ok: true},
+		{stamp: "2024-05-06T07-08-09.123-2", seq: 2, ok: true},
+		{stamp: "2024-05-06T07-08-09.123-0", ok: false},
+		{stamp: "2024-05-06T07-08-09.123-x", ok: false},
+		{stamp: "2024-05-06", ok: false},
+		{stamp: "other", ok: false},
+	}
+	for _, tt := range tests {
+		tm, seq, ok := parseBackupStamp(tt.stamp)
+		if ok != tt.ok || seq != tt.seq || (ok && !tm.Equal(now)) {
+			t.Errorf("parseBackupStamp(%s) = %v, %d, %v", tt.stamp, tm, seq, ok)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package framework
+
+import (
+	"os"
+	"syscall"
+)
+
+// reopenSignals 重新打开日志文件的信号
+var reopenSignals = []os.Signal{syscall.SIGHUP}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package framework
+
+import "os"
+
+// reopenSignals windows下不支持通过信号重新打开日志文件
+var reopenSignals []os.Signal
@@ -0,0 +1,241 @@
+package framework
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Server 应用服务，负责监听端口、启动、信号处理和优雅关闭
+type Server struct {
+	core *Core
+
+	ReadHeaderTimeout time.Duration // 读取请求头的超时时间，默认10s
+	ReadTimeout       time.Duration // 读取整个请求的超时时间，默认不限制
+	WriteTimeout      time.Duration // 写响应的超时时间，默认不限制，长连接(SSE)需要保持为0
+	IdleTimeout       time.Duration // keep-alive连接的空闲超时时间，默认120s
+	MaxHeaderBytes    int           // 请求头的最大字节数，默认1MB
+	ShutdownTimeout   time.Duration // 优雅关闭时等待请求处理完的最长时间，默认30s
+	StopHookTimeout   time.Duration // 关闭钩子的最长执行时间，和ShutdownTimeout分开计算，默认10s
+	DrainDelay        time.Duration // 标记为未就绪后继续接受请求的时间，留给负载均衡摘除流量，默认0
+	RestartSignals    []os.Signal   // 触发平滑重启的信号，默认SIGHUP和SIGUSR2，为空时不支持重启
+	RestartTimeout    time.Duration // 平滑重启时等待新进程就绪的最长时间，默认30s
+
+	H2C   bool              // 不使用tls的地址同时支持http/2(h2c)，包括prior knowledge和Upgrade: h2c两种方式
+	HTTP2 *http.HTTP2Config // http/2的参数，例如最大并发流数量和最大帧大小，为nil时使用默认值
+
+	onStart []func() error
+	onStop  []func(ctx context.Context) error
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+// NewServer 初始化服务
+func NewServer(core *Core) *Server {
+	return &Server{
+		core:              core,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+		ShutdownTimeout:   30 * time.Second,
+		StopHookTimeout:   10 * time.Second,
+		RestartSignals:    defaultRestartSignals,
+		RestartTimeout:    30 * time.Second,
+		stop:              make(chan struct{}),
+	}
+}
+
+// OnStart 注册启动钩子，在开始接受请求之前按注册顺序执行，出错时服务不会启动
+func (s *Server) OnStart(fn func() error) {
+	s.onStart = append(s.onStart, fn)
+}
+
+// OnStop 注册关闭钩子，在所有请求处理完之后按注册顺序执行，ctx的截止时间为StopHookTimeout
+func (s *Server) OnStop(fn func(ctx context.Context) error) {
+	s.onStop = append(s.onStop, fn)
+}
+
+// Stop 通知服务优雅关闭，Run等方法会在关闭完成后返回
+func (s *Server) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+// Run 监听tcp地址并提供http服务，阻塞到服务关闭
+// 由平滑重启或者systemd启动时使用继承的listener
+func (s *Server) Run(addr string) error {
+	return s.RunListeners(ListenerSpec{Addr: addr})
+}
+
+// RunTLS 监听tcp地址并提供https服务，阻塞到服务关闭
+func (s *Server) RunTLS(addr string, certFile string, keyFile string) error {
+	return s.RunListeners(ListenerSpec{Addr: addr, CertFile: certFile, KeyFile: keyFile})
+}
+
+// RunUnix 监听unix socket并提供http服务，阻塞到服务关闭
+func (s *Server) RunUnix(file string) error {
+	return s.RunListeners(ListenerSpec{Network: "unix", Addr: file})
+}
+
+// Serve 在已有的listener上提供http服务，阻塞到服务关闭
+func (s *Server) Serve(ln net.Listener) error {
+	return s.serve([]boundListener{{raw: ln, ln: ln}})
+}
+
+// newHTTPServer 按配置生成http.Server
+func (s *Server) newHTTPServer() *http.Server {
+	srv := &http.Server{
+		Handler:           s.core,
+		ReadHeaderTimeout: s.ReadHeaderTimeout,
+		ReadTimeout:       s.ReadTimeout,
+		WriteTimeout:      s.WriteTimeout,
+		IdleTimeout:       s.IdleTimeout,
+		MaxHeaderBytes:    s.MaxHeaderBytes,
+		HTTP2:             s.HTTP2,
+	}
+	if s.H2C {
+		srv.Protocols = new(http.Protocols)
+		srv.Protocols.SetHTTP1(true)
+		srv.Protocols.SetHTTP2(true)
+		srv.Protocols.SetUnencryptedHTTP2(true)
+	}
+	return srv
+}
+
+// serve 执行启动钩子，在所有listener上启动服务，等待退出信号后优雅关闭
+func (s *Server) serve(bound []boundListener) error {
+	for _, fn := range s.onStart {
+		if err := fn(); err != nil {
+			for _, b := range bound {
+				b.raw.Close()
+				b.release()
+			}
+			return err
+		}
+	}
+
+	defer func() {
+		for _, b := range bound {
+			b.release()
+		}
+	}()
+
+	srv := s.newHTTPServer()
+	errCh := make(chan error, len(bound)+1)
+	if s.H2C {
+		// 通过Upgrade: h2c升级的连接由这个listener交回http.Server，和其他listener一起关闭
+		upgrades := newConnListener()
+		srv.Handler = &h2cUpgradeHandler{next: srv.Handler, conns: upgrades}
+		go func() {
+			errCh <- serveListener(srv, upgrades)
+		}()
+	}
+	raws := make([]net.Listener, 0, len(bound))
+	for _, b := range bound {
+		raws = append(raws, b.raw)
+		go func(ln net.Listener) {
+			errCh <- serveListener(srv, ln)
+		}(b.ln)
+	}
+
+	// 信号通道需要有缓冲，否则在接收之前到达的信号会丢失
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	defer signal.Stop(quit)
+	restart := make(chan os.Signal, 1)
+	if len(s.RestartSignals) > 0 {
+		signal.Notify(restart, s.RestartSignals...)
+		defer signal.Stop(restart)
+	}
+
+	// 平滑重启时通知父进程已经就绪，需要在注册信号之后，否则父进程随后发送的信号会直接结束进程
+	notifyReady()
+
+	for {
+		select {
+		case err := <-errCh:
+			// 一个listener出错时关闭所有listener
+			return errors.Join(err, s.shutdown(srv))
+		case <-quit:
+		case <-s.stop:
+		case <-restart:
+			// 新进程就绪后当前进程停止接受请求，处理完剩余请求后退出
+			if err := s.restart(raws); err != nil {
+				s.core.Logger().Error("graceful restart failed", "error", err)
+				continue
+			}
+			s.core.Logger().Info("graceful restart: new process is ready, draining", "pid", os.Getpid())
+		}
+		return s.shutdown(srv)
+	}
+}
+
+// shutdown 在ShutdownTimeout内等待请求处理完，然后执行关闭钩子
+// 先标记为未就绪让健康检查失败，等待DrainDelay后停止接受新请求，再等待正在处理的请求和goroutine
+func (s *Server) shutdown(srv *http.Server) error {
+	ctx := context.Background()
+	if s.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.ShutdownTimeout)
+		defer cancel()
+	}
+
+	inflight := s.core.Inflight()
+	inflight.SetDraining()
+	if s.DrainDelay > 0 {
+		select {
+		case <-time.After(s.DrainDelay):
+		case <-ctx.Done():
+		}
+	}
+
+	err := srv.Shutdown(ctx)
+	// http.Server不会等待被hijack的连接和请求中启动的goroutine
+	if err == nil {
+		err = inflight.Wait(ctx)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		s.logCutOff(inflight)
+		// 超时后强制关闭剩余的连接
+		srv.Close()
+	}
+	s.core.StopAdmin()
+	// 等待请求时可能已经用完了ctx，关闭钩子使用单独的超时，保证有时间释放资源
+	return errors.Join(err, s.runStopHooks())
+}
+
+// logCutOff 记录超时后仍未处理完的请求
+func (s *Server) logCutOff(inflight *InflightTracker) {
+	now := time.Now()
+	for _, req := range inflight.Snapshot() {
+		s.core.Logger().Warn("shutdown cut off request", "method", req.Method, "path", req.Path,
+			"route", req.Route, "client_ip", req.Client, "elapsed", now.Sub(req.Start).Round(time.Millisecond))
+	}
+	if n := inflight.Goroutines(); n > 0 {
+		s.core.Logger().Warn("shutdown cut off goroutines", "count", n)
+	}
+}
+
+func (s *Server) runStopHooks() error {
+	ctx := context.Background()
+	if s.StopHookTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.StopHookTimeout)
+		defer cancel()
+	}
+	var errs []error
+	for _, fn := range s.onStop {
+		if err := fn(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
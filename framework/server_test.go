@@ -0,0 +1,228 @@
+package framework
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer 可以并发写入的日志输出
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// startTestServer 在本地随机端口上运行服务，返回地址和等待Serve返回的函数
+func startTestServer(t *testing.T, s *Server) (string, func() error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ln) }()
+
+	var once sync.Once
+	var serveErr error
+	wait := func() error {
+		once.Do(func() {
+			select {
+			case serveErr = <-done:
+			case <-time.After(5 * time.Second):
+				t.Error("server did not stop")
+			}
+		})
+		return serveErr
+	}
+	t.Cleanup(func() {
+		s.Stop()
+		wait()
+	})
+	return "http://" + ln.Addr().String(), wait
+}
+
+func getBody(url string) (string, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func newServerTestCore(logs io.Writer) *Core {
+	core := NewCore()
+	core.SetMode(TestMode)
+	core.SetLogger(NewLogger(logs, LevelDebug, JSONEncoder{}))
+	core.Get("/ping", func(c *Context) error {
+		c.Text("pong")
+		return nil
+	})
+	return core
+}
+
+func TestServerStartHookError(t *testing.T) {
+	s := NewServer(newServerTestCore(io.Discard))
+	var calls []string
+	s.OnStart(func() error {
+		calls = append(calls, "first")
+		return nil
+	})
+	hookErr := errors.New("db unavailable")
+	s.OnStart(func() error {
+		calls = append(calls, "second")
+		return hookErr
+	})
+	s.OnStart(func() error {
+		calls = append(calls, "third")
+		return nil
+	})
+	s.OnStop(func(context.Context) error {
+		calls = append(calls, "stop")
+		return nil
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Serve(ln); err != hookErr {
+		t.Fatalf("Serve = %v, want start hook error", err)
+	}
+	if strings.Join(calls, ",") != "first,second" {
+		t.Fatalf("calls = %v", calls)
+	}
+	// 启动失败时listener要关闭
+	if _, err := ln.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("Accept after failed start = %v, want net.ErrClosed", err)
+	}
+}
+
+func TestServerStop(t *testing.T) {
+	s := NewServer(newServerTestCore(io.Discard))
+	var calls []string
+	var hookCtxErr error
+	var hookDeadline time.Duration
+	s.OnStop(func(ctx context.Context) error {
+		calls = append(calls, "first")
+		hookCtxErr = ctx.Err()
+		if deadline, ok := ctx.Deadline(); ok {
+			hookDeadline = time.Until(deadline)
+		}
+		return nil
+	})
+	stopErr := errors.New("flush failed")
+	s.OnStop(func(context.Context) error {
+		calls = append(calls, "second")
+		return stopErr
+	})
+
+	url, wait := startTestServer(t, s)
+	if body, err := getBody(url + "/ping"); err != nil || body != "pong" {
+		t.Fatalf("GET /ping = %q, %v", body, err)
+	}
+
+	s.Stop()
+	s.Stop()
+	if err := wait(); !errors.Is(err, stopErr) {
+		t.Fatalf("Serve = %v, want stop hook error", err)
+	}
+	if strings.Join(calls, ",") != "first,second" {
+		t.Fatalf("stop hooks = %v", calls)
+	}
+	if hookCtxErr != nil || hookDeadline <= 0 || hookDeadline > s.StopHookTimeout {
+		t.Fatalf("stop hook ctx err = %v, deadline in %v", hookCtxErr, hookDeadline)
+	}
+	if !s.core.Inflight().Draining() {
+		t.Fatal("not draining after stop")
+	}
+	if _, err := getBody(url + "/ping"); err == nil {
+		t.Fatal("server still accepts requests after stop")
+	}
+}
+
+func TestServerDrainDelay(t *testing.T) {
+	s := NewServer(newServerTestCore(io.Discard))
+	s.DrainDelay = 300 * time.Millisecond
+	url, wait := startTestServer(t, s)
+
+	start := time.Now()
+	s.Stop()
+	deadline := time.Now().Add(time.Second)
+	for !s.core.Inflight().Draining() {
+		if time.Now().After(deadline) {
+			t.Fatal("not draining after stop")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// 摘除流量期间仍然处理新请求
+	if body, err := getBody(url + "/ping"); err != nil || body != "pong" {
+		t.Fatalf("GET during drain delay = %q, %v", body, err)
+	}
+	if err := wait(); err != nil {
+		t.Fatalf("Serve = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < s.DrainDelay {
+		t.Fatalf("stopped after %v, before drain delay %v", elapsed, s.DrainDelay)
+	}
+}
+
+func TestServerShutdownTimeout(t *testing.T) {
+	logs := &syncBuffer{}
+	core := newServerTestCore(logs)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	core.Get("/slow", func(c *Context) error {
+		close(started)
+		<-release
+		return nil
+	})
+	defer close(release)
+
+	s := NewServer(core)
+	s.ShutdownTimeout = 100 * time.Millisecond
+	hookCtxErr := errors.New("stop hook not called")
+	s.OnStop(func(ctx context.Context) error {
+		hookCtxErr = ctx.Err()
+		return nil
+	})
+	url, wait := startTestServer(t, s)
+
+	go http.Get(url + "/slow")
+	<-started
+	start := time.Now()
+	s.Stop()
+	err := wait()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Serve = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("shutdown took %v", elapsed)
+	}
+	// 关闭钩子不能拿到已经超时的ctx
+	if hookCtxErr != nil {
+		t.Fatalf("stop hook ctx err = %v", hookCtxErr)
+	}
+	if !strings.Contains(logs.String(), "shutdown cut off request") || !strings.Contains(logs.String(), "/slow") {
+		t.Fatalf("cut off request not logged: %s", logs)
+	}
+}
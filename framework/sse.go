@@ -0,0 +1,335 @@
+package framework
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-contrib/sse"
+)
+
+// SSEvent 一个Server-Sent Event，Data为字符串时原样输出，其他类型输出json
+type SSEvent struct {
+	ID    string
+	Event string
+	Retry uint // 客户端断线重连的间隔，单位毫秒
+	Data  interface{}
+}
+
+// sseHeartbeat 心跳使用注释行，客户端会忽略
+var sseHeartbeat = []byte(": ping\n\n")
+
+// LastEventID 获取客户端断线重连时携带的最后一个事件ID
+func (ctx *Context) LastEventID() string {
+	id, _ := ctx.Header("Last-Event-ID")
+	return id
+}
+
+// startSSE 在第一次输出事件前设置响应头
+func (ctx *Context) startSSE() {
+	header := ctx.responseWriter.Header()
+	if header.Get("Content-Type") == sse.ContentType {
+		return
+	}
+	header.Set("Content-Type", sse.ContentType)
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// 关闭nginx的响应缓冲
+	header.Set("X-Accel-Buffering", "no")
+}
+
+// flush 将已经输出的内容立即发送给客户端
+func (ctx *Context) flush() {
+	ctx.responseWriter.Flush()
+}
+
+// SSEWrite 输出一个事件并立即发送，客户端断开时返回错误
+func (ctx *Context) SSEWrite(event SSEvent) error {
+	if err := ctx.BaseContext().Err(); err != nil {
+		return err
+	}
+	ctx.startSSE()
+	err := sse.Encode(ctx.responseWriter, sse.Event{
+		Id:    event.ID,
+		Event: event.Event,
+		Retry: event.Retry,
+		Data:  event.Data,
+	})
+	if err != nil {
+		return err
+	}
+	ctx.flush()
+	return nil
+}
+
+// SSEvent 输出一个名称为name的事件
+func (ctx *Context) SSEvent(name string, message interface{}) IResponse {
+	// 客户端断开属于正常结束，不需要交给错误处理函数
+	if err := ctx.SSEWrite(SSEvent{Event: name, Data: message}); err != nil && ctx.BaseContext().Err() == nil {
+		ctx.AddError(err)
+	}
+	return ctx
+}
+
+// Stream 循环调用step输出数据，每次调用后立即发送，step返回false时结束
+// 返回值表示是否因为客户端断开而结束
+func (ctx *Context) Stream(step func(w io.Writer) bool) bool {
+	done := ctx.BaseContext().Done()
+	for {
+		select {
+		case <-done:
+			return true
+		default:
+			keepOpen := step(ctx.responseWriter)
+			ctx.flush()
+			if !keepOpen {
+				return false
+			}
+		}
+	}
+}
+
+// SSEStream 持续输出events中的事件，空闲超过heartbeat时发送心跳，heartbeat为0表示不发送
+// events关闭时返回nil，客户端断开时返回对应的错误
+func (ctx *Context) SSEStream(events <-chan SSEvent, heartbeat time.Duration) error {
+	ctx.startSSE()
+	ctx.responseWriter.WriteHeader(http.StatusOK)
+	ctx.flush()
+
+	var ticker *time.Ticker
+	var tick <-chan time.Time
+	if heartbeat > 0 {
+		ticker = time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	done := ctx.BaseContext().Done()
+	for {
+		select {
+		case <-done:
+			return ctx.BaseContext().Err()
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := ctx.SSEWrite(event); err != nil {
+				return err
+			}
+			if ticker != nil {
+				ticker.Reset(heartbeat)
+			}
+		case <-tick:
+			if _, err := ctx.responseWriter.Write(sseHeartbeat); err != nil {
+				return err
+			}
+			ctx.flush()
+		}
+	}
+}
+
+// DropPolicy 订阅者缓冲区满时的处理策略
+type DropPolicy int
+
+const (
+	// DropOldest 丢弃缓冲区中最早的事件
+	DropOldest DropPolicy = iota
+	// DropNewest 丢弃新的事件
+	DropNewest
+	// DropSubscriber 断开跟不上的订阅者，客户端重连后通过Last-Event-ID补发
+	DropSubscriber
+)
+
+// Subscriber 广播的一个订阅者
+type Subscriber struct {
+	events  chan SSEvent
+	dropped uint64
+	once    sync.Once
+}
+
+// Events 订阅者收到的事件，订阅取消时关闭
+func (s *Subscriber) Events() <-chan SSEvent {
+	return s.events
+}
+
+// Dropped 因为缓冲区满被丢弃的事件数
+func (s *Subscriber) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+func (s *Subscriber) close() {
+	s.once.Do(func() {
+		close(s.events)
+	})
+}
+
+// Broadcaster 将事件广播给所有订阅者
+// 每个订阅者有独立的缓冲区，慢的订阅者不会阻塞发布者
+type Broadcaster struct {
+	mu          sync.Mutex
+	subscribers map[*Subscriber]struct{}
+	bufferSize  int
+	policy      DropPolicy
+	heartbeat   time.Duration
+
+	history     []SSEvent // 最近发布的事件，用于断线重连补发
+	historySize int
+	nextID      uint64
+	closed      bool
+}
+
+// NewBroadcaster 初始化广播，bufferSize为每个订阅者的缓冲区大小
+func NewBroadcaster(bufferSize int, policy DropPolicy) *Broadcaster {
+	if bufferSize <= 0 {
+		bufferSize = 1
+	}
+	return &Broadcaster{
+		subscribers: map[*Subscriber]struct{}{},
+		bufferSize:  bufferSize,
+		policy:      policy,
+	}
+}
+
+// SetHistory 设置保留的历史事件数，用于客户端断线重连时补发
+func (b *Broadcaster) SetHistory(size int) *Broadcaster {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.historySize = size
+	if len(b.history) > size {
+		b.history = b.history[len(b.history)-size:]
+	}
+	return b
+}
+
+// SetHeartbeat 设置Handler的心跳间隔
+func (b *Broadcaster) SetHeartbeat(heartbeat time.Duration) *Broadcaster {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.heartbeat = heartbeat
+	return b
+}
+
+// Publish 发布事件，没有ID的事件会自动生成递增的ID
+func (b *Broadcaster) Publish(event SSEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.nextID++
+	if event.ID == "" {
+		event.ID = strconv.FormatUint(b.nextID, 10)
+	}
+	if b.historySize > 0 {
+		b.history = append(b.history, event)
+		if len(b.history) > b.historySize {
+			b.history = b.history[len(b.history)-b.historySize:]
+		}
+	}
+	for s := range b.subscribers {
+		b.send(s, event)
+	}
+}
+
+// send 向订阅者发送事件，调用方需要持有锁
+func (b *Broadcaster) send(s *Subscriber, event SSEvent) {
+	select {
+	case s.events <- event:
+		return
+	default:
+	}
+
+	atomic.AddUint64(&s.dropped, 1)
+	switch b.policy {
+	case DropOldest:
+		// 腾出一个位置，发布者持有锁，不会有其他写入者抢占
+		select {
+		case <-s.events:
+		default:
+		}
+		select {
+		case s.events <- event:
+		default:
+		}
+	case DropSubscriber:
+		delete(b.subscribers, s)
+		s.close()
+	}
+}
+
+// Subscribe 订阅广播，lastEventID不为空时补发之后的历史事件
+// 历史中找不到lastEventID时补发全部历史事件，超过缓冲区大小时只补发最新的部分
+func (b *Broadcaster) Subscribe(lastEventID string) *Subscriber {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := &Subscriber{events: make(chan SSEvent, b.bufferSize)}
+	if b.closed {
+		s.close()
+		return s
+	}
+	b.subscribers[s] = struct{}{}
+
+	if lastEventID != "" {
+		missed := b.history
+		for i, event := range b.history {
+			if event.ID == lastEventID {
+				missed = b.history[i+1:]
+				break
+			}
+		}
+		// 补发不能超过缓冲区，否则DropSubscriber策略下新的订阅者会被立即断开
+		if len(missed) > b.bufferSize {
+			atomic.AddUint64(&s.dropped, uint64(len(missed)-b.bufferSize))
+			missed = missed[len(missed)-b.bufferSize:]
+		}
+		for _, event := range missed {
+			s.events <- event
+		}
+	}
+	return s
+}
+
+// Unsubscribe 取消订阅
+func (b *Broadcaster) Unsubscribe(s *Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscribers, s)
+	s.close()
+}
+
+// Count 当前订阅者数量
+func (b *Broadcaster) Count() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// Close 关闭广播，所有订阅者的事件流都会结束
+func (b *Broadcaster) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subscribers {
+		delete(b.subscribers, s)
+		s.close()
+	}
+}
+
+// Handler 将广播挂载为路由，客户端通过EventSource订阅
+func (b *Broadcaster) Handler() ControllerHandler {
+	return func(c *Context) error {
+		s := b.Subscribe(c.LastEventID())
+		defer b.Unsubscribe(s)
+
+		b.mu.Lock()
+		heartbeat := b.heartbeat
+		b.mu.Unlock()
+		// 客户端断开属于正常结束
+		if err := c.SSEStream(s.Events(), heartbeat); err != nil && c.BaseContext().Err() == nil {
+			return err
+		}
+		return nil
+	}
+}
@@ -0,0 +1,228 @@
+package framework
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSEWrite(t *testing.T) {
+	tests := []struct {
+		name  string
+		event SSEvent
+		want  string
+	}{
+		{name: "data only", event: SSEvent{Data: "hello"}, want: "data:hello\n\n"},
+		{name: "multi line", event: SSEvent{Data: "line1\nline2\n\nline4"}, want: "data:line1\ndata:line2\ndata:\ndata:line4\n\n"},
+		{name: "id event retry", event: SSEvent{ID: "7", Event: "update", Retry: 3000, Data: "x"}, want: "id:7\nevent:update\nretry:3000\ndata:x\n\n"},
+		{name: "id newline escaped", event: SSEvent{ID: "1\n2", Data: "x"}, want: "id:1\\n2\ndata:x\n\n"},
+		{name: "json", event: SSEvent{Data: map[string]int{"n": 1}}, want: "data:{\"n\":1}\n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			ctx := NewContext(httptest.NewRequest("GET", "/", nil), rec)
+			if err := ctx.SSEWrite(tt.event); err != nil {
+				t.Fatal(err)
+			}
+			if got := rec.Body.String(); got != tt.want {
+				t.Fatalf("body = %q, want %q", got, tt.want)
+			}
+			if rec.Header().Get("Content-Type") != "text/event-stream" || rec.Header().Get("Cache-Control") != "no-cache" {
+				t.Fatalf("header = %v", rec.Header())
+			}
+			if !rec.Flushed {
+				t.Fatal("event not flushed")
+			}
+		})
+	}
+}
+
+func TestSSEWriteClientGone(t *testing.T) {
+	reqCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec := httptest.NewRecorder()
+	ctx := NewContext(httptest.NewRequest("GET", "/", nil).WithContext(reqCtx), rec)
+	if err := ctx.SSEWrite(SSEvent{Data: "x"}); err != context.Canceled {
+		t.Fatalf("SSEWrite = %v, want context.Canceled", err)
+	}
+	if rec.Body.Len() != 0 {
+		t.Fatalf("body = %q", rec.Body)
+	}
+}
+
+// drain 读出订阅者缓冲区中的全部事件ID
+func drain(s *Subscriber) []string {
+	var ids []string
+	for {
+		select {
+		case event, ok := <-s.Events():
+			if !ok {
+				return ids
+			}
+			ids = append(ids, event.ID)
+		default:
+			return ids
+		}
+	}
+}
+
+func TestBroadcasterPolicies(t *testing.T) {
+	tests := []struct {
+		policy     DropPolicy
+		want       []string
+		wantClosed bool
+	}{
+		{policy: DropOldest, want: []string{"3", "4"}},
+		{policy: DropNewest, want: []string{"1", "2"}},
+		{policy: DropSubscriber, want: []string{"1", "2"}, wantClosed: true},
+	}
+	for _, tt := range tests {
+		b := NewBroadcaster(2, tt.policy)
+		s := b.Subscribe("")
+		for i := 0; i < 4; i++ {
+			b.Publish(SSEvent{Data: i})
+		}
+		if got := drain(s); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("policy %d: events = %v, want %v", tt.policy, got, tt.want)
+		}
+		if s.Dropped() != 2 && !tt.wantClosed {
+			t.Errorf("policy %d: dropped = %d, want 2", tt.policy, s.Dropped())
+		}
+		open := true
+		select {
+		case _, open = <-s.Events():
+		default:
+		}
+		if tt.wantClosed == open {
+			t.Errorf("policy %d: events open = %v", tt.policy, open)
+		}
+		if wantCount := map[bool]int{true: 0, false: 1}[tt.wantClosed]; b.Count() != wantCount {
+			t.Errorf("policy %d: count = %d, want %d", tt.policy, b.Count(), wantCount)
+		}
+	}
+}
+
+func TestBroadcasterReplay(t *testing.T) {
+	tests := []struct {
+		name        string
+		bufferSize  int
+		policy      DropPolicy
+		lastEventID string
+		want        []string
+		wantDropped uint64
+	}{
+		{name: "no last id", bufferSize: 10, lastEventID: "", want: nil},
+		{name: "after last id", bufferSize: 10, lastEventID: "3", want: []string{"4", "5"}},
+		{name: "up to date", bufferSize: 10, lastEventID: "5", want: nil},
+		{name: "unknown id replays history", bufferSize: 10, lastEventID: "x", want: []string{"2", "3", "4", "5"}},
+		// 历史比缓冲区大时只补发最新的事件，DropSubscriber也不会断开新的订阅者
+		{name: "capped to buffer", bufferSize: 2, policy: DropSubscriber, lastEventID: "x", want: []string{"4", "5"}, wantDropped: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBroadcaster(tt.bufferSize, tt.policy).SetHistory(4)
+			for i := 0; i < 5; i++ {
+				b.Publish(SSEvent{Data: i})
+			}
+			s := b.Subscribe(tt.lastEventID)
+			if got := drain(s); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("replayed = %v, want %v", got, tt.want)
+			}
+			if s.Dropped() != tt.wantDropped {
+				t.Fatalf("dropped = %d, want %d", s.Dropped(), tt.wantDropped)
+			}
+			if b.Count() != 1 {
+				t.Fatalf("count = %d, subscriber was dropped", b.Count())
+			}
+			b.Publish(SSEvent{ID: "live"})
+			if got := drain(s); len(got) != 1 || got[0] != "live" {
+				t.Fatalf("live events = %v", got)
+			}
+		})
+	}
+}
+
+func TestBroadcasterClose(t *testing.T) {
+	b := NewBroadcaster(1, DropOldest)
+	s1 := b.Subscribe("")
+	s2 := b.Subscribe("")
+	b.Unsubscribe(s1)
+	b.Unsubscribe(s1)
+	if _, ok := <-s1.Events(); ok {
+		t.Fatal("unsubscribed events still open")
+	}
+	b.Close()
+	if _, ok := <-s2.Events(); ok {
+		t.Fatal("events still open after Close")
+	}
+	b.Publish(SSEvent{Data: "ignored"})
+	s3 := b.Subscribe("")
+	if _, ok := <-s3.Events(); ok || b.Count() != 0 {
+		t.Fatal("subscribe after Close returned an open subscriber")
+	}
+}
+
+func TestBroadcasterHandler(t *testing.T) {
+	b := NewBroadcaster(8, DropOldest).SetHistory(8).SetHeartbeat(20 * time.Millisecond)
+	b.Publish(SSEvent{Event: "old", Data: "a"})
+	b.Publish(SSEvent{Event: "old", Data: "b"})
+
+	core := NewCore()
+	core.SetMode(TestMode)
+	core.Get("/events", b.Handler())
+	server := httptest.NewServer(core)
+	defer server.Close()
+
+	reqCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(reqCtx, "GET", server.URL+"/events", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", resp.Header.Get("Content-Type"))
+	}
+
+	b.Publish(SSEvent{Event: "new", Data: "c"})
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 7 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read after %q: %v", lines, err)
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+	want := []string{"id:2", "event:old", "data:b", "", "id:3", "event:new", "data:c"}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Fatalf("stream = %q, want %q", lines, want)
+	}
+
+	// 空闲时发送心跳
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line == ": ping\n" {
+			break
+		}
+	}
+
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for b.Count() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("subscriber not removed after client disconnect")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,290 @@
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"html/template"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StaticConfig 静态文件服务配置
+type StaticConfig struct {
+	Root     string          // 本地目录，和FS二选一
+	FS       http.FileSystem // 文件系统，embed.FS可以通过 http.FS(fsys) 转换
+	Index    string          // 目录的默认文件，默认为index.html
+	Browse   bool            // 没有默认文件时是否列出目录内容，默认关闭
+	Compress bool            // 客户端支持gzip时优先返回同名的.gz预压缩文件
+	SPA      bool            // 单页应用模式，找不到文件时返回根目录的默认文件
+	MaxAge   time.Duration   // 设置Cache-Control的max-age，0表示不设置
+}
+
+// staticServer 静态文件服务
+type staticServer struct {
+	config StaticConfig
+	etags  sync.Map // 没有修改时间的文件(例如embed.FS)按内容计算的ETag
+}
+
+func newStaticServer(config StaticConfig) *staticServer {
+	if config.FS == nil {
+		config.FS = http.Dir(config.Root)
+	}
+	if config.Index == "" {
+		config.Index = "index.html"
+	}
+	return &staticServer{config: config}
+}
+
+// staticRoute 为前缀生成匹配剩余全部路径的路由
+func staticRoute(prefix string) string {
+	return strings.TrimSuffix(prefix, "/") + "/*filepath"
+}
+
+// Static 将目录root下的文件挂载到prefix下
+func (c *Core) Static(prefix string, root string) {
+	c.StaticWithConfig(prefix, StaticConfig{Root: root})
+}
+
+// StaticFS 将文件系统挂载到prefix下，embed.FS可以通过 http.FS(fsys) 转换
+func (c *Core) StaticFS(prefix string, fsys http.FileSystem) {
+	c.StaticWithConfig(prefix, StaticConfig{FS: fsys})
+}
+
+// StaticWithConfig 按配置挂载静态文件服务
+func (c *Core) StaticWithConfig(prefix string, config StaticConfig) {
+	handler := newStaticServer(config).serve
+	c.Get(staticRoute(prefix), handler)
+	c.Head(staticRoute(prefix), handler)
+}
+
+// StaticFile 将单个文件挂载到url上
+func (c *Core) StaticFile(url string, file string) {
+	handler := func(ctx *Context) error {
+		return ctx.serveFile(file, "")
+	}
+	c.Get(url, handler)
+	c.Head(url, handler)
+}
+
+// Static 将目录root下的文件挂载到prefix下
+func (g *Group) Static(prefix string, root string) {
+	g.StaticWithConfig(prefix, StaticConfig{Root: root})
+}
+
+// StaticFS 将文件系统挂载到prefix下
+func (g *Group) StaticFS(prefix string, fsys http.FileSystem) {
+	g.StaticWithConfig(prefix, StaticConfig{FS: fsys})
+}
+
+// StaticWithConfig 按配置挂载静态文件服务
+func (g *Group) StaticWithConfig(prefix string, config StaticConfig) {
+	handler := newStaticServer(config).serve
+	g.Get(staticRoute(prefix), handler)
+	g.Head(staticRoute(prefix), handler)
+}
+
+// StaticFile 将单个文件挂载到url上
+func (g *Group) StaticFile(url string, file string) {
+	handler := func(ctx *Context) error {
+		return ctx.serveFile(file, "")
+	}
+	g.Get(url, handler)
+	g.Head(url, handler)
+}
+
+// serve 处理静态文件请求
+func (s *staticServer) serve(c *Context) error {
+	name, _ := c.ParamString("filepath", "")
+	name = path.Clean("/" + name)
+
+	f, info, err := s.open(name)
+	if err != nil {
+		if !s.config.SPA || !errors.Is(err, fs.ErrNotExist) {
+			return c.staticError(err)
+		}
+		// 单页应用的前端路由，统一返回默认文件
+		name = "/" + s.config.Index
+		if f, info, err = s.open(name); err != nil {
+			return c.staticError(err)
+		}
+	}
+	defer f.Close()
+
+	if info.IsDir() {
+		// 目录需要以/结尾，保证页面中的相对路径正确
+		if urlPath := c.request.URL.Path; !strings.HasSuffix(urlPath, "/") {
+			http.Redirect(c.responseWriter, c.request, path.Base(urlPath)+"/", http.StatusMovedPermanently)
+			return nil
+		}
+		index := path.Join(name, s.config.Index)
+		indexFile, indexInfo, err := s.open(index)
+		if err == nil && !indexInfo.IsDir() {
+			defer indexFile.Close()
+			return s.serveContent(c, index, indexFile, indexInfo)
+		}
+		if indexFile != nil {
+			indexFile.Close()
+		}
+		if !s.config.Browse {
+			return c.staticError(fs.ErrNotExist)
+		}
+		return s.listDir(c, f)
+	}
+
+	// 优先返回预压缩的文件
+	if s.config.Compress {
+		c.responseWriter.Header().Add("Vary", "Accept-Encoding")
+		if acceptsGzip(c.request) {
+			if gz, gzInfo, err := s.open(name + ".gz"); err == nil {
+				defer gz.Close()
+				if !gzInfo.IsDir() {
+					c.responseWriter.Header().Set("Content-Encoding", "gzip")
+					c.responseWriter.Header().Set("Content-Type", contentTypeByName(name))
+					return s.serveContent(c, name+".gz", gz, gzInfo)
+				}
+			}
+		}
+	}
+	return s.serveContent(c, name, f, info)
+}
+
+func (s *staticServer) open(name string) (http.File, fs.FileInfo, error) {
+	f, err := s.config.FS.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, info, nil
+}
+
+func (s *staticServer) serveContent(c *Context, name string, f http.File, info fs.FileInfo) error {
+	if s.config.MaxAge > 0 {
+		c.responseWriter.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(s.config.MaxAge.Seconds())))
+	}
+	if info.ModTime().IsZero() {
+		// 没有修改时间的文件内容不会变化，按内容计算一次ETag后缓存
+		etag, ok := s.etags.Load(name)
+		if !ok {
+			h := fnv.New64a()
+			if _, err := io.Copy(h, f); err != nil {
+				return err
+			}
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			etag = fmt.Sprintf(`"%x"`, h.Sum64())
+			s.etags.Store(name, etag)
+		}
+		c.responseWriter.Header().Set("Etag", etag.(string))
+	} else {
+		c.responseWriter.Header().Set("Etag", fileETag(info))
+	}
+	http.ServeContent(c.responseWriter, c.request, name, info.ModTime(), f)
+	return nil
+}
+
+var dirListTemplate = template.Must(template.New("dir").Parse(`<!doctype html>
+<meta name="viewport" content="width=device-width">
+<pre>
+{{range .}}<a href="{{.URL}}">{{.Name}}</a>
+{{end}}</pre>
+`))
+
+// listDir 输出目录列表
+func (s *staticServer) listDir(c *Context, dir http.File) error {
+	infos, err := dir.Readdir(-1)
+	if err != nil {
+		return err
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+
+	type entry struct{ Name, URL string }
+	entries := make([]entry, 0, len(infos))
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() {
+			name += "/"
+		}
+		entries = append(entries, entry{Name: name, URL: (&url.URL{Path: name}).String()})
+	}
+	c.responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if c.request.Method == http.MethodHead {
+		return nil
+	}
+	return dirListTemplate.Execute(c.responseWriter, entries)
+}
+
+// serveFile 输出本地的单个文件，支持Range和条件请求
+// disposition不为空时设置Content-Disposition，文件打开失败时不设置
+func (ctx *Context) serveFile(name string, disposition string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return ctx.staticError(err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return ctx.staticError(err)
+	}
+	if info.IsDir() {
+		return ctx.staticError(fs.ErrNotExist)
+	}
+	if disposition != "" {
+		ctx.responseWriter.Header().Set("Content-Disposition", disposition)
+	}
+	ctx.responseWriter.Header().Set("Etag", fileETag(info))
+	http.ServeContent(ctx.responseWriter, ctx.request, info.Name(), info.ModTime(), f)
+	return nil
+}
+
+// staticError 文件不存在或者没有权限时直接返回对应状态码，其他错误交给错误处理函数
+func (ctx *Context) staticError(err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		http.Error(ctx.responseWriter, "404 page not found", http.StatusNotFound)
+		return nil
+	case errors.Is(err, fs.ErrPermission):
+		http.Error(ctx.responseWriter, "403 Forbidden", http.StatusForbidden)
+		return nil
+	}
+	return err
+}
+
+// fileETag 根据文件大小和修改时间生成弱ETag
+func fileETag(info fs.FileInfo) string {
+	return fmt.Sprintf(`W/"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
+
+// contentTypeByName 根据文件后缀获取Content-Type
+func contentTypeByName(name string) string {
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		return ctype
+	}
+	return "application/octet-stream"
+}
+
+// acceptsGzip 判断客户端是否接受gzip编码
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		return strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0"
+	}
+	return false
+}
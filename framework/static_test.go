@@ -0,0 +1,224 @@
+package framework
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func staticTestFS() fstest.MapFS {
+	return fstest.MapFS{
+		"index.html":           {Data: []byte("<h1>index</h1>")},
+		"app.js":               {Data: []byte("console.log('plain')")},
+		"app.js.gz":            {Data: []byte("gzipped")},
+		"style.css":            {Data: []byte("body{}")},
+		"docs/guide/intro.txt": {Data: []byte("intro")},
+		"docs/readme.txt":      {Data: []byte("readme")},
+		"assets/index.html":    {Data: []byte("assets index")},
+		"data":                 {Data: []byte("data")},
+		"data.gz/file.txt":     {Data: []byte("file")},
+	}
+}
+
+func newStaticTestCore(config StaticConfig) *Core {
+	core := NewCore()
+	core.SetMode(TestMode)
+	core.SetLogger(NewLogger(&strings.Builder{}, LevelError, JSONEncoder{}))
+	core.StaticWithConfig("/static", config)
+	return core
+}
+
+func TestStaticCatchAll(t *testing.T) {
+	core := newStaticTestCore(StaticConfig{FS: http.FS(staticTestFS())})
+	tests := []struct {
+		method   string
+		path     string
+		status   int
+		body     string
+		location string
+	}{
+		{method: "GET", path: "/static/app.js", status: http.StatusOK, body: "console.log('plain')"},
+		{method: "GET", path: "/static/docs/guide/intro.txt", status: http.StatusOK, body: "intro"},
+		{method: "GET", path: "/static/docs/guide/../readme.txt", status: http.StatusOK, body: "readme"},
+		{method: "GET", path: "/static/", status: http.StatusOK, body: "<h1>index</h1>"},
+		{method: "GET", path: "/static/assets/", status: http.StatusOK, body: "assets index"},
+		{method: "GET", path: "/static/assets", status: http.StatusMovedPermanently, location: "/static/assets/"},
+		{method: "GET", path: "/static/docs/", status: http.StatusNotFound},
+		{method: "GET", path: "/static/missing.js", status: http.StatusNotFound},
+		{method: "HEAD", path: "/static/style.css", status: http.StatusOK},
+		{method: "POST", path: "/static/app.js", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		core.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.path, rec.Code, tt.status)
+			continue
+		}
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("%s %s: body = %q, want %q", tt.method, tt.path, rec.Body, tt.body)
+		}
+		if tt.location != "" && rec.Header().Get("Location") != tt.location {
+			t.Errorf("%s %s: Location = %q, want %q", tt.method, tt.path, rec.Header().Get("Location"), tt.location)
+		}
+		if tt.method == "HEAD" && rec.Body.Len() != 0 {
+			t.Errorf("HEAD %s: body = %q", tt.path, rec.Body)
+		}
+	}
+}
+
+func TestStaticETag(t *testing.T) {
+	core := newStaticTestCore(StaticConfig{FS: http.FS(staticTestFS()), MaxAge: time.Hour})
+	rec := httptest.NewRecorder()
+	core.ServeHTTP(rec, httptest.NewRequest("GET", "/static/app.js", nil))
+	etag := rec.Header().Get("Etag")
+	if etag == "" {
+		t.Fatal("missing Etag for file without modification time")
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "public, max-age=3600" {
+		t.Errorf("Cache-Control = %q", got)
+	}
+
+	req := httptest.NewRequest("GET", "/static/app.js", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	core.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("If-None-Match status = %d, want 304", rec.Code)
+	}
+}
+
+func TestStaticPrecompressed(t *testing.T) {
+	core := newStaticTestCore(StaticConfig{FS: http.FS(staticTestFS()), Compress: true})
+	tests := []struct {
+		path           string
+		acceptEncoding string
+		body           string
+		encoding       string
+	}{
+		{path: "/static/app.js", acceptEncoding: "gzip, deflate", body: "gzipped", encoding: "gzip"},
+		{path: "/static/app.js", acceptEncoding: "br;q=1.0, GZIP;q=0.5", body: "gzipped", encoding: "gzip"},
+		{path: "/static/app.js", acceptEncoding: "", body: "console.log('plain')"},
+		{path: "/static/app.js", acceptEncoding: "gzip;q=0", body: "console.log('plain')"},
+		{path: "/static/app.js", acceptEncoding: "br", body: "console.log('plain')"},
+		// 没有预压缩文件时返回原文件
+		{path: "/static/style.css", acceptEncoding: "gzip", body: "body{}"},
+		// 同名的.gz是目录时不能当作预压缩文件
+		{path: "/static/data", acceptEncoding: "gzip", body: "data"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		if tt.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		core.ServeHTTP(rec, req)
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("%s (%q): body = %q, want %q", tt.path, tt.acceptEncoding, rec.Body, tt.body)
+		}
+		if got := rec.Header().Get("Content-Encoding"); got != tt.encoding {
+			t.Errorf("%s (%q): Content-Encoding = %q, want %q", tt.path, tt.acceptEncoding, got, tt.encoding)
+		}
+		if tt.encoding != "" && !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/javascript") {
+			t.Errorf("%s (%q): Content-Type = %q, want type of the original file", tt.path, tt.acceptEncoding, rec.Header().Get("Content-Type"))
+		}
+		if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("%s (%q): Vary = %q", tt.path, tt.acceptEncoding, got)
+		}
+	}
+}
+
+func TestStaticSPA(t *testing.T) {
+	tests := []struct {
+		name   string
+		config StaticConfig
+		path   string
+		status int
+		body   string
+	}{
+		{name: "client route", config: StaticConfig{SPA: true}, path: "/static/users/42", status: http.StatusOK, body: "<h1>index</h1>"},
+		{name: "existing file", config: StaticConfig{SPA: true}, path: "/static/app.js", status: http.StatusOK, body: "console.log('plain')"},
+		{name: "existing dir index", config: StaticConfig{SPA: true}, path: "/static/assets/", status: http.StatusOK, body: "assets index"},
+		{name: "custom index", config: StaticConfig{SPA: true, Index: "style.css"}, path: "/static/users/42", status: http.StatusOK, body: "body{}"},
+		{name: "missing index", config: StaticConfig{SPA: true, Index: "app.html"}, path: "/static/users/42", status: http.StatusNotFound},
+		{name: "disabled", config: StaticConfig{}, path: "/static/users/42", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.FS = http.FS(staticTestFS())
+			core := newStaticTestCore(tt.config)
+			rec := httptest.NewRecorder()
+			core.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.body != "" && rec.Body.String() != tt.body {
+				t.Fatalf("body = %q, want %q", rec.Body, tt.body)
+			}
+		})
+	}
+}
+
+func TestStaticBrowse(t *testing.T) {
+	core := newStaticTestCore(StaticConfig{FS: http.FS(staticTestFS()), Browse: true})
+	rec := httptest.NewRecorder()
+	core.ServeHTTP(rec, httptest.NewRequest("GET", "/static/docs/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `<a href="guide/">guide/</a>`) || !strings.Contains(body, `<a href="readme.txt">readme.txt</a>`) {
+		t.Fatalf("listing = %q", body)
+	}
+}
+
+func TestStaticDirAndFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "a.txt"), []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	core := NewCore()
+	core.SetMode(TestMode)
+	core.Static("/files", dir)
+	core.StaticFile("/robots.txt", filepath.Join(dir, "sub", "a.txt"))
+	core.StaticFile("/missing.txt", filepath.Join(dir, "missing.txt"))
+
+	tests := []struct {
+		path   string
+		rng    string
+		status int
+		body   string
+	}{
+		{path: "/files/sub/a.txt", status: http.StatusOK, body: "0123456789"},
+		{path: "/files/sub/a.txt", rng: "bytes=2-4", status: http.StatusPartialContent, body: "234"},
+		{path: "/robots.txt", status: http.StatusOK, body: "0123456789"},
+		{path: "/missing.txt", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		if tt.rng != "" {
+			req.Header.Set("Range", tt.rng)
+		}
+		rec := httptest.NewRecorder()
+		core.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: status = %d, want %d", tt.path, tt.rng, rec.Code, tt.status)
+			continue
+		}
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("%s %s: body = %q, want %q", tt.path, tt.rng, rec.Body, tt.body)
+		}
+		if tt.status == http.StatusOK && !strings.HasPrefix(rec.Header().Get("Etag"), `W/"`) {
+			t.Errorf("%s: Etag = %q, want weak etag", tt.path, rec.Header().Get("Etag"))
+		}
+	}
+}
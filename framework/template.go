@@ -0,0 +1,215 @@
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// HTMLEngine 模版引擎
+// 启动时一次性加载所有模版并缓存，每个页面模版都会和布局、公共模版一起解析
+type HTMLEngine struct {
+	mu sync.RWMutex
+
+	fsys     fs.FS    // 模版所在的文件系统，可以是目录也可以是embed.FS
+	patterns []string // 页面模版的匹配规则
+	layout   string   // 布局模版，设置后所有页面都通过布局渲染
+	partials []string // 公共模版的匹配规则，所有页面都可以引用
+
+	left, right string           // 模版分隔符
+	funcs       template.FuncMap // 自定义模版函数
+	reload      bool             // 每次渲染都重新从文件系统加载，用于开发调试
+
+	templates map[string]*template.Template // 页面名称 => 解析好的模版
+}
+
+// NewHTMLEngine 初始化模版引擎
+func NewHTMLEngine() *HTMLEngine {
+	return &HTMLEngine{funcs: template.FuncMap{}}
+}
+
+// Delims 设置模版分隔符，需要在加载模版前调用
+func (e *HTMLEngine) Delims(left, right string) *HTMLEngine {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.left, e.right = left, right
+	return e
+}
+
+// Funcs 注册自定义模版函数，需要在加载模版前调用
+func (e *HTMLEngine) Funcs(funcs template.FuncMap) *HTMLEngine {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for name, fn := range funcs {
+		e.funcs[name] = fn
+	}
+	return e
+}
+
+// Layout 设置布局模版和公共模版，需要在加载模版前调用
+// 布局中使用 {{block "content" .}}{{end}} 预留位置，页面中使用 {{define "content"}} 填充
+func (e *HTMLEngine) Layout(layout string, partials ...string) *HTMLEngine {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.layout = layout
+	e.partials = partials
+	return e
+}
+
+// Reload 设置是否每次渲染都重新加载模版
+func (e *HTMLEngine) Reload(reload bool) *HTMLEngine {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.reload = reload
+	return e
+}
+
+// Load 从文件系统中加载匹配patterns的页面模版，模版名称为文件相对fsys根目录的路径
+func (e *HTMLEngine) Load(fsys fs.FS, patterns ...string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.fsys = fsys
+	e.patterns = patterns
+	templates, err := e.parse()
+	if err != nil {
+		return err
+	}
+	e.templates = templates
+	return nil
+}
+
+// Loaded 是否已经加载过模版
+func (e *HTMLEngine) Loaded() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.fsys != nil
+}
+
+// parse 解析所有模版，调用方需要持有锁
+func (e *HTMLEngine) parse() (map[string]*template.Template, error) {
+	if e.fsys == nil {
+		return nil, errors.New("html templates not loaded")
+	}
+	base := template.New("").Delims(e.left, e.right).Funcs(e.funcs)
+
+	// 布局和公共模版不作为页面单独渲染
+	shared := map[string]bool{}
+	if e.layout != "" {
+		if err := e.parseFile(base, e.layout); err != nil {
+			return nil, err
+		}
+		shared[e.layout] = true
+	}
+	for _, pattern := range e.partials {
+		matches, err := e.glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range matches {
+			if err := e.parseFile(base, name); err != nil {
+				return nil, err
+			}
+			shared[name] = true
+		}
+	}
+
+	templates := map[string]*template.Template{}
+	for _, pattern := range e.patterns {
+		matches, err := e.glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range matches {
+			if shared[name] || templates[name] != nil {
+				continue
+			}
+			t, err := base.Clone()
+			if err != nil {
+				return nil, err
+			}
+			if err := e.parseFile(t, name); err != nil {
+				return nil, err
+			}
+			templates[name] = t
+		}
+	}
+	return templates, nil
+}
+
+func (e *HTMLEngine) glob(pattern string) ([]string, error) {
+	matches, err := fs.Glob(e.fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("html template pattern %q matches no files", pattern)
+	}
+	return matches, nil
+}
+
+func (e *HTMLEngine) parseFile(t *template.Template, name string) error {
+	content, err := fs.ReadFile(e.fsys, name)
+	if err != nil {
+		return err
+	}
+	_, err = t.New(name).Parse(string(content))
+	return err
+}
+
+// Render 使用模版name渲染data
+func (e *HTMLEngine) Render(w io.Writer, name string, data interface{}) error {
+	e.mu.RLock()
+	templates, layout := e.templates, e.layout
+	var err error
+	if e.reload {
+		templates, err = e.parse()
+	}
+	e.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	t, ok := templates[name]
+	if !ok {
+		return fmt.Errorf("html template %q not found", name)
+	}
+	if layout != "" {
+		return t.ExecuteTemplate(w, layout, data)
+	}
+	return t.ExecuteTemplate(w, name, data)
+}
+
+// Delims 设置模版分隔符
+func (c *Core) Delims(left, right string) *Core {
+	c.html.Delims(left, right)
+	return c
+}
+
+// SetFuncMap 注册自定义模版函数
+func (c *Core) SetFuncMap(funcs template.FuncMap) {
+	c.html.Funcs(funcs)
+}
+
+// SetHTMLLayout 设置布局模版和公共模版
+func (c *Core) SetHTMLLayout(layout string, partials ...string) {
+	c.html.Layout(layout, partials...)
+}
+
+// SetHTMLReload 设置是否每次渲染都从磁盘重新加载模版，debug模式下默认重新加载
+func (c *Core) SetHTMLReload(reload bool) {
+	c.html.Reload(reload)
+}
+
+// LoadHTMLDir 加载目录dir下匹配patterns的模版
+func (c *Core) LoadHTMLDir(dir string, patterns ...string) error {
+	return c.html.Load(os.DirFS(dir), patterns...)
+}
+
+// LoadHTMLFS 加载文件系统中匹配patterns的模版，支持embed.FS
+func (c *Core) LoadHTMLFS(fsys fs.FS, patterns ...string) error {
+	return c.html.Load(fsys, patterns...)
+}
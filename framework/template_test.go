@@ -0,0 +1,142 @@
+package framework
+
+import (
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestHTMLEngineLayout(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layout.html":         {Data: []byte(`<html>[[template "nav" .]][[block "content" .]]default[[end]]</html>`)},
+		"partials/nav.html":   {Data: []byte(`[[define "nav"]]<nav>[[upper .Title]]</nav>[[end]]`)},
+		"pages/index.html":    {Data: []byte(`[[define "content"]]<p>[[.Body]]</p>[[end]]`)},
+		"pages/empty.html":    {Data: []byte(`[[define "other"]][[end]]`)},
+		"pages/escape.html":   {Data: []byte(`[[define "content"]][[.Body]][[end]]`)},
+		"pages/not-page.txt":  {Data: []byte(`ignored`)},
+		"partials/footer.txt": {Data: []byte(`ignored`)},
+	}
+	e := NewHTMLEngine().
+		Delims("[[", "]]").
+		Funcs(template.FuncMap{"upper": strings.ToUpper}).
+		Layout("layout.html", "partials/*.html")
+	if err := e.Load(fsys, "pages/*.html", "*.html"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		data    map[string]string
+		want    string
+		wantErr bool
+	}{
+		{name: "pages/index.html", data: map[string]string{"Title": "home", "Body": "hello"}, want: "<html><nav>HOME</nav><p>hello</p></html>"},
+		{name: "pages/empty.html", data: map[string]string{"Title": "t"}, want: "<html><nav>T</nav>default</html>"},
+		{name: "pages/escape.html", data: map[string]string{"Title": "x", "Body": "<b>"}, want: "<html><nav>X</nav>&lt;b&gt;</html>"},
+		// 布局和公共模版不能作为页面渲染
+		{name: "layout.html", wantErr: true},
+		{name: "partials/nav.html", wantErr: true},
+		{name: "pages/missing.html", wantErr: true},
+	}
+	for _, tt := range tests {
+		var buf strings.Builder
+		err := e.Render(&buf, tt.name, tt.data)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Render(%q) = %q, want error", tt.name, buf.String())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Render(%q): %v", tt.name, err)
+			continue
+		}
+		if buf.String() != tt.want {
+			t.Errorf("Render(%q) = %q, want %q", tt.name, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestHTMLEngineLoadError(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":  {Data: []byte(`{{.}}`)},
+		"broken.html": {Data: []byte(`{{if}}`)},
+	}
+	tests := []struct {
+		name     string
+		patterns []string
+	}{
+		{name: "no match", patterns: []string{"*.tmpl"}},
+		{name: "bad pattern", patterns: []string{"["}},
+		{name: "parse error", patterns: []string{"*.html"}},
+	}
+	for _, tt := range tests {
+		if err := NewHTMLEngine().Load(fsys, tt.patterns...); err == nil {
+			t.Errorf("%s: Load(%q) succeeded", tt.name, tt.patterns)
+		}
+	}
+
+	var buf strings.Builder
+	if err := NewHTMLEngine().Render(&buf, "index.html", nil); err == nil {
+		t.Error("Render before Load succeeded")
+	}
+}
+
+func TestHTMLEngineReload(t *testing.T) {
+	dir := t.TempDir()
+	page := filepath.Join(dir, "index.html")
+	for _, reload := range []bool{false, true} {
+		if err := os.WriteFile(page, []byte(`v1 {{.}}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		core := NewCore()
+		core.SetHTMLReload(reload)
+		if err := core.LoadHTMLDir(dir, "*.html"); err != nil {
+			t.Fatal(err)
+		}
+		render := func() string {
+			var buf strings.Builder
+			if err := core.html.Render(&buf, "index.html", "axis"); err != nil {
+				t.Fatalf("reload=%v: %v", reload, err)
+			}
+			return buf.String()
+		}
+		if got := render(); got != "v1 axis" {
+			t.Fatalf("reload=%v: first render = %q", reload, got)
+		}
+
+		if err := os.WriteFile(page, []byte(`v2 {{.}}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		want := "v1 axis"
+		if reload {
+			want = "v2 axis"
+		}
+		if got := render(); got != want {
+			t.Errorf("reload=%v: render after edit = %q, want %q", reload, got, want)
+		}
+	}
+}
+
+func TestHTMLEngineReloadError(t *testing.T) {
+	dir := t.TempDir()
+	page := filepath.Join(dir, "index.html")
+	if err := os.WriteFile(page, []byte(`ok`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e := NewHTMLEngine().Reload(true)
+	if err := e.Load(os.DirFS(dir), "*.html"); err != nil {
+		t.Fatal(err)
+	}
+	// 开发时改坏了模版，渲染返回解析错误而不是旧的结果
+	if err := os.WriteFile(page, []byte(`{{if}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	if err := e.Render(&buf, "index.html", nil); err == nil {
+		t.Fatalf("Render = %q, want parse error", buf.String())
+	}
+}
@@ -2,8 +2,6 @@ package framework
 
 import (
 	"context"
-	"fmt"
-	"log"
 	"time"
 )
 
@@ -34,9 +32,9 @@ func TimeoutHandler(function ControllerHandler, d time.Duration) ControllerHandl
 		case p := <-panicChan: //异常情况，也会在业务出现异常的时候，通过 panicChan 来传递异常信号。
 			c.WriterMux().Lock()
 			defer c.WriterMux().Unlock()
-			log.Println(p)
+			c.Logger().Error("handler panic", "panic", p)
 		case <-finish: //业务结束
-			fmt.Println("finish")
+			c.Debugf("finish")
 		case <-durationCtx.Done(): //超时
 			c.WriterMux().Lock()
 			defer c.WriterMux().Unlock()
@@ -0,0 +1,133 @@
+package framework
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HeaderServerTiming 返回处理阶段耗时的响应头，浏览器开发者工具会展示其中的耗时
+const HeaderServerTiming = "Server-Timing"
+
+// TimingMetric 一个处理阶段的耗时，同名的阶段会累加
+type TimingMetric struct {
+	Name        string
+	Description string
+	Duration    time.Duration
+	Count       int // 记录的次数
+}
+
+// Timer 正在计时的处理阶段，由Context.Timing创建
+type Timer struct {
+	ctx     *Context
+	name    string
+	desc    string
+	start   time.Time
+	stopped bool
+}
+
+// Timing 开始记录名为name的处理阶段，调用Stop结束，例如 defer c.Timing("db").Stop()
+// 可以在Go启动的goroutine中使用，Server-Timing头只包含写入响应之前结束的阶段
+func (ctx *Context) Timing(name string) *Timer {
+	return &Timer{ctx: ctx, name: name, start: time.Now()}
+}
+
+// Describe 设置阶段的描述，在开发者工具中代替名称显示
+func (t *Timer) Describe(desc string) *Timer {
+	t.desc = desc
+	return t
+}
+
+// Stop 结束计时并记录，返回耗时，多次调用只记录一次
+func (t *Timer) Stop() time.Duration {
+	d := time.Since(t.start)
+	t.ctx.timingMu.Lock()
+	stopped := t.stopped
+	t.stopped = true
+	t.ctx.timingMu.Unlock()
+	if stopped {
+		return 0
+	}
+	t.ctx.AddTiming(t.name, d, t.desc)
+	return d
+}
+
+// AddTiming 直接记录一个阶段的耗时，例如从下游响应中得到的耗时
+func (ctx *Context) AddTiming(name string, d time.Duration, desc string) {
+	ctx.timingMu.Lock()
+	defer ctx.timingMu.Unlock()
+	for i := range ctx.timings {
+		if ctx.timings[i].Name == name {
+			ctx.timings[i].Duration += d
+			ctx.timings[i].Count++
+			if ctx.timings[i].Description == "" {
+				ctx.timings[i].Description = desc
+			}
+			return
+		}
+	}
+	ctx.timings = append(ctx.timings, TimingMetric{Name: name, Description: desc, Duration: d, Count: 1})
+}
+
+// Timings 已经记录的阶段，按第一次记录的顺序
+func (ctx *Context) Timings() []TimingMetric {
+	ctx.timingMu.Lock()
+	defer ctx.timingMu.Unlock()
+	if len(ctx.timings) == 0 {
+		return nil
+	}
+	timings := make([]TimingMetric, len(ctx.timings))
+	copy(timings, ctx.timings)
+	return timings
+}
+
+// FormatServerTiming 编码为Server-Timing头，例如 db;dur=12.5;desc="query users"
+// 耗时的单位为毫秒，名称中不合法的字符替换为下划线
+func FormatServerTiming(timings []TimingMetric) string {
+	var b strings.Builder
+	for i, t := range timings {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(timingToken(t.Name))
+		b.WriteString(";dur=")
+		b.WriteString(strconv.FormatFloat(float64(t.Duration.Microseconds())/1000, 'f', -1, 64))
+		if t.Description != "" {
+			b.WriteString(`;desc="`)
+			for _, r := range t.Description {
+				switch {
+				case r == '"' || r == '\\':
+					b.WriteByte('\\')
+					b.WriteRune(r)
+				case r < 0x20 || r == 0x7f:
+					b.WriteByte(' ')
+				default:
+					b.WriteRune(r)
+				}
+			}
+			b.WriteByte('"')
+		}
+	}
+	return b.String()
+}
+
+// timingToken 把名称转换为http的token
+func timingToken(name string) string {
+	if name == "" {
+		return "_"
+	}
+	b := []byte(name)
+	for i, c := range b {
+		if !isTokenChar(c) {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+func isTokenChar(c byte) bool {
+	if c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
@@ -0,0 +1,305 @@
+package framework
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeCertFiles 把证书和私钥以pem格式写入文件
+func writeCertFiles(t *testing.T, cert tls.Certificate, certFile string, keyFile string) {
+	t.Helper()
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key})
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testCA 测试用的CA，签发客户端证书
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// clientCert 签发客户端证书
+func (ca *testCA) clientCert(t *testing.T, template *x509.Certificate) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(42)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	first, err := SelfSignedCertificate("first.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeCertFiles(t, first, certFile, keyFile)
+
+	r, err := NewCertReloader(certFile, keyFile, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	commonName := func() string {
+		cert, _ := r.GetCertificate(nil)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.Subject.CommonName
+	}
+	if cn := commonName(); cn != "first.test" {
+		t.Fatalf("initial certificate = %s", cn)
+	}
+
+	second, err := SelfSignedCertificate("second.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeCertFiles(t, second, certFile, keyFile)
+	// 文件系统的时间精度可能比写入间隔粗，明确设置一个不同的修改时间
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(certFile, later, later)
+	deadline := time.Now().Add(5 * time.Second)
+	for commonName() != "second.test" {
+		if time.Now().After(deadline) {
+			t.Fatal("certificate not reloaded after files changed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 加载失败时继续使用原来的证书
+	if err := os.WriteFile(keyFile, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Fatal("Reload with invalid key succeeded")
+	}
+	if cn := commonName(); cn != "second.test" {
+		t.Fatalf("certificate after failed reload = %s", cn)
+	}
+
+	if _, err := NewCertReloader(filepath.Join(dir, "missing.crt"), keyFile, 0); err == nil {
+		t.Fatal("NewCertReloader with missing file succeeded")
+	}
+}
+
+func TestListenerSpecTLSConfig(t *testing.T) {
+	ca := newTestCA(t, "client ca")
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, _, err := ListenerSpec{Addr: ":0"}.tlsConfig()
+	if err != nil || config != nil {
+		t.Fatalf("plain listener tls config = %v, %v", config, err)
+	}
+
+	tests := []struct {
+		name       string
+		spec       ListenerSpec
+		clientAuth tls.ClientAuthType
+		withCAs    bool
+	}{
+		{name: "no client ca", spec: ListenerSpec{SelfSigned: true}, clientAuth: tls.NoClientCert},
+		{name: "ca file verifies if given", spec: ListenerSpec{SelfSigned: true, ClientCAFile: caFile}, clientAuth: tls.VerifyClientCertIfGiven, withCAs: true},
+		{name: "ca pool", spec: ListenerSpec{SelfSigned: true, ClientCAs: ca.pool}, clientAuth: tls.VerifyClientCertIfGiven, withCAs: true},
+		{name: "explicit client auth", spec: ListenerSpec{SelfSigned: true, ClientCAs: ca.pool, ClientAuth: tls.RequireAndVerifyClientCert}, clientAuth: tls.RequireAndVerifyClientCert, withCAs: true},
+		{name: "tls config client auth", spec: ListenerSpec{TLSConfig: &tls.Config{ClientAuth: tls.RequestClientCert}, SelfSigned: true, ClientCAs: ca.pool}, clientAuth: tls.RequestClientCert, withCAs: true},
+	}
+	for _, tt := range tests {
+		config, reloader, err := tt.spec.tlsConfig()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if reloader != nil {
+			t.Errorf("%s: unexpected reloader", tt.name)
+		}
+		if config.ClientAuth != tt.clientAuth {
+			t.Errorf("%s: ClientAuth = %v, want %v", tt.name, config.ClientAuth, tt.clientAuth)
+		}
+		if (config.ClientCAs != nil) != tt.withCAs {
+			t.Errorf("%s: ClientCAs = %v", tt.name, config.ClientCAs)
+		}
+		if len(config.Certificates) != 1 {
+			t.Errorf("%s: %d certificates, want self-signed", tt.name, len(config.Certificates))
+		}
+		if strings.Join(config.NextProtos, ",") != "h2,http/1.1" {
+			t.Errorf("%s: NextProtos = %v", tt.name, config.NextProtos)
+		}
+	}
+
+	if _, _, err := (ListenerSpec{SelfSigned: true, ClientCAFile: filepath.Join(t.TempDir(), "missing.pem")}).tlsConfig(); err == nil {
+		t.Fatal("missing client ca file accepted")
+	}
+	// 传入的TLSConfig不能被修改
+	shared := &tls.Config{}
+	if _, _, err := (ListenerSpec{TLSConfig: shared, SelfSigned: true, ClientCAs: ca.pool}).tlsConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if shared.ClientCAs != nil || len(shared.Certificates) != 0 {
+		t.Fatal("spec TLSConfig modified")
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newTestCA(t, "client ca")
+	spiffe, _ := url.Parse("spiffe://axis.test/billing")
+	clientCert := ca.clientCert(t, &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "billing", Organization: []string{"axis"}},
+		DNSNames:       []string{"billing.internal"},
+		EmailAddresses: []string{"ops@axis.test"},
+		URIs:           []*url.URL{spiffe},
+	})
+	otherCert := newTestCA(t, "other ca").clientCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "intruder"}})
+
+	core := NewCore()
+	core.SetMode(TestMode)
+	core.Get("/whoami", func(c *Context) error {
+		id, ok := c.PeerIdentity()
+		if !ok {
+			c.Text("anonymous")
+			return nil
+		}
+		c.Text("%s|%s|%s|%s|%s|%s", id.CommonName(), id.Issuer.CommonName, id.SerialNumber,
+			strings.Join(id.DNSNames, ","), strings.Join(id.EmailAddresses, ","), id.URIs[0])
+		return nil
+	})
+
+	serve := func(spec ListenerSpec) string {
+		t.Helper()
+		config, _, err := spec.tlsConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv := &http.Server{Handler: core, ErrorLog: log.New(io.Discard, "", 0)}
+		go srv.Serve(tls.NewListener(ln, config))
+		t.Cleanup(func() { srv.Close() })
+		return "https://" + ln.Addr().String() + "/whoami"
+	}
+	get := func(url string, certs ...tls.Certificate) (string, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			// 客户端默认只发送服务端CA签发的证书，这里总是发送，验证服务端会拒绝
+			GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				if len(certs) == 0 {
+					return &tls.Certificate{}, nil
+				}
+				return &certs[0], nil
+			},
+		}}}
+		defer client.CloseIdleConnections()
+		resp, err := client.Get(url)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	required := serve(ListenerSpec{SelfSigned: true, ClientCAs: ca.pool, ClientAuth: tls.RequireAndVerifyClientCert})
+	body, err := get(required, clientCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "billing|client ca|42|billing.internal|ops@axis.test|spiffe://axis.test/billing"
+	if body != want {
+		t.Fatalf("identity = %q, want %q", body, want)
+	}
+	if _, err := get(required); err == nil {
+		t.Fatal("request without client certificate accepted")
+	}
+	if _, err := get(required, otherCert); err == nil {
+		t.Fatal("client certificate from unknown ca accepted")
+	}
+
+	// 只设置CA时客户端可以不提供证书
+	optional := serve(ListenerSpec{SelfSigned: true, ClientCAs: ca.pool})
+	if body, err := get(optional); err != nil || body != "anonymous" {
+		t.Fatalf("optional without certificate = %q, %v", body, err)
+	}
+	if body, err := get(optional, clientCert); err != nil || body != want {
+		t.Fatalf("optional with certificate = %q, %v", body, err)
+	}
+	if _, err := get(optional, otherCert); err == nil {
+		t.Fatal("optional accepted certificate from unknown ca")
+	}
+
+	// 不验证的证书不会作为身份
+	requested := serve(ListenerSpec{SelfSigned: true, ClientAuth: tls.RequestClientCert})
+	if body, err := get(requested, otherCert); err != nil || body != "anonymous" {
+		t.Fatalf("unverified certificate = %q, %v", body, err)
+	}
+}
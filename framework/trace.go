@@ -0,0 +1,473 @@
+package framework
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// W3C Trace Context的请求头
+const (
+	HeaderTraceparent = "traceparent"
+	HeaderTracestate  = "tracestate"
+)
+
+// ErrTraceparent traceparent请求头格式错误
+var ErrTraceparent = errors.New("invalid traceparent")
+
+// TraceID 16字节的trace id
+type TraceID [16]byte
+
+// IsValid 全为0时无效
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// SpanID 8字节的span id
+type SpanID [8]byte
+
+// IsValid 全为0时无效
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// SpanContext 在服务之间传递的span信息
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool   // 是否采样，对应trace-flags的最低位
+	TraceState string // tracestate请求头，原样传递
+	Remote     bool   // 是否从请求头中解析得到
+}
+
+// IsValid trace id和span id都有效
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent 编码为traceparent请求头，例如 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent 解析traceparent请求头
+// 高于00的版本只解析前四个字段，版本ff和全为0的id无效
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+	s = strings.TrimSpace(s)
+	if len(s) < 55 || s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return sc, ErrTraceparent
+	}
+	version, ok := decodeLowerHex(s[0:2])
+	if !ok || version[0] == 0xff {
+		return sc, ErrTraceparent
+	}
+	if version[0] == 0 && len(s) != 55 {
+		return sc, ErrTraceparent
+	}
+	if version[0] > 0 && len(s) > 55 && s[55] != '-' {
+		return sc, ErrTraceparent
+	}
+	traceID, ok1 := decodeLowerHex(s[3:35])
+	spanID, ok2 := decodeLowerHex(s[36:52])
+	flags, ok3 := decodeLowerHex(s[53:55])
+	if !ok1 || !ok2 || !ok3 {
+		return sc, ErrTraceparent
+	}
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	if !sc.IsValid() {
+		return sc, ErrTraceparent
+	}
+	sc.Sampled = flags[0]&0x01 == 1
+	sc.Remote = true
+	return sc, nil
+}
+
+// decodeLowerHex 规范要求只能是小写的十六进制
+func decodeLowerHex(s string) ([]byte, bool) {
+	for i := 0; i < len(s); i++ {
+		if !('0' <= s[i] && s[i] <= '9' || 'a' <= s[i] && s[i] <= 'f') {
+			return nil, false
+		}
+	}
+	b, err := hex.DecodeString(s)
+	return b, err == nil
+}
+
+// validTraceState tracestate最多32个key=value，超过时整个丢弃
+func validTraceState(s string) bool {
+	if len(s) > 512 {
+		return false
+	}
+	members := 0
+	for _, member := range strings.Split(s, ",") {
+		member = strings.TrimSpace(member)
+		if member == "" {
+			continue
+		}
+		if key, value, found := strings.Cut(member, "="); !found || key == "" || value == "" {
+			return false
+		}
+		members++
+	}
+	return members <= 32
+}
+
+// ExtractTraceContext 从请求头中解析traceparent和tracestate
+func ExtractTraceContext(header http.Header) (SpanContext, bool) {
+	sc, err := ParseTraceparent(header.Get(HeaderTraceparent))
+	if err != nil {
+		return sc, false
+	}
+	state := strings.Join(header.Values(HeaderTracestate), ",")
+	if validTraceState(state) {
+		sc.TraceState = state
+	}
+	return sc, true
+}
+
+// InjectTraceContext 把req的context中的span设置到traceparent和tracestate请求头，用于调用其他服务
+func InjectTraceContext(req *http.Request) {
+	span := SpanFromContext(req.Context())
+	if span == nil {
+		return
+	}
+	sc := span.SpanContext()
+	req.Header.Set(HeaderTraceparent, sc.Traceparent())
+	if sc.TraceState != "" {
+		req.Header.Set(HeaderTracestate, sc.TraceState)
+	} else {
+		req.Header.Del(HeaderTracestate)
+	}
+}
+
+// SpanKind span的类型，取值和OTLP一致
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = iota + 1
+	SpanKindServer
+	SpanKindClient
+	SpanKindProducer
+	SpanKindConsumer
+)
+
+// SpanStatus span的状态，取值和OTLP一致
+type SpanStatus int
+
+const (
+	SpanStatusUnset SpanStatus = iota
+	SpanStatusOK
+	SpanStatusError
+)
+
+// SpanEvent span中的事件，例如错误
+type SpanEvent struct {
+	Name       string
+	Time       time.Time
+	Attributes []Field
+}
+
+// SpanData 结束后的span，交给exporter输出
+type SpanData struct {
+	Name          string
+	SpanContext   SpanContext
+	Parent        SpanID // 没有父span时无效
+	Kind          SpanKind
+	Start         time.Time
+	End           time.Time
+	Attributes    []Field
+	Events        []SpanEvent
+	Status        SpanStatus
+	StatusMessage string
+}
+
+// SpanExporter 输出结束的span，ExportSpans在End中同步调用，耗时的输出需要自己缓冲
+type SpanExporter interface {
+	ExportSpans(spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+// Sampler 决定没有父span的trace是否采样，有父span时沿用父span的采样标记
+type Sampler func(traceID TraceID) bool
+
+// AlwaysSample 全部采样
+func AlwaysSample(TraceID) bool { return true }
+
+// RatioSampler 按比例采样，根据trace id计算，同一个trace的结果总是相同
+func RatioSampler(ratio float64) Sampler {
+	if ratio >= 1 {
+		return AlwaysSample
+	}
+	bound := uint64(ratio * (1 << 63))
+	return func(traceID TraceID) bool {
+		return binary.BigEndian.Uint64(traceID[8:])>>1 < bound
+	}
+}
+
+// Tracer 创建span，采样的span结束时交给exporter
+type Tracer struct {
+	exporter SpanExporter
+	sampler  Sampler
+}
+
+// NewTracer 初始化Tracer，sampler为空时全部采样
+func NewTracer(exporter SpanExporter, sampler Sampler) *Tracer {
+	if sampler == nil {
+		sampler = AlwaysSample
+	}
+	return &Tracer{exporter: exporter, sampler: sampler}
+}
+
+// Start 创建span，parent中有span时作为子span，否则使用ContextWithRemoteSpanContext设置的远程span
+// 返回的context带有新的span
+func (t *Tracer) Start(parent context.Context, name string, kind SpanKind, attrs ...interface{}) (context.Context, *Span) {
+	span := &Span{tracer: t}
+	span.data.Name = name
+	span.data.Kind = kind
+	span.data.Start = time.Now()
+	span.data.Attributes = toFields(attrs)
+
+	var psc SpanContext
+	if p := SpanFromContext(parent); p != nil {
+		psc = p.SpanContext()
+	} else if remote, ok := parent.Value(remoteSpanKey{}).(SpanContext); ok {
+		psc = remote
+	}
+	sc := SpanContext{SpanID: newSpanID()}
+	if psc.IsValid() {
+		sc.TraceID = psc.TraceID
+		sc.Sampled = psc.Sampled
+		sc.TraceState = psc.TraceState
+		span.data.Parent = psc.SpanID
+	} else {
+		sc.TraceID = newTraceID()
+		sc.Sampled = t.sampler(sc.TraceID)
+	}
+	span.data.SpanContext = sc
+	return context.WithValue(parent, spanKey{}, span), span
+}
+
+// Shutdown 关闭exporter
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t.exporter == nil {
+		return nil
+	}
+	return t.exporter.Shutdown(ctx)
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(id[8:], rand.Uint64())
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:], rand.Uint64())
+	}
+	return id
+}
+
+type spanKey struct{}
+
+type remoteSpanKey struct{}
+
+// SpanFromContext 获取context中的span，没有时返回nil
+func SpanFromContext(c context.Context) *Span {
+	span, _ := c.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithRemoteSpanContext 设置从请求头中解析的父span，Tracer.Start时使用
+func ContextWithRemoteSpanContext(parent context.Context, sc SpanContext) context.Context {
+	return context.WithValue(parent, remoteSpanKey{}, sc)
+}
+
+// StartSpan 使用parent中的span所属的Tracer创建子span，parent中没有span时返回nil
+// nil的Span可以正常调用所有方法，没有开启trace时业务代码不需要判断
+func StartSpan(parent context.Context, name string, attrs ...interface{}) (context.Context, *Span) {
+	p := SpanFromContext(parent)
+	if p == nil {
+		return parent, nil
+	}
+	return p.tracer.Start(parent, name, SpanKindInternal, attrs...)
+}
+
+// Span 一次操作的耗时记录，方法可以并发调用，nil的Span所有方法都不做任何事
+type Span struct {
+	tracer *Tracer
+	mu     sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+// SpanContext span的id信息
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.SpanContext
+}
+
+// IsRecording span是否会被输出，没有采样或者已经结束时为false
+func (s *Span) IsRecording() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.SpanContext.Sampled && !s.ended
+}
+
+// SetName 修改span的名称
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.data.Name = name
+	}
+}
+
+// SetAttributes 设置属性，参数为交替出现的key和value
+func (s *Span) SetAttributes(attrs ...interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.data.Attributes = append(s.data.Attributes, toFields(attrs)...)
+	}
+}
+
+// AddEvent 记录事件
+func (s *Span) AddEvent(name string, attrs ...interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.data.Events = append(s.data.Events, SpanEvent{Name: name, Time: time.Now(), Attributes: toFields(attrs)})
+	}
+}
+
+// RecordError 记录错误事件并把状态设置为Error
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.AddEvent("exception", "exception.message", err.Error())
+	s.SetStatus(SpanStatusError, err.Error())
+}
+
+// SetStatus 设置状态，message只在Error状态时保留
+func (s *Span) SetStatus(status SpanStatus, message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	s.data.Status = status
+	if status != SpanStatusError {
+		message = ""
+	}
+	s.data.StatusMessage = message
+}
+
+// End 结束span，采样的span交给exporter，重复调用只有第一次生效
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	if !data.SpanContext.Sampled || s.tracer.exporter == nil {
+		return
+	}
+	if err := s.tracer.exporter.ExportSpans([]SpanData{data}); err != nil {
+		DefaultLogger().Warn("export span failed", "span", data.Name, "error", err)
+	}
+}
+
+// Span 当前请求的span，没有开启trace时返回nil
+func (ctx *Context) Span() *Span {
+	return SpanFromContext(ctx.BaseContext())
+}
+
+// StartSpan 创建当前请求的子span，返回的context用于创建更深的子span或者传给下游调用
+func (ctx *Context) StartSpan(name string, attrs ...interface{}) (context.Context, *Span) {
+	return StartSpan(ctx.BaseContext(), name, attrs...)
+}
+
+// TraceTransport 发出请求时创建client类型的span，并把trace信息写入请求头
+// 例如 client := &http.Client{Transport: &TraceTransport{}}
+type TraceTransport struct {
+	Base http.RoundTripper // 为空时使用http.DefaultTransport
+}
+
+func (t *TraceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	parent := SpanFromContext(req.Context())
+	if parent == nil {
+		return base.RoundTrip(req)
+	}
+	ctx, span := parent.tracer.Start(req.Context(), "HTTP "+req.Method, SpanKindClient,
+		"http.request.method", req.Method, "url.full", req.URL.Redacted(), "server.address", req.URL.Host)
+	defer span.End()
+
+	// RoundTripper不能修改传入的请求
+	req = req.Clone(ctx)
+	InjectTraceContext(req)
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	span.SetAttributes("http.response.status_code", resp.StatusCode)
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(SpanStatusError, resp.Status)
+	}
+	return resp, nil
+}
@@ -0,0 +1,211 @@
+package framework
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// OTLPJSONExporter 把span编码为OTLP/JSON格式，每批span输出一行
+// 输出可以用otel-collector的otlpjsonfile receiver读取，适合本地调试和没有collector的环境
+type OTLPJSONExporter struct {
+	mu       sync.Mutex
+	w        io.Writer
+	closer   io.Closer // 由exporter打开的文件，Shutdown时关闭
+	resource []otlpKeyValue
+}
+
+// NewOTLPJSONExporter 输出到w，例如os.Stdout或者RotateWriter，serviceName作为resource的service.name
+func NewOTLPJSONExporter(w io.Writer, serviceName string) *OTLPJSONExporter {
+	return &OTLPJSONExporter{
+		w:        w,
+		resource: otlpAttributes([]Field{{Key: "service.name", Value: serviceName}}),
+	}
+}
+
+// NewOTLPJSONFileExporter 追加写入文件，Shutdown时关闭文件
+func NewOTLPJSONFileExporter(filename string, serviceName string) (*OTLPJSONExporter, error) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	e := NewOTLPJSONExporter(f, serviceName)
+	e.closer = f
+	return e, nil
+}
+
+func (e *OTLPJSONExporter) ExportSpans(spans []SpanData) error {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		out = append(out, toOTLPSpan(span))
+	}
+	body := otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: e.resource},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/iceymoss/axis/framework"},
+			Spans: out,
+		}},
+	}}}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.w == nil {
+		return os.ErrClosed
+	}
+	_, err = e.w.Write(b)
+	return err
+}
+
+// Shutdown 关闭由exporter打开的文件，之后的ExportSpans返回错误
+func (e *OTLPJSONExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.w = nil
+	if e.closer == nil {
+		return nil
+	}
+	err := e.closer.Close()
+	e.closer = nil
+	return err
+}
+
+// 以下为OTLP/JSON的结构，id使用十六进制，时间使用字符串表示的纳秒
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	TraceState        string         `json:"traceState,omitempty"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Flags             uint32         `json:"flags,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    SpanStatus `json:"code,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"` // int64在json中使用字符串
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func toOTLPSpan(span SpanData) otlpSpan {
+	out := otlpSpan{
+		TraceID:           span.SpanContext.TraceID.String(),
+		SpanID:            span.SpanContext.SpanID.String(),
+		TraceState:        span.SpanContext.TraceState,
+		Name:              span.Name,
+		Kind:              span.Kind,
+		StartTimeUnixNano: unixNano(span.Start),
+		EndTimeUnixNano:   unixNano(span.End),
+		Attributes:        otlpAttributes(span.Attributes),
+		Status:            otlpStatus{Code: span.Status, Message: span.StatusMessage},
+	}
+	if span.Parent.IsValid() {
+		out.ParentSpanID = span.Parent.String()
+	}
+	if span.SpanContext.Sampled {
+		out.Flags = 1
+	}
+	for _, event := range span.Events {
+		out.Events = append(out.Events, otlpEvent{
+			TimeUnixNano: unixNano(event.Time),
+			Name:         event.Name,
+			Attributes:   otlpAttributes(event.Attributes),
+		})
+	}
+	return out
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func otlpAttributes(fields []Field) []otlpKeyValue {
+	if len(fields) == 0 {
+		return nil
+	}
+	out := make([]otlpKeyValue, 0, len(fields))
+	for _, field := range fields {
+		out = append(out, otlpKeyValue{Key: field.Key, Value: otlpValue(field.Value)})
+	}
+	return out
+}
+
+// otlpValue 整数、浮点数和bool保留类型，其他值使用字符串
+func otlpValue(v interface{}) otlpAnyValue {
+	var value otlpAnyValue
+	switch x := fieldValue(v).(type) {
+	case bool:
+		value.BoolValue = &x
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		s := fmt.Sprint(x)
+		value.IntValue = &s
+	case float32:
+		f := float64(x)
+		value.DoubleValue = &f
+	case float64:
+		value.DoubleValue = &x
+	case string:
+		value.StringValue = &x
+	default:
+		s := fmt.Sprint(x)
+		value.StringValue = &s
+	}
+	return value
+}
@@ -0,0 +1,100 @@
+package framework
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+	tests := []struct {
+		name        string
+		in          string
+		wantErr     bool
+		wantSampled bool
+	}{
+		{name: "sampled", in: "00-" + traceID + "-" + spanID + "-01", wantSampled: true},
+		{name: "not sampled", in: "00-" + traceID + "-" + spanID + "-00"},
+		{name: "other flags", in: "00-" + traceID + "-" + spanID + "-09", wantSampled: true},
+		{name: "surrounding spaces", in: "  00-" + traceID + "-" + spanID + "-01 ", wantSampled: true},
+		{name: "future version", in: "01-" + traceID + "-" + spanID + "-01", wantSampled: true},
+		{name: "future version extra fields", in: "cc-" + traceID + "-" + spanID + "-01-what-the-future-will-be-like", wantSampled: true},
+		{name: "future version extra field empty", in: "01-" + traceID + "-" + spanID + "-00-"},
+		{name: "future version no separator", in: "01-" + traceID + "-" + spanID + "-01x", wantErr: true},
+		{name: "version 00 extra fields", in: "00-" + traceID + "-" + spanID + "-01-extra", wantErr: true},
+		{name: "version ff", in: "ff-" + traceID + "-" + spanID + "-01", wantErr: true},
+		{name: "uppercase version", in: "0A-" + traceID + "-" + spanID + "-01", wantErr: true},
+		{name: "uppercase trace id", in: "00-" + strings.ToUpper(traceID) + "-" + spanID + "-01", wantErr: true},
+		{name: "uppercase span id", in: "00-" + traceID + "-" + "00F067AA0BA902B7" + "-01", wantErr: true},
+		{name: "uppercase flags", in: "00-" + traceID + "-" + spanID + "-0A", wantErr: true},
+		{name: "zero trace id", in: "00-" + strings.Repeat("0", 32) + "-" + spanID + "-01", wantErr: true},
+		{name: "zero span id", in: "00-" + traceID + "-" + strings.Repeat("0", 16) + "-01", wantErr: true},
+		{name: "short trace id", in: "00-" + traceID[1:] + "-" + spanID + "-01", wantErr: true},
+		{name: "long span id", in: "00-" + traceID + "-" + spanID + "0-01", wantErr: true},
+		{name: "short flags", in: "00-" + traceID + "-" + spanID + "-1", wantErr: true},
+		{name: "non hex", in: "00-" + traceID[:31] + "g-" + spanID + "-01", wantErr: true},
+		{name: "wrong separator", in: "00_" + traceID + "-" + spanID + "-01", wantErr: true},
+		{name: "empty", in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := ParseTraceparent(tt.in)
+			if tt.wantErr {
+				if err != ErrTraceparent {
+					t.Fatalf("ParseTraceparent(%q) err = %v, want ErrTraceparent", tt.in, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTraceparent(%q): %v", tt.in, err)
+			}
+			if sc.TraceID.String() != traceID || sc.SpanID.String() != spanID || sc.Sampled != tt.wantSampled || !sc.Remote {
+				t.Fatalf("ParseTraceparent(%q) = %+v", tt.in, sc)
+			}
+		})
+	}
+}
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	for _, sampled := range []bool{true, false} {
+		sc := SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: sampled}
+		got, err := ParseTraceparent(sc.Traceparent())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.TraceID != sc.TraceID || got.SpanID != sc.SpanID || got.Sampled != sampled {
+			t.Fatalf("round trip %q = %+v", sc.Traceparent(), got)
+		}
+	}
+}
+
+func TestExtractTraceContext(t *testing.T) {
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	tests := []struct {
+		name      string
+		header    http.Header
+		wantOK    bool
+		wantState string
+	}{
+		{name: "no header", header: http.Header{}},
+		{name: "invalid traceparent", header: http.Header{"Traceparent": {"00-invalid"}, "Tracestate": {"a=1"}}},
+		{name: "no tracestate", header: http.Header{"Traceparent": {parent}}, wantOK: true},
+		{name: "tracestate", header: http.Header{"Traceparent": {parent}, "Tracestate": {"a=1,b=2"}}, wantOK: true, wantState: "a=1,b=2"},
+		{name: "multiple tracestate headers", header: http.Header{"Traceparent": {parent}, "Tracestate": {"a=1", "b=2"}}, wantOK: true, wantState: "a=1,b=2"},
+		{name: "invalid tracestate", header: http.Header{"Traceparent": {parent}, "Tracestate": {"a"}}, wantOK: true},
+		{name: "too many members", header: http.Header{"Traceparent": {parent}, "Tracestate": {strings.Repeat("k=v,", 33)}}, wantOK: true},
+		{name: "tracestate too long", header: http.Header{"Traceparent": {parent}, "Tracestate": {"k=" + strings.Repeat("v", 511)}}, wantOK: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, ok := ExtractTraceContext(tt.header)
+			if ok != tt.wantOK || sc.TraceState != tt.wantState {
+				t.Fatalf("ExtractTraceContext = %+v, %v; want ok=%v state=%q", sc, ok, tt.wantOK, tt.wantState)
+			}
+		})
+	}
+}
@@ -13,6 +13,7 @@ type node struct {
 	handlers []ControllerHandler // 代表这个节点中包含的控制器，用于最终加载调用: 变成一个队列：中间件+控制器
 	childs   []*node             // 代表这个节点下的子节点
 	parent   *node               // 指针，构造一个双向链表
+	pattern  string              // 终极节点注册时的路由规则，例如 /subject/:id
 }
 
 func newNode() *node {
@@ -39,6 +40,11 @@ func isWildSegment(segment string) bool {
 	return strings.HasPrefix(segment, ":")
 }
 
+// 判断一个segment是否匹配剩余的全部路径，即以*开头，只能作为最后一个segment
+func isCatchAllSegment(segment string) bool {
+	return strings.HasPrefix(segment, "*")
+}
+
 // 过滤下一层满足segment规则的子节点
 func (n *node) filterChildNodes(segment string) []*node {
 	if len(n.childs) == 0 {
@@ -51,9 +57,19 @@ func (n *node) filterChildNodes(segment string) []*node {
 	}
 
 	nodes := make([]*node, 0, len(n.childs))
+	// 如果segment匹配剩余全部路径，则只和同样的子节点冲突
+	if isCatchAllSegment(segment) {
+		for _, cnode := range n.childs {
+			if isCatchAllSegment(cnode.segment) {
+				nodes = append(nodes, cnode)
+			}
+		}
+		return nodes
+	}
+
 	// 过滤所有的下一层子节点
 	for _, cnode := range n.childs {
-		if isWildSegment(cnode.segment) {
+		if isWildSegment(cnode.segment) || isCatchAllSegment(cnode.segment) {
 			// 如果下一层子节点有通配符，则满足需求
 			nodes = append(nodes, cnode)
 		} else if cnode.segment == segment {
@@ -71,7 +87,7 @@ func (n *node) matchNode(uri string) *node {
 	segments := strings.SplitN(uri, "/", 2)
 	// 第一个部分用于匹配下一层子节点
 	segment := segments[0]
-	if !isWildSegment(segment) {
+	if !isWildSegment(segment) && !isCatchAllSegment(segment) {
 		segment = strings.ToUpper(segment)
 	}
 	// 匹配符合的下一层子节点
@@ -81,26 +97,34 @@ func (n *node) matchNode(uri string) *node {
 		return nil
 	}
 
-	// 如果只有一个segment，则是最后一个标记
-	if len(segments) == 1 {
-		// 如果segment已经是最后一个节点，判断这些cnode是否有isLast标志
-		for _, tn := range cnodes {
+	var catchAll *node
+	for _, tn := range cnodes {
+		// 匹配剩余全部路径的节点优先级最低，其他节点都不匹配时才使用
+		if isCatchAllSegment(tn.segment) {
+			if catchAll == nil {
+				catchAll = tn
+			}
+			continue
+		}
+
+		// 如果只有一个segment，则是最后一个标记
+		if len(segments) == 1 {
+			// 如果segment已经是最后一个节点，判断这些cnode是否有isLast标志
 			if tn.isLast {
 				return tn
 			}
+			continue
 		}
 
-		// 都不是最后一个节点
-		return nil
-	}
-
-	// 如果有2个segment, 递归每个子节点继续进行查找
-	for _, tn := range cnodes {
+		// 如果有2个segment, 递归每个子节点继续进行查找
 		tnMatch := tn.matchNode(segments[1])
 		if tnMatch != nil {
 			return tnMatch
 		}
 	}
+	if catchAll != nil && catchAll.isLast {
+		return catchAll
+	}
 	return nil
 }
 
@@ -112,19 +136,26 @@ func (n *node) matchNode(uri string) *node {
 /book/:student/age
 /:user/name(冲突)
 /:user/name/:age
+/static/*filepath
 */
 func (tree *Tree) AddRouter(uri string, handlers []ControllerHandler) error {
 	n := tree.root
-	if n.matchNode(uri) != nil {
-		return errors.New("route exist: " + uri)
+	segments := strings.Split(uri, "/")
+	// 被匹配剩余全部路径的节点覆盖的路由不算冲突
+	if matched := n.matchNode(uri); matched != nil {
+		if !isCatchAllSegment(matched.segment) || isCatchAllSegment(segments[len(segments)-1]) {
+			return errors.New("route exist: " + uri)
+		}
 	}
 
-	segments := strings.Split(uri, "/")
 	// 对每个segment
 	for index, segment := range segments {
+		if isCatchAllSegment(segment) && index != len(segments)-1 {
+			return errors.New("catch-all segment must be the last one: " + uri)
+		}
 
 		// 最终进入Node segment的字段
-		if !isWildSegment(segment) {
+		if !isWildSegment(segment) && !isCatchAllSegment(segment) {
 			segment = strings.ToUpper(segment)
 		}
 		isLast := index == len(segments)-1
@@ -147,15 +178,17 @@ func (tree *Tree) AddRouter(uri string, handlers []ControllerHandler) error {
 			// 创建一个当前node的节点
 			cnode := newNode()
 			cnode.segment = segment
-			if isLast {
-				cnode.isLast = true
-				cnode.handlers = handlers
-			}
 			// 父节点指针修改
 			cnode.parent = n
 			n.childs = append(n.childs, cnode)
 			objNode = cnode
 		}
+		// 已经存在的中间节点也可以成为终极节点，例如先注册/book/list再注册/book
+		if isLast {
+			objNode.isLast = true
+			objNode.handlers = handlers
+			objNode.pattern = uri
+		}
 
 		n = objNode
 	}
@@ -176,10 +209,19 @@ func (tree *Tree) FindHandler(uri string) []ControllerHandler {
 func (n *node) parseParamsFromEndNode(uri string) map[string]string {
 	ret := map[string]string{}
 	segments := strings.Split(uri, "/")
-	cnt := len(segments)
-	cur := n
-	for i := cnt - 1; i >= 0; i-- {
-		if cur.segment == "" {
+
+	// 从终点节点回溯到根节点，第i层节点对应uri的第i个segment
+	nodes := make([]*node, 0, len(segments))
+	for cur := n; cur != nil && cur.parent != nil; cur = cur.parent {
+		nodes = append([]*node{cur}, nodes...)
+	}
+	for i, cur := range nodes {
+		if i >= len(segments) {
+			break
+		}
+		// 匹配剩余全部路径的节点，取剩余的所有segment
+		if isCatchAllSegment(cur.segment) {
+			ret[cur.segment[1:]] = strings.Join(segments[i:], "/")
 			break
 		}
 		// 如果是通配符节点
@@ -187,7 +229,6 @@ func (n *node) parseParamsFromEndNode(uri string) map[string]string {
 			// 设置 params
 			ret[cur.segment[1:]] = segments[i]
 		}
-		cur = cur.parent
 	}
 	return ret
 }
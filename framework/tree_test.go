@@ -0,0 +1,53 @@
+package framework
+
+import "testing"
+
+func TestTreeIntermediateNodeBecomesTerminal(t *testing.T) {
+	handler := func(c *Context) error { return nil }
+	tests := []struct {
+		name    string
+		routes  []string
+		uri     string
+		pattern string
+	}{
+		{name: "parent after child", routes: []string{"/a/b", "/a"}, uri: "/a", pattern: "/a"},
+		{name: "child still matches", routes: []string{"/a/b", "/a"}, uri: "/a/b", pattern: "/a/b"},
+		{name: "child after parent", routes: []string{"/a", "/a/b"}, uri: "/a", pattern: "/a"},
+		{name: "param parent after child", routes: []string{"/book/:id/name", "/book/:id"}, uri: "/book/1", pattern: "/book/:id"},
+		{name: "case insensitive", routes: []string{"/a/b", "/a"}, uri: "/A", pattern: "/a"},
+		{name: "unregistered intermediate", routes: []string{"/a/b/c"}, uri: "/a/b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := NewTree()
+			for _, route := range tt.routes {
+				if err := tree.AddRouter(route, []ControllerHandler{handler}); err != nil {
+					t.Fatalf("AddRouter(%q): %v", route, err)
+				}
+			}
+			n := tree.root.matchNode(tt.uri)
+			if tt.pattern == "" {
+				if n != nil {
+					t.Fatalf("matchNode(%q) = %q, want no match", tt.uri, n.pattern)
+				}
+				return
+			}
+			if n == nil || n.pattern != tt.pattern || len(n.handlers) != 1 {
+				t.Fatalf("matchNode(%q) = %v, want pattern %q", tt.uri, n, tt.pattern)
+			}
+		})
+	}
+}
+
+func TestTreeDuplicateRoute(t *testing.T) {
+	handler := func(c *Context) error { return nil }
+	tree := NewTree()
+	for _, route := range []string{"/a/b", "/a"} {
+		if err := tree.AddRouter(route, []ControllerHandler{handler}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tree.AddRouter("/a", []ControllerHandler{handler}); err == nil {
+		t.Fatal("registering /a twice should fail")
+	}
+}
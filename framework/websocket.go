@@ -0,0 +1,250 @@
+package framework
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// websocketGUID 握手时计算Sec-WebSocket-Accept使用的固定值，见RFC 6455 1.3
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// defaultWebSocketReadLimit 默认单条消息的最大字节数
+const defaultWebSocketReadLimit = 32 << 20 // 32 MB
+
+// WebSocketConfig websocket连接配置
+type WebSocketConfig struct {
+	ReadLimit         int64                      // 单条消息的最大字节数，超过时以1009关闭连接，默认32MB
+	Subprotocols      []string                   // 服务端支持的子协议，按优先级排列
+	CheckOrigin       func(r *http.Request) bool // 校验Origin，默认只允许同源请求
+	EnableCompression bool                       // 是否协商permessage-deflate压缩
+	FragmentSize      int                        // 发送消息时单个帧的最大长度，0表示不分片
+	PingInterval      time.Duration              // 定时发送ping的间隔，超过两个间隔没有收到数据时认为连接断开，0表示不发送
+}
+
+// WebSocketHandler 处理升级后的websocket连接，返回后连接会被关闭
+type WebSocketHandler func(c *Context, conn *WebSocketConn) error
+
+// HandshakeError 握手失败的错误，此时已经向客户端返回了对应的状态码
+type HandshakeError struct {
+	Status  int
+	Message string
+}
+
+func (e HandshakeError) Error() string {
+	return "websocket handshake: " + e.Message
+}
+
+// WebSocket 注册websocket路由，config为nil时使用默认配置，middlewares在升级之前执行，可以用来做鉴权
+func (c *Core) WebSocket(url string, config *WebSocketConfig, handler WebSocketHandler, middlewares ...ControllerHandler) {
+	c.Get(url, websocketHandlers(config, handler, middlewares)...)
+}
+
+// WebSocket 注册websocket路由，config为nil时使用默认配置，middlewares在升级之前执行，可以用来做鉴权
+func (g *Group) WebSocket(url string, config *WebSocketConfig, handler WebSocketHandler, middlewares ...ControllerHandler) {
+	g.Get(url, websocketHandlers(config, handler, middlewares)...)
+}
+
+// websocketHandlers 将控制器加在middlewares之后，不修改调用方的slice
+func websocketHandlers(config *WebSocketConfig, handler WebSocketHandler, middlewares []ControllerHandler) []ControllerHandler {
+	handlers := make([]ControllerHandler, 0, len(middlewares)+1)
+	handlers = append(handlers, middlewares...)
+	return append(handlers, websocketController(config, handler))
+}
+
+// websocketController 将WebSocketHandler包装为普通的控制器
+func websocketController(config *WebSocketConfig, handler WebSocketHandler) ControllerHandler {
+	return func(c *Context) error {
+		conn, err := c.Upgrade(config)
+		if err != nil {
+			// 握手失败时已经返回了对应的状态码
+			return nil
+		}
+		defer conn.Close()
+
+		if err := handler(c, conn); err != nil {
+			c.Logger().Error("websocket error", "error", err)
+			conn.CloseWithCode(CloseInternalServerErr, "")
+		}
+		return nil
+	}
+}
+
+// Upgrade 将当前请求升级为websocket连接，config为nil时使用默认配置
+// 握手失败时会直接向客户端返回对应的状态码，并返回HandshakeError
+func (ctx *Context) Upgrade(config *WebSocketConfig) (*WebSocketConn, error) {
+	if config == nil {
+		config = &WebSocketConfig{}
+	}
+	r := ctx.request
+	var w http.ResponseWriter = ctx.responseWriter
+
+	fail := func(status int, message string) (*WebSocketConn, error) {
+		http.Error(w, http.StatusText(status), status)
+		return nil, HandshakeError{Status: status, Message: message}
+	}
+
+	if r.Method != http.MethodGet {
+		return fail(http.StatusMethodNotAllowed, "method is not GET")
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") {
+		return fail(http.StatusBadRequest, "'upgrade' token not found in 'Connection' header")
+	}
+	if !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return fail(http.StatusBadRequest, "'websocket' token not found in 'Upgrade' header")
+	}
+	if r.Header.Get("Sec-Websocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return fail(http.StatusUpgradeRequired, "unsupported version")
+	}
+	key := r.Header.Get("Sec-Websocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return fail(http.StatusBadRequest, "invalid 'Sec-WebSocket-Key' header")
+	}
+	checkOrigin := config.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		return fail(http.StatusForbidden, "origin not allowed")
+	}
+
+	subprotocol := selectSubprotocol(r, config.Subprotocols)
+	compress := config.EnableCompression && acceptsPerMessageDeflate(r)
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return fail(http.StatusInternalServerError, "response does not implement http.Hijacker")
+	}
+
+	// 握手响应带上中间件已经设置的header
+	buf := make([]byte, 0, 256)
+	buf = append(buf, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: "...)
+	buf = append(buf, websocketAccept(key)...)
+	buf = append(buf, "\r\n"...)
+	if subprotocol != "" {
+		buf = append(buf, "Sec-WebSocket-Protocol: "+subprotocol+"\r\n"...)
+	}
+	if compress {
+		buf = append(buf, "Sec-WebSocket-Extensions: permessage-deflate; server_no_context_takeover; client_no_context_takeover\r\n"...)
+	}
+	for k, vals := range w.Header() {
+		switch k {
+		case "Content-Type", "Content-Length", "Connection", "Upgrade", "Transfer-Encoding":
+			continue
+		}
+		for _, v := range vals {
+			buf = append(buf, k+": "+v+"\r\n"...)
+		}
+	}
+	buf = append(buf, "\r\n"...)
+
+	netConn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return fail(http.StatusInternalServerError, err.Error())
+	}
+	// 清除http server设置的超时
+	netConn.SetDeadline(time.Time{})
+	if _, err := netConn.Write(buf); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	var br *bufio.Reader
+	if brw != nil && brw.Reader != nil {
+		br = brw.Reader
+	} else {
+		br = bufio.NewReader(netConn)
+	}
+	conn := newWebSocketConn(netConn, br, config, subprotocol, compress)
+	if ctx.core != nil {
+		// http.Server关闭时不会通知被hijack的连接，开始优雅关闭时以1001关闭，让处理函数尽快返回
+		conn.watchDrain(ctx.core.inflight)
+	}
+	return conn, nil
+}
+
+// websocketAccept 计算Sec-WebSocket-Accept
+func websocketAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerContainsToken 判断逗号分隔的header中是否包含token，不区分大小写
+func headerContainsToken(header http.Header, name string, token string) bool {
+	for _, val := range header.Values(name) {
+		for _, part := range strings.Split(val, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameOrigin 没有Origin或者Origin和Host一致时允许连接
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// selectSubprotocol 选择客户端请求的子协议中服务端优先级最高的一个
+func selectSubprotocol(r *http.Request, supported []string) string {
+	requested := map[string]bool{}
+	for _, val := range r.Header.Values("Sec-Websocket-Protocol") {
+		for _, p := range strings.Split(val, ",") {
+			requested[strings.TrimSpace(p)] = true
+		}
+	}
+	for _, p := range supported {
+		if requested[p] {
+			return p
+		}
+	}
+	return ""
+}
+
+// acceptsPerMessageDeflate 判断客户端是否提供了可以接受的permessage-deflate参数
+// 服务端总是使用32K窗口，客户端要求更小的server_max_window_bits时不启用压缩
+func acceptsPerMessageDeflate(r *http.Request) bool {
+	for _, val := range r.Header.Values("Sec-Websocket-Extensions") {
+		for _, offer := range strings.Split(val, ",") {
+			params := strings.Split(offer, ";")
+			if strings.TrimSpace(params[0]) != "permessage-deflate" {
+				continue
+			}
+			ok := true
+			for _, param := range params[1:] {
+				name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				switch strings.TrimSpace(name) {
+				case "server_no_context_takeover", "client_no_context_takeover", "client_max_window_bits":
+				case "server_max_window_bits":
+					if strings.Trim(strings.TrimSpace(value), `"`) != "15" {
+						ok = false
+					}
+				default:
+					ok = false
+				}
+			}
+			if ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var errWebSocketClosed = errors.New("websocket: use of closed connection")
@@ -0,0 +1,595 @@
+package framework
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// websocket消息类型，见RFC 6455 11.8
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+
+	continuationFrame = 0
+)
+
+// websocket关闭码，见RFC 6455 7.4.1
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseMandatoryExtension      = 1010
+	CloseInternalServerErr       = 1011
+)
+
+const (
+	finalBit = 1 << 7
+	rsv1Bit  = 1 << 6
+	rsv2Bit  = 1 << 5
+	rsv3Bit  = 1 << 4
+	maskBit  = 1 << 7
+
+	maxControlPayload = 125
+
+	// 小于这个长度的消息压缩收益很小，直接发送
+	minCompressSize = 64
+)
+
+// deflate去掉的尾部，解压时补上，再追加一个空的final块让reader正常结束
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+
+var (
+	flateWriterPool = sync.Pool{New: func() interface{} {
+		w, _ := flate.NewWriter(nil, flate.BestSpeed)
+		return w
+	}}
+	flateReaderPool = sync.Pool{New: func() interface{} {
+		return flate.NewReader(nil)
+	}}
+)
+
+// CloseError 对端发送close帧或者连接因为协议错误关闭
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return "websocket: close " + strconv.Itoa(e.Code) + " " + e.Text
+}
+
+// WebSocketConn 升级后的websocket连接
+// 同一时间只能有一个goroutine读，写操作可以并发调用
+type WebSocketConn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	subprotocol string
+	compress    bool // 是否协商了permessage-deflate
+
+	readLimit    int64
+	fragmentSize int
+	pingInterval time.Duration
+
+	writeMu   sync.Mutex
+	closeSent bool
+
+	pingHandler func(data string) error
+	pongHandler func(data string) error
+
+	readErr   error
+	closeOnce sync.Once
+	closed    chan struct{}
+	onClose   func() // 关闭时执行，用于取消优雅关闭的通知
+}
+
+func newWebSocketConn(conn net.Conn, br *bufio.Reader, config *WebSocketConfig, subprotocol string, compress bool) *WebSocketConn {
+	c := &WebSocketConn{
+		conn:         conn,
+		br:           br,
+		subprotocol:  subprotocol,
+		compress:     compress,
+		readLimit:    config.ReadLimit,
+		fragmentSize: config.FragmentSize,
+		pingInterval: config.PingInterval,
+		closed:       make(chan struct{}),
+	}
+	if c.readLimit <= 0 {
+		c.readLimit = defaultWebSocketReadLimit
+	}
+	c.pingHandler = func(data string) error {
+		err := c.WriteControl(PongMessage, []byte(data), time.Now().Add(time.Second))
+		if errors.Is(err, errWebSocketClosed) {
+			return nil
+		}
+		return err
+	}
+	c.pongHandler = func(string) error { return nil }
+
+	if c.pingInterval > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(2 * c.pingInterval))
+		go c.keepalive()
+	}
+	return c
+}
+
+// keepalive 定时发送ping，连接关闭时退出
+func (c *WebSocketConn) keepalive() {
+	ticker := time.NewTicker(c.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.closed:
+			return
+		case <-ticker.C:
+			if err := c.WriteControl(PingMessage, nil, time.Now().Add(c.pingInterval)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Subprotocol 协商的子协议
+func (c *WebSocketConn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// RemoteAddr 对端地址
+func (c *WebSocketConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// SetReadLimit 设置单条消息的最大字节数
+func (c *WebSocketConn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+// SetReadDeadline 设置读超时
+func (c *WebSocketConn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline 设置写超时
+func (c *WebSocketConn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// SetPingHandler 设置收到ping时的处理函数，默认回复pong
+func (c *WebSocketConn) SetPingHandler(h func(data string) error) {
+	c.pingHandler = h
+}
+
+// SetPongHandler 设置收到pong时的处理函数
+func (c *WebSocketConn) SetPongHandler(h func(data string) error) {
+	c.pongHandler = h
+}
+
+// frameHeader 帧头
+type frameHeader struct {
+	fin      bool
+	rsv1     bool
+	opcode   int
+	length   int64
+	maskKey  [4]byte
+	isMasked bool
+}
+
+// readFrameHeader 读取并校验帧头
+func (c *WebSocketConn) readFrameHeader() (frameHeader, error) {
+	var h frameHeader
+	var b [8]byte
+	if _, err := io.ReadFull(c.br, b[:2]); err != nil {
+		return h, err
+	}
+	h.fin = b[0]&finalBit != 0
+	h.rsv1 = b[0]&rsv1Bit != 0
+	h.opcode = int(b[0] & 0x0f)
+	h.isMasked = b[1]&maskBit != 0
+	h.length = int64(b[1] & 0x7f)
+
+	if b[0]&(rsv2Bit|rsv3Bit) != 0 {
+		return h, c.protocolError(CloseProtocolError, "unexpected reserved bits")
+	}
+	switch h.length {
+	case 126:
+		if _, err := io.ReadFull(c.br, b[:2]); err != nil {
+			return h, err
+		}
+		h.length = int64(binary.BigEndian.Uint16(b[:2]))
+	case 127:
+		if _, err := io.ReadFull(c.br, b[:8]); err != nil {
+			return h, err
+		}
+		length := binary.BigEndian.Uint64(b[:8])
+		if length>>63 != 0 {
+			return h, c.protocolError(CloseProtocolError, "invalid payload length")
+		}
+		h.length = int64(length)
+	}
+	// 客户端发送的帧必须经过掩码处理，见RFC 6455 5.1
+	if !h.isMasked {
+		return h, c.protocolError(CloseProtocolError, "client frame is not masked")
+	}
+	if _, err := io.ReadFull(c.br, h.maskKey[:]); err != nil {
+		return h, err
+	}
+
+	switch h.opcode {
+	case CloseMessage, PingMessage, PongMessage:
+		if !h.fin || h.length > maxControlPayload {
+			return h, c.protocolError(CloseProtocolError, "invalid control frame")
+		}
+		if h.rsv1 {
+			return h, c.protocolError(CloseProtocolError, "control frame is compressed")
+		}
+	case continuationFrame:
+		if h.rsv1 {
+			return h, c.protocolError(CloseProtocolError, "continuation frame is compressed")
+		}
+	case TextMessage, BinaryMessage:
+		if h.rsv1 && !c.compress {
+			return h, c.protocolError(CloseProtocolError, "compression not negotiated")
+		}
+	default:
+		return h, c.protocolError(CloseProtocolError, "unknown opcode "+strconv.Itoa(h.opcode))
+	}
+	return h, nil
+}
+
+// readPayload 读取帧的内容并去掉掩码
+func (c *WebSocketConn) readPayload(h frameHeader, dst []byte) ([]byte, error) {
+	start := len(dst)
+	dst = append(dst, make([]byte, h.length)...)
+	if _, err := io.ReadFull(c.br, dst[start:]); err != nil {
+		return nil, err
+	}
+	for i := range dst[start:] {
+		dst[start+i] ^= h.maskKey[i&3]
+	}
+	return dst, nil
+}
+
+// ReadMessage 读取一条完整的消息，期间收到的控制帧会被自动处理
+// 对端关闭连接时返回*CloseError
+func (c *WebSocketConn) ReadMessage() (messageType int, data []byte, err error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+	defer func() {
+		if err != nil {
+			c.readErr = err
+		}
+	}()
+
+	messageType = 0
+	compressed := false
+	for {
+		h, err := c.readFrameHeader()
+		if err != nil {
+			return 0, nil, err
+		}
+		if c.pingInterval > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(2 * c.pingInterval))
+		}
+
+		if h.opcode >= CloseMessage {
+			payload, err := c.readPayload(h, nil)
+			if err != nil {
+				return 0, nil, err
+			}
+			if err := c.handleControl(h.opcode, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		}
+
+		if h.opcode == continuationFrame && messageType == 0 {
+			return 0, nil, c.protocolError(CloseProtocolError, "continuation frame without message")
+		}
+		if h.opcode != continuationFrame {
+			if messageType != 0 {
+				return 0, nil, c.protocolError(CloseProtocolError, "new message before previous one finished")
+			}
+			messageType = h.opcode
+			compressed = h.rsv1
+		}
+		if int64(len(data))+h.length > c.readLimit {
+			return 0, nil, c.protocolError(CloseMessageTooBig, "message too big")
+		}
+		if data, err = c.readPayload(h, data); err != nil {
+			return 0, nil, err
+		}
+		if h.fin {
+			break
+		}
+	}
+
+	if compressed {
+		if data, err = c.inflate(data); err != nil {
+			return 0, nil, err
+		}
+	}
+	if messageType == TextMessage && !utf8.Valid(data) {
+		return 0, nil, c.protocolError(CloseInvalidFramePayloadData, "invalid utf8 payload")
+	}
+	if data == nil {
+		data = []byte{}
+	}
+	return messageType, data, nil
+}
+
+// ReadJSON 读取一条消息并解析为json
+func (c *WebSocketConn) ReadJSON(v interface{}) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// handleControl 处理控制帧
+func (c *WebSocketConn) handleControl(opcode int, payload []byte) error {
+	switch opcode {
+	case PingMessage:
+		return c.pingHandler(string(payload))
+	case PongMessage:
+		return c.pongHandler(string(payload))
+	}
+
+	// close帧：校验关闭码和原因后原样回复，见RFC 6455 5.5.1
+	code, text := CloseNoStatusReceived, ""
+	if len(payload) == 1 {
+		return c.protocolError(CloseProtocolError, "invalid close payload")
+	}
+	if len(payload) >= 2 {
+		code = int(binary.BigEndian.Uint16(payload))
+		text = string(payload[2:])
+		if !validCloseCode(code) {
+			return c.protocolError(CloseProtocolError, "invalid close code")
+		}
+		if !utf8.ValidString(text) {
+			return c.protocolError(CloseInvalidFramePayloadData, "invalid utf8 close reason")
+		}
+	}
+	reply := []byte{}
+	if code != CloseNoStatusReceived {
+		reply = closePayload(code, "")
+	}
+	c.WriteControl(CloseMessage, reply, time.Now().Add(time.Second))
+	return &CloseError{Code: code, Text: text}
+}
+
+// validCloseCode 对端可以发送的关闭码
+func validCloseCode(code int) bool {
+	switch code {
+	case CloseNormalClosure, CloseGoingAway, CloseProtocolError, CloseUnsupportedData,
+		CloseInvalidFramePayloadData, ClosePolicyViolation, CloseMessageTooBig,
+		CloseMandatoryExtension, CloseInternalServerErr, 1012, 1013, 1014:
+		return true
+	}
+	return code >= 3000 && code <= 4999
+}
+
+// protocolError 发送对应的close帧并返回错误，之后连接不再可读
+func (c *WebSocketConn) protocolError(code int, text string) error {
+	c.WriteControl(CloseMessage, closePayload(code, text), time.Now().Add(time.Second))
+	return &CloseError{Code: code, Text: text}
+}
+
+func closePayload(code int, text string) []byte {
+	if len(text) > maxControlPayload-2 {
+		text = text[:maxControlPayload-2]
+	}
+	payload := make([]byte, 2, 2+len(text))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return append(payload, text...)
+}
+
+// inflate 解压permessage-deflate压缩的消息，解压后同样受readLimit限制
+func (c *WebSocketConn) inflate(data []byte) ([]byte, error) {
+	fr := flateReaderPool.Get().(io.ReadCloser)
+	defer flateReaderPool.Put(fr)
+	if err := fr.(flate.Resetter).Reset(io.MultiReader(bytes.NewReader(data), bytes.NewReader(deflateTail)), nil); err != nil {
+		return nil, err
+	}
+	out, err := io.ReadAll(io.LimitReader(fr, c.readLimit+1))
+	if err != nil {
+		return nil, c.protocolError(CloseInvalidFramePayloadData, "invalid compressed payload")
+	}
+	if int64(len(out)) > c.readLimit {
+		return nil, c.protocolError(CloseMessageTooBig, "message too big")
+	}
+	return out, nil
+}
+
+// deflate 压缩消息并去掉尾部的 00 00 ff ff
+func deflate(data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	fw := flateWriterPool.Get().(*flate.Writer)
+	defer flateWriterPool.Put(fw)
+	fw.Reset(buf)
+	if _, err := fw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := fw.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), deflateTail[:4]), nil
+}
+
+// WriteMessage 发送一条消息，配置了FragmentSize时超过长度的消息会分片发送
+func (c *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return c.WriteControl(messageType, data, time.Time{})
+	}
+
+	compressed := false
+	if c.compress && len(data) >= minCompressSize {
+		deflated, err := deflate(data)
+		if err != nil {
+			return err
+		}
+		data, compressed = deflated, true
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return errWebSocketClosed
+	}
+
+	opcode := messageType
+	for {
+		frame := data
+		if c.fragmentSize > 0 && len(frame) > c.fragmentSize {
+			frame = data[:c.fragmentSize]
+		}
+		data = data[len(frame):]
+
+		b0 := byte(opcode)
+		if len(data) == 0 {
+			b0 |= finalBit
+		}
+		// 压缩标记只设置在第一个帧上
+		if compressed && opcode != continuationFrame {
+			b0 |= rsv1Bit
+		}
+		if err := c.writeFrame(b0, frame); err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			return nil
+		}
+		opcode = continuationFrame
+	}
+}
+
+// WriteJSON 将v编码为json后作为文本消息发送
+func (c *WebSocketConn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(TextMessage, data)
+}
+
+// WriteControl 发送控制帧，deadline为零值表示不设置超时
+func (c *WebSocketConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	if messageType != CloseMessage && messageType != PingMessage && messageType != PongMessage {
+		return fmt.Errorf("websocket: invalid control message type %d", messageType)
+	}
+	if len(data) > maxControlPayload {
+		return errors.New("websocket: control frame too large")
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return errWebSocketClosed
+	}
+	if !deadline.IsZero() {
+		c.conn.SetWriteDeadline(deadline)
+		defer c.conn.SetWriteDeadline(time.Time{})
+	}
+	if messageType == CloseMessage {
+		c.closeSent = true
+	}
+	return c.writeFrame(byte(messageType)|finalBit, data)
+}
+
+// Ping 发送ping帧
+func (c *WebSocketConn) Ping(data []byte) error {
+	return c.WriteControl(PingMessage, data, time.Time{})
+}
+
+// writeFrame 写入一个帧，服务端发送的帧不需要掩码，调用方需要持有写锁
+func (c *WebSocketConn) writeFrame(b0 byte, payload []byte) error {
+	header := make([]byte, 2, 10+len(payload))
+	header[0] = b0
+	switch length := len(payload); {
+	case length <= 125:
+		header[1] = byte(length)
+	case length <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+	_, err := c.conn.Write(append(header, payload...))
+	return err
+}
+
+// CloseWithCode 发送close帧后关闭连接
+func (c *WebSocketConn) CloseWithCode(code int, reason string) error {
+	err := c.WriteControl(CloseMessage, closePayload(code, reason), time.Now().Add(time.Second))
+	if errors.Is(err, errWebSocketClosed) {
+		err = nil
+	}
+	if closeErr := c.close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Close 以1000正常关闭连接
+func (c *WebSocketConn) Close() error {
+	return c.CloseWithCode(CloseNormalClosure, "")
+}
+
+func (c *WebSocketConn) close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		err = c.conn.Close()
+		if c.onClose != nil {
+			c.onClose()
+		}
+	})
+	return err
+}
+
+// watchDrain 开始优雅关闭时以1001关闭连接，连接关闭时取消注册
+// 钩子可能在注册完成前就在其他goroutine中执行，所以要先设置onClose再注册
+func (c *WebSocketConn) watchDrain(t *InflightTracker) {
+	var mu sync.Mutex
+	var cancel func()
+	closed := false
+	c.onClose = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		closed = true
+		if cancel != nil {
+			cancel()
+		}
+	}
+	unregister := t.onDrain(func() {
+		c.CloseWithCode(CloseGoingAway, "server shutting down")
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	if closed {
+		unregister()
+		return
+	}
+	cancel = unregister
+}
@@ -0,0 +1,502 @@
+package framework
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsTestKey RFC 6455 1.3中的示例key
+const wsTestKey = "dGhlIHNhbXBsZSBub25jZQ=="
+
+func TestWebSocketAccept(t *testing.T) {
+	if got := websocketAccept(wsTestKey); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("websocketAccept = %q", got)
+	}
+}
+
+func TestWebSocketHandshakeError(t *testing.T) {
+	valid := func() *http.Request {
+		r := httptest.NewRequest("GET", "http://example.com/ws", nil)
+		r.Header.Set("Connection", "keep-alive, Upgrade")
+		r.Header.Set("Upgrade", "WebSocket")
+		r.Header.Set("Sec-WebSocket-Version", "13")
+		r.Header.Set("Sec-WebSocket-Key", wsTestKey)
+		return r
+	}
+	tests := []struct {
+		name   string
+		modify func(r *http.Request)
+		status int
+	}{
+		{name: "method", modify: func(r *http.Request) { r.Method = "POST" }, status: http.StatusMethodNotAllowed},
+		{name: "no connection upgrade", modify: func(r *http.Request) { r.Header.Set("Connection", "keep-alive") }, status: http.StatusBadRequest},
+		{name: "no upgrade", modify: func(r *http.Request) { r.Header.Del("Upgrade") }, status: http.StatusBadRequest},
+		{name: "version", modify: func(r *http.Request) { r.Header.Set("Sec-WebSocket-Version", "8") }, status: http.StatusUpgradeRequired},
+		{name: "short key", modify: func(r *http.Request) { r.Header.Set("Sec-WebSocket-Key", "c2hvcnQ=") }, status: http.StatusBadRequest},
+		{name: "invalid key", modify: func(r *http.Request) { r.Header.Set("Sec-WebSocket-Key", "not base64!") }, status: http.StatusBadRequest},
+		{name: "cross origin", modify: func(r *http.Request) { r.Header.Set("Origin", "http://evil.com") }, status: http.StatusForbidden},
+		// httptest.ResponseRecorder不支持Hijack
+		{name: "not hijacker", modify: func(r *http.Request) { r.Header.Set("Origin", "http://EXAMPLE.com") }, status: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		r := valid()
+		tt.modify(r)
+		rec := httptest.NewRecorder()
+		_, err := NewContext(r, rec).Upgrade(nil)
+		var handshakeErr HandshakeError
+		if !errors.As(err, &handshakeErr) || handshakeErr.Status != tt.status || rec.Code != tt.status {
+			t.Errorf("%s: err = %v, status = %d, want %d", tt.name, err, rec.Code, tt.status)
+		}
+		if tt.status == http.StatusUpgradeRequired && rec.Header().Get("Sec-WebSocket-Version") != "13" {
+			t.Errorf("%s: missing Sec-WebSocket-Version", tt.name)
+		}
+	}
+}
+
+func TestSelectSubprotocolAndDeflate(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Add("Sec-WebSocket-Protocol", "chat, superchat")
+	r.Header.Add("Sec-WebSocket-Protocol", "json")
+	if got := selectSubprotocol(r, []string{"json", "chat"}); got != "json" {
+		t.Errorf("selectSubprotocol = %q, want json", got)
+	}
+	if got := selectSubprotocol(r, []string{"mqtt"}); got != "" {
+		t.Errorf("selectSubprotocol = %q, want empty", got)
+	}
+
+	tests := []struct {
+		offer string
+		want  bool
+	}{
+		{offer: "permessage-deflate", want: true},
+		{offer: "permessage-deflate; client_max_window_bits", want: true},
+		{offer: `permessage-deflate; server_max_window_bits="15"`, want: true},
+		{offer: "permessage-deflate; server_max_window_bits=10", want: false},
+		{offer: "permessage-deflate; server_max_window_bits=10, permessage-deflate", want: true},
+		{offer: "permessage-deflate; unknown", want: false},
+		{offer: "x-webkit-deflate-frame", want: false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Sec-WebSocket-Extensions", tt.offer)
+		if got := acceptsPerMessageDeflate(r); got != tt.want {
+			t.Errorf("acceptsPerMessageDeflate(%q) = %v, want %v", tt.offer, got, tt.want)
+		}
+	}
+}
+
+// writeClientFrame 以客户端身份写入一个带掩码的帧
+func writeClientFrame(w io.Writer, b0 byte, payload []byte) error {
+	key := [4]byte{0x12, 0x34, 0x56, 0x78}
+	frame := []byte{b0}
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, maskBit|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	frame = append(frame, key[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^key[i&3])
+	}
+	_, err := w.Write(frame)
+	return err
+}
+
+// readServerFrame 以客户端身份读取一个服务端发送的帧
+func readServerFrame(r *bufio.Reader) (byte, []byte, error) {
+	var h [2]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return 0, nil, err
+	}
+	length := int(h[1] & 0x7f)
+	switch length {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, nil, err
+		}
+		length = int(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, nil, err
+		}
+		length = int(binary.BigEndian.Uint64(b[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return h[0], payload, nil
+}
+
+// wsPipe 通过net.Pipe连接的服务端连接和客户端
+type wsPipe struct {
+	server *WebSocketConn
+	client net.Conn
+	reader *bufio.Reader
+}
+
+func newWSPipe(t *testing.T, config *WebSocketConfig) *wsPipe {
+	serverSide, clientSide := net.Pipe()
+	deadline := time.Now().Add(5 * time.Second)
+	serverSide.SetDeadline(deadline)
+	clientSide.SetDeadline(deadline)
+	t.Cleanup(func() {
+		serverSide.Close()
+		clientSide.Close()
+	})
+	return &wsPipe{
+		server: newWebSocketConn(serverSide, bufio.NewReader(serverSide), config, "", false),
+		client: clientSide,
+		reader: bufio.NewReader(clientSide),
+	}
+}
+
+// send 在后台写入客户端帧，net.Pipe没有缓冲，写入和服务端的回复需要并发进行
+func (p *wsPipe) send(frames ...[]byte) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		for _, f := range frames {
+			if err := writeClientFrame(p.client, f[0], f[1:]); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	return done
+}
+
+// frame 第一个字节为帧头的b0，后面为内容
+func frame(b0 byte, payload string) []byte {
+	return append([]byte{b0}, payload...)
+}
+
+type readResult struct {
+	messageType int
+	data        []byte
+	err         error
+}
+
+func (p *wsPipe) read() <-chan readResult {
+	result := make(chan readResult, 1)
+	go func() {
+		messageType, data, err := p.server.ReadMessage()
+		result <- readResult{messageType, data, err}
+	}()
+	return result
+}
+
+func TestWebSocketConnFragmentedMessage(t *testing.T) {
+	p := newWSPipe(t, &WebSocketConfig{})
+	result := p.read()
+	sent := p.send(
+		frame(TextMessage, "hel"),
+		// 分片之间可以插入控制帧
+		frame(finalBit|PingMessage, "ping-data"),
+		frame(continuationFrame, "lo, "),
+		frame(finalBit|continuationFrame, "世界"),
+	)
+
+	b0, payload, err := readServerFrame(p.reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b0 != finalBit|PongMessage || string(payload) != "ping-data" {
+		t.Fatalf("pong frame = %#x %q", b0, payload)
+	}
+	if err := <-sent; err != nil {
+		t.Fatal(err)
+	}
+	r := <-result
+	if r.err != nil || r.messageType != TextMessage || string(r.data) != "hello, 世界" {
+		t.Fatalf("ReadMessage = %d %q %v", r.messageType, r.data, r.err)
+	}
+}
+
+func TestWebSocketConnPongHandler(t *testing.T) {
+	p := newWSPipe(t, &WebSocketConfig{})
+	pongs := make(chan string, 1)
+	p.server.SetPongHandler(func(data string) error {
+		pongs <- data
+		return nil
+	})
+	result := p.read()
+	if err := <-p.send(frame(finalBit|PongMessage, "pong-data"), frame(finalBit|BinaryMessage, "\x00\x01")); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-pongs; got != "pong-data" {
+		t.Fatalf("pong handler got %q", got)
+	}
+	r := <-result
+	if r.err != nil || r.messageType != BinaryMessage || string(r.data) != "\x00\x01" {
+		t.Fatalf("ReadMessage = %d %q %v", r.messageType, r.data, r.err)
+	}
+}
+
+func TestWebSocketConnWriteFragments(t *testing.T) {
+	p := newWSPipe(t, &WebSocketConfig{FragmentSize: 4})
+	done := make(chan error, 1)
+	go func() { done <- p.server.WriteMessage(TextMessage, []byte("0123456789")) }()
+
+	want := []struct {
+		b0      byte
+		payload string
+	}{
+		{TextMessage, "0123"},
+		{continuationFrame, "4567"},
+		{finalBit | continuationFrame, "89"},
+	}
+	for _, w := range want {
+		b0, payload, err := readServerFrame(p.reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b0 != w.b0 || string(payload) != w.payload {
+			t.Fatalf("frame = %#x %q, want %#x %q", b0, payload, w.b0, w.payload)
+		}
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebSocketConnClose(t *testing.T) {
+	tests := []struct {
+		name      string
+		frames    [][]byte
+		config    WebSocketConfig
+		wantCode  int // ReadMessage返回的关闭码
+		wantReply []byte
+	}{
+		{name: "normal", frames: [][]byte{frame(finalBit|CloseMessage, "\x03\xe8bye")}, wantCode: 1000, wantReply: []byte{0x03, 0xe8}},
+		{name: "no status", frames: [][]byte{frame(finalBit|CloseMessage, "")}, wantCode: CloseNoStatusReceived, wantReply: []byte{}},
+		{name: "application code", frames: [][]byte{frame(finalBit|CloseMessage, "\x0b\xb8")}, wantCode: 3000, wantReply: []byte{0x0b, 0xb8}},
+		{name: "one byte payload", frames: [][]byte{frame(finalBit|CloseMessage, "\x03")}, wantCode: CloseProtocolError},
+		{name: "reserved code 1005", frames: [][]byte{frame(finalBit|CloseMessage, "\x03\xed")}, wantCode: CloseProtocolError},
+		{name: "code below 1000", frames: [][]byte{frame(finalBit|CloseMessage, "\x03\xe7")}, wantCode: CloseProtocolError},
+		{name: "code above 4999", frames: [][]byte{frame(finalBit|CloseMessage, "\x13\x88")}, wantCode: CloseProtocolError},
+		{name: "invalid utf8 reason", frames: [][]byte{frame(finalBit|CloseMessage, "\x03\xe8\xff")}, wantCode: CloseInvalidFramePayloadData},
+		{name: "invalid utf8 text", frames: [][]byte{frame(finalBit|TextMessage, "\xff\xfe")}, wantCode: CloseInvalidFramePayloadData},
+		{name: "fragmented control", frames: [][]byte{frame(PingMessage, "")}, wantCode: CloseProtocolError},
+		{name: "continuation first", frames: [][]byte{frame(finalBit|continuationFrame, "x")}, wantCode: CloseProtocolError},
+		{name: "interleaved message", frames: [][]byte{frame(TextMessage, "a"), frame(finalBit|TextMessage, "b")}, wantCode: CloseProtocolError},
+		{name: "reserved bits", frames: [][]byte{frame(finalBit|rsv2Bit|TextMessage, "x")}, wantCode: CloseProtocolError},
+		{name: "unknown opcode", frames: [][]byte{frame(finalBit|3, "x")}, wantCode: CloseProtocolError},
+		{name: "uncompressed rsv1", frames: [][]byte{frame(finalBit|rsv1Bit|TextMessage, "x")}, wantCode: CloseProtocolError},
+		{name: "too big", config: WebSocketConfig{ReadLimit: 4}, frames: [][]byte{frame(TextMessage, "abc"), frame(finalBit|continuationFrame, "de")}, wantCode: CloseMessageTooBig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newWSPipe(t, &tt.config)
+			result := p.read()
+			sent := p.send(tt.frames...)
+
+			b0, payload, err := readServerFrame(p.reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b0 != finalBit|CloseMessage {
+				t.Fatalf("reply frame = %#x, want close", b0)
+			}
+			if tt.wantReply != nil {
+				if string(payload) != string(tt.wantReply) {
+					t.Fatalf("close reply = %x, want %x", payload, tt.wantReply)
+				}
+			} else if len(payload) < 2 || int(binary.BigEndian.Uint16(payload)) != tt.wantCode {
+				t.Fatalf("close reply = %x, want code %d", payload, tt.wantCode)
+			}
+
+			// 协议错误后服务端不再读取剩余的帧
+			p.client.Close()
+			<-sent
+			r := <-result
+			var closeErr *CloseError
+			if !errors.As(r.err, &closeErr) || closeErr.Code != tt.wantCode {
+				t.Fatalf("ReadMessage err = %v, want close %d", r.err, tt.wantCode)
+			}
+			if _, _, err := p.server.ReadMessage(); err != r.err {
+				t.Fatalf("second ReadMessage err = %v, want %v", err, r.err)
+			}
+			if err := p.server.WriteMessage(TextMessage, []byte("x")); err != errWebSocketClosed {
+				t.Fatalf("WriteMessage after close = %v", err)
+			}
+		})
+	}
+}
+
+func TestWebSocketConnUnmasked(t *testing.T) {
+	p := newWSPipe(t, &WebSocketConfig{})
+	result := p.read()
+	go p.client.Write([]byte{finalBit | TextMessage, 1, 'x'})
+	b0, payload, err := readServerFrame(p.reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b0 != finalBit|CloseMessage || binary.BigEndian.Uint16(payload) != CloseProtocolError {
+		t.Fatalf("reply = %#x %x", b0, payload)
+	}
+	var closeErr *CloseError
+	if r := <-result; !errors.As(r.err, &closeErr) || closeErr.Code != CloseProtocolError {
+		t.Fatalf("ReadMessage err = %v", r.err)
+	}
+}
+
+// dialWebSocket 建立真实的TCP连接并完成握手
+func dialWebSocket(t *testing.T, url string, header http.Header) (net.Conn, *bufio.Reader, *http.Response) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	t.Cleanup(func() { conn.Close() })
+
+	req, _ := http.NewRequest("GET", url+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", wsTestKey)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, br, resp
+}
+
+func TestWebSocketServer(t *testing.T) {
+	core := NewCore()
+	core.SetMode(TestMode)
+	config := &WebSocketConfig{Subprotocols: []string{"echo"}, EnableCompression: true}
+	core.WebSocket("/ws", config, func(c *Context, conn *WebSocketConn) error {
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				return nil
+			}
+			if err := conn.WriteMessage(messageType, data); err != nil {
+				return err
+			}
+		}
+	}, func(c *Context) error {
+		c.SetHeader("X-Auth", "ok")
+		return c.Next()
+	})
+	server := httptest.NewServer(core)
+	defer server.Close()
+
+	conn, br, resp := dialWebSocket(t, server.URL, http.Header{
+		"Sec-Websocket-Protocol":   {"chat, echo"},
+		"Sec-Websocket-Extensions": {"permessage-deflate; client_max_window_bits"},
+	})
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	wantHeader := map[string]string{
+		"Sec-Websocket-Accept":     "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=",
+		"Sec-Websocket-Protocol":   "echo",
+		"Sec-Websocket-Extensions": "permessage-deflate; server_no_context_takeover; client_no_context_takeover",
+		"X-Auth":                   "ok",
+	}
+	for k, v := range wantHeader {
+		if got := resp.Header.Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+
+	// 长消息压缩后回显，短消息直接回显
+	long := strings.Repeat("compress me ", 20)
+	deflated, err := deflate([]byte(long))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeClientFrame(conn, finalBit|rsv1Bit|TextMessage, deflated); err != nil {
+		t.Fatal(err)
+	}
+	b0, payload, err := readServerFrame(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b0 != finalBit|rsv1Bit|TextMessage {
+		t.Fatalf("echo frame = %#x, want compressed text", b0)
+	}
+	p := &WebSocketConn{readLimit: defaultWebSocketReadLimit}
+	if inflated, err := p.inflate(payload); err != nil || string(inflated) != long {
+		t.Fatalf("inflate echo = %q, %v", inflated, err)
+	}
+
+	if err := writeClientFrame(conn, finalBit|TextMessage, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if b0, payload, err = readServerFrame(br); err != nil || b0 != finalBit|TextMessage || string(payload) != "hi" {
+		t.Fatalf("echo = %#x %q %v", b0, payload, err)
+	}
+
+	if err := writeClientFrame(conn, finalBit|CloseMessage, []byte{0x03, 0xe8}); err != nil {
+		t.Fatal(err)
+	}
+	if b0, payload, err = readServerFrame(br); err != nil || b0 != finalBit|CloseMessage || string(payload) != "\x03\xe8" {
+		t.Fatalf("close reply = %#x %x %v", b0, payload, err)
+	}
+	// 服务端回复close后关闭TCP连接
+	if _, err := br.ReadByte(); err != io.EOF {
+		t.Fatalf("read after close = %v, want EOF", err)
+	}
+}
+
+func TestWebSocketDrain(t *testing.T) {
+	core := NewCore()
+	core.SetMode(TestMode)
+	upgraded := make(chan struct{}, 2)
+	core.WebSocket("/ws", nil, func(c *Context, conn *WebSocketConn) error {
+		upgraded <- struct{}{}
+		conn.ReadMessage()
+		return nil
+	})
+	server := httptest.NewServer(core)
+	defer server.Close()
+
+	_, br, resp := dialWebSocket(t, server.URL, nil)
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	<-upgraded
+	core.inflight.SetDraining()
+
+	b0, payload, err := readServerFrame(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b0 != finalBit|CloseMessage || binary.BigEndian.Uint16(payload) != CloseGoingAway {
+		t.Fatalf("drain frame = %#x %x, want close 1001", b0, payload)
+	}
+
+	// 已经在关闭时新的连接立即以1001关闭
+	_, br, resp = dialWebSocket(t, server.URL, nil)
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	if b0, payload, err = readServerFrame(br); err != nil || binary.BigEndian.Uint16(payload) != CloseGoingAway {
+		t.Fatalf("frame after drain = %#x %x %v", b0, payload, err)
+	}
+}
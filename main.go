@@ -2,34 +2,185 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"github.com/iceymoss/axis/framework"
+	"github.com/iceymoss/axis/framework/middleware"
 	"log"
-	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
+	"slices"
+	"time"
 )
 
 func main() {
+	// 命令行参数优先于配置文件和环境变量，例如 -app.address=:9000
+	flag.String("app.address", "", "listen address")
+	flag.Parse()
+
+	config, err := framework.NewConfig("./config", "")
+	if err != nil {
+		log.Fatal("Load config: ", err)
+	}
+	if err := config.BindFlags(flag.CommandLine); err != nil {
+		log.Fatal("Load config: ", err)
+	}
+	config.Watch(10 * time.Second)
+	// 环境变量AXIS_MODE优先于配置文件
+	if mode := config.GetString("app.mode"); mode != "" && os.Getenv(framework.EnvAxisMode) == "" {
+		mode, err := framework.ParseMode(mode)
+		if err != nil {
+			log.Fatal("Load config: ", err)
+		}
+		framework.SetMode(mode)
+	}
+
 	core := framework.NewCore()
+	core.SetConfig(config)
+	logFile, err := setupLogger(core, config)
+	if err != nil {
+		log.Fatal("Open log file: ", err)
+	}
+	tracer, err := setupTracer(config)
+	if err != nil {
+		log.Fatal("Setup tracer: ", err)
+	}
+	core.Use(middleware.RequestID())
+	if tracer != nil {
+		core.Use(middleware.Tracing(tracer))
+	}
+	core.Use(middleware.Metrics(core.Metrics()), middleware.AccessLog(), middleware.Recovery())
+	core.Use(middleware.ServerTimingWithConfig(middleware.ServerTimingConfig{
+		TrustedNetworks: config.GetStringSlice("app.server_timing_networks"),
+	}))
+	core.MountMetrics("/metrics")
+	if err := core.MountHealth(framework.HealthConfig{}); err != nil {
+		log.Fatal("Mount health: ", err)
+	}
 	//core.Use(middleware.Test1(), middleware.Test2())
 	//subjectApi := core.Group("/test")
 	//subjectApi.Use(middleware.Test3())
 	registerRouter(core)
-	serve := &http.Server{
-		Addr:    ":8000",
-		Handler: core,
+	// 调试和管理接口需要配置debug.password，release模式下调试接口还需要debug.enabled
+	if password := config.GetString("debug.password"); password != "" {
+		core.SetDebugEndpoints(config.GetBool("debug.enabled"))
+		debug := core.Group("/debug")
+		debug.Use(middleware.BasicAuth(map[string]string{config.GetString("debug.user"): password}))
+		core.Debug(debug)
+
+		admin := core.Group("/admin")
+		admin.Use(middleware.BasicAuth(map[string]string{config.GetString("debug.user"): password}))
+		core.Admin(admin, framework.AdminConfig{
+			SlowThreshold:   config.GetDuration("debug.slow_threshold"),
+			LogSlowRequests: true,
+		})
 	}
-	go func() {
-		serve.ListenAndServe()
-	}()
 
-	quit := make(chan os.Signal)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
-	<-quit //主协程堵塞，等待结束命令
+	// 阻塞到收到退出信号，然后优雅关闭
+	server := framework.NewServer(core)
+	if d := config.GetDuration("app.read_header_timeout"); d > 0 {
+		server.ReadHeaderTimeout = d
+	}
+	if d := config.GetDuration("app.idle_timeout"); d > 0 {
+		server.IdleTimeout = d
+	}
+	if d := config.GetDuration("app.shutdown_timeout"); d > 0 {
+		server.ShutdownTimeout = d
+	}
+	server.DrainDelay = config.GetDuration("app.drain_delay")
+	if logFile != nil {
+		// 用于重新打开日志文件的信号不再触发平滑重启
+		server.RestartSignals = withoutSignals(server.RestartSignals, logFile.Signals())
+	}
+	if tracer != nil {
+		server.OnStop(tracer.Shutdown)
+	}
+	if logFile != nil {
+		// 最后关闭日志文件，保证关闭过程中的日志也能写入
+		server.OnStop(func(ctx context.Context) error {
+			return logFile.Close()
+		})
+	}
+	address := config.GetString("app.address")
+	if address == "" {
+		address = ":8000"
+	}
+	if err := server.Run(address); err != nil {
+		log.Fatal("Server exit: ", err)
+	}
+}
+
+// setupLogger 按配置设置日志，配置了log.file时写入按大小和时间切分的文件
+func setupLogger(core *framework.Core, config *framework.Config) (*framework.RotateWriter, error) {
+	level := framework.LevelDebug
+	if !core.IsDebugging() {
+		level = framework.LevelInfo
+	}
+	if s := config.GetString("log.level"); s != "" {
+		l, err := framework.ParseLevel(s)
+		if err != nil {
+			return nil, err
+		}
+		level = l
+	}
+	file := config.GetString("log.file")
+	if file == "" {
+		if config.GetString("log.level") != "" {
+			logger := framework.NewLogger(os.Stderr, level, framework.ConsoleEncoder{})
+			if !core.IsDebugging() {
+				logger = framework.NewLogger(os.Stderr, level, framework.JSONEncoder{})
+			}
+			core.SetLogger(logger)
+		}
+		return nil, nil
+	}
+	w, err := framework.NewRotateWriter(framework.RotateConfig{
+		Filename:       file,
+		MaxSize:        config.GetInt64("log.max_size"),
+		Interval:       config.GetDuration("log.interval"),
+		MaxBackups:     config.GetInt("log.max_backups"),
+		MaxAge:         config.GetDuration("log.max_age"),
+		Compress:       config.GetBool("log.compress"),
+		ReopenOnSignal: config.GetBool("log.reopen_on_signal"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	core.SetLogger(framework.NewLogger(w, level, framework.JSONEncoder{}))
+	return w, nil
+}
 
-	// 调用Server.Shutdown graceful结束
-	if err := serve.Shutdown(context.Background()); err != nil {
-		log.Fatal("Server Shutdown:", err)
+// withoutSignals 从signals中去掉exclude中的信号
+func withoutSignals(signals []os.Signal, exclude []os.Signal) []os.Signal {
+	kept := make([]os.Signal, 0, len(signals))
+	for _, sig := range signals {
+		if !slices.Contains(exclude, sig) {
+			kept = append(kept, sig)
+		}
+	}
+	return kept
+}
+
+// setupTracer 按配置开启链路追踪，没有配置trace.exporter时返回nil
+func setupTracer(config *framework.Config) (*framework.Tracer, error) {
+	var exporter framework.SpanExporter
+	service := config.GetString("trace.service_name")
+	switch config.GetString("trace.exporter") {
+	case "":
+		return nil, nil
+	case "stdout":
+		exporter = framework.NewOTLPJSONExporter(os.Stdout, service)
+	case "file":
+		e, err := framework.NewOTLPJSONFileExporter(config.GetString("trace.file"), service)
+		if err != nil {
+			return nil, err
+		}
+		exporter = e
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", config.GetString("trace.exporter"))
+	}
+	var sampler framework.Sampler
+	if config.IsExist("trace.sample_ratio") {
+		sampler = framework.RatioSampler(config.GetFloat64("trace.sample_ratio"))
 	}
+	return framework.NewTracer(exporter, sampler), nil
 }